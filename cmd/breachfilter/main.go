@@ -0,0 +1,72 @@
+// Command breachfilter builds the breached password filter the server loads
+// from BREACHED_PASSWORDS. Its input has one password per line, or one hex
+// SHA-1 per line as in Have I Been Pwned's downloads:
+//
+//	go run ./cmd/breachfilter -in pwned-passwords-sha1.txt -out breached.bloom
+package main
+
+import (
+	"bufio"
+	"flag"
+	"github.com/dabates/httpServer/internal/passwords"
+	"log"
+	"os"
+)
+
+func main() {
+	in := flag.String("in", "", "file with one password or SHA-1 per line")
+	out := flag.String("out", "breached.bloom", "where to write the filter")
+	fp := flag.Float64("fp", 0.001, "false positive rate")
+	flag.Parse()
+
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	n, err := countLines(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filter := passwords.NewBloom(n, *fp)
+	f, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if err := filter.AddList(f); err != nil {
+		log.Fatal(err)
+	}
+
+	dst, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	size, err := filter.WriteTo(dst)
+	if err == nil {
+		err = dst.Close()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("wrote %d passwords to %s in %d bytes", n, *out, size)
+}
+
+func countLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			n++
+		}
+	}
+	return n, scanner.Err()
+}
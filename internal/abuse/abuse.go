@@ -0,0 +1,223 @@
+// Package abuse scores new chirps for spam: near-duplicates of the author's
+// recent chirps, bursts of links, new accounts posting fast and repeated
+// mentions. It only looks at what it is given and never touches the database.
+package abuse
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Reasons recorded with a score.
+const (
+	ReasonDuplicate        = "duplicate"
+	ReasonNearDuplicate    = "near_duplicate"
+	ReasonTooManyLinks     = "too_many_links"
+	ReasonLinkBurst        = "link_burst"
+	ReasonNewAccountBurst  = "new_account_velocity"
+	ReasonTooManyMentions  = "too_many_mentions"
+	ReasonRepeatedMentions = "repeated_mentions"
+)
+
+// Thresholds configure the heuristics. A chirp scoring FlagScore or more is
+// flagged for review; one scoring RejectScore or more is refused.
+type Thresholds struct {
+	// Window is how far back the author's chirps are compared against.
+	Window time.Duration
+
+	// Similarity is the word-pair overlap, from 0 to 1, at which a chirp
+	// counts as a near-duplicate of an earlier one.
+	Similarity float64
+
+	// MaxLinks is the most links one chirp may carry, and MaxWindowLinks
+	// the most across all of the author's chirps in the window.
+	MaxLinks       int
+	MaxWindowLinks int
+
+	// Accounts younger than NewAccountAge may post NewAccountChirps chirps
+	// per window.
+	NewAccountAge    time.Duration
+	NewAccountChirps int
+
+	// MaxMentions is the most distinct users one chirp may mention, and
+	// MaxRepeatMentions how often the author may mention the same user in
+	// the window.
+	MaxMentions       int
+	MaxRepeatMentions int
+
+	FlagScore   float64
+	RejectScore float64
+}
+
+// DefaultThresholds are the limits used unless main.go says otherwise.
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		Window:            time.Hour,
+		Similarity:        0.8,
+		MaxLinks:          2,
+		MaxWindowLinks:    10,
+		NewAccountAge:     24 * time.Hour,
+		NewAccountChirps:  10,
+		MaxMentions:       5,
+		MaxRepeatMentions: 5,
+		FlagScore:         1,
+		RejectScore:       3,
+	}
+}
+
+// How much each reason adds to a score.
+var weights = map[string]float64{
+	ReasonDuplicate:        3,
+	ReasonNearDuplicate:    1.5,
+	ReasonTooManyLinks:     1,
+	ReasonLinkBurst:        1.5,
+	ReasonNewAccountBurst:  1.5,
+	ReasonTooManyMentions:  1,
+	ReasonRepeatedMentions: 1,
+}
+
+// Input is a chirp about to be posted and what is known about its author.
+type Input struct {
+	Body       string
+	AccountAge time.Duration
+	// Recent are the bodies of the author's chirps in the window.
+	Recent []string
+}
+
+// Score is the verdict on one chirp.
+type Score struct {
+	Total   float64
+	Reasons []string
+}
+
+func (s *Score) add(reason string) {
+	s.Total += weights[reason]
+	s.Reasons = append(s.Reasons, reason)
+}
+
+// Flagged reports whether the chirp should be reviewed by a moderator.
+func (s Score) Flagged(t Thresholds) bool {
+	return s.Total >= t.FlagScore
+}
+
+// Rejected reports whether the chirp should not be posted at all.
+func (s Score) Rejected(t Thresholds) bool {
+	return s.Total >= t.RejectScore
+}
+
+var (
+	linkPattern    = regexp.MustCompile(`(?i)\bhttps?://\S+|\bwww\.\S+`)
+	mentionPattern = regexp.MustCompile(`@(\w+)`)
+)
+
+// Check scores in against t.
+func Check(t Thresholds, in Input) Score {
+	score := Score{}
+
+	hash := ContentHash(in.Body)
+	shingles := wordPairs(in.Body)
+	duplicate, similar := false, false
+	for _, body := range in.Recent {
+		if ContentHash(body) == hash {
+			duplicate = true
+			break
+		}
+		if similarity(shingles, wordPairs(body)) >= t.Similarity {
+			similar = true
+		}
+	}
+	if duplicate {
+		score.add(ReasonDuplicate)
+	} else if similar {
+		score.add(ReasonNearDuplicate)
+	}
+
+	links := len(linkPattern.FindAllString(in.Body, -1))
+	if links > t.MaxLinks {
+		score.add(ReasonTooManyLinks)
+	}
+	windowLinks := links
+	for _, body := range in.Recent {
+		windowLinks += len(linkPattern.FindAllString(body, -1))
+	}
+	if links > 0 && windowLinks > t.MaxWindowLinks {
+		score.add(ReasonLinkBurst)
+	}
+
+	if in.AccountAge < t.NewAccountAge && len(in.Recent)+1 > t.NewAccountChirps {
+		score.add(ReasonNewAccountBurst)
+	}
+
+	mentions := mentionSet(in.Body)
+	if len(mentions) > t.MaxMentions {
+		score.add(ReasonTooManyMentions)
+	}
+	for name := range mentions {
+		count := 1
+		for _, body := range in.Recent {
+			if mentionSet(body)[name] {
+				count++
+			}
+		}
+		if count > t.MaxRepeatMentions {
+			score.add(ReasonRepeatedMentions)
+			break
+		}
+	}
+
+	return score
+}
+
+// ContentHash identifies a chirp's text regardless of case, punctuation and
+// spacing.
+func ContentHash(body string) string {
+	sum := sha256.Sum256([]byte(strings.Join(words(body), " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+func words(body string) []string {
+	return strings.FieldsFunc(strings.ToLower(body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '@'
+	})
+}
+
+// wordPairs returns the set of adjacent word pairs in body, or of single
+// words when there is only one.
+func wordPairs(body string) map[string]bool {
+	w := words(body)
+	set := map[string]bool{}
+	if len(w) == 1 {
+		set[w[0]] = true
+	}
+	for i := 0; i+1 < len(w); i++ {
+		set[w[i]+" "+w[i+1]] = true
+	}
+	return set
+}
+
+// similarity is the Jaccard index of two sets.
+func similarity(a map[string]bool, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for item := range a {
+		if b[item] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func mentionSet(body string) map[string]bool {
+	set := map[string]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		set[strings.ToLower(m[1])] = true
+	}
+	return set
+}
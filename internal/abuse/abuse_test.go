@@ -0,0 +1,110 @@
+package abuse
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func hasReason(score Score, reason string) bool {
+	for _, r := range score.Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOrdinaryChirpIsClean(t *testing.T) {
+	score := Check(DefaultThresholds(), Input{
+		Body:       "Just had the best coffee of my life",
+		AccountAge: 30 * 24 * time.Hour,
+		Recent:     []string{"Good morning everyone", "Anyone going to the game tonight?"},
+	})
+	if score.Total != 0 || len(score.Reasons) != 0 {
+		t.Fatalf("Expected a clean score, got %+v", score)
+	}
+}
+
+func TestDuplicatesAreRejected(t *testing.T) {
+	thresholds := DefaultThresholds()
+	score := Check(thresholds, Input{
+		Body:       "Buy cheap followers now!",
+		AccountAge: 30 * 24 * time.Hour,
+		Recent:     []string{"buy  CHEAP followers now"},
+	})
+	if !hasReason(score, ReasonDuplicate) || !score.Rejected(thresholds) {
+		t.Fatalf("Expected an exact duplicate to be rejected, got %+v", score)
+	}
+}
+
+func TestNearDuplicatesAreFlagged(t *testing.T) {
+	thresholds := DefaultThresholds()
+	score := Check(thresholds, Input{
+		Body:       "check out my new album on every platform today friends",
+		AccountAge: 30 * 24 * time.Hour,
+		Recent:     []string{"check out my new album on every platform today"},
+	})
+	if !hasReason(score, ReasonNearDuplicate) || !score.Flagged(thresholds) || score.Rejected(thresholds) {
+		t.Fatalf("Expected a near-duplicate to be flagged only, got %+v", score)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	score := Check(DefaultThresholds(), Input{
+		Body:       "http://a.example https://b.example www.c.example",
+		AccountAge: 30 * 24 * time.Hour,
+	})
+	if !hasReason(score, ReasonTooManyLinks) {
+		t.Fatalf("Expected too many links, got %+v", score)
+	}
+
+	recent := []string{}
+	for i := 0; i < 5; i++ {
+		recent = append(recent, "look https://x.example/"+strings.Repeat("a", i)+" and https://y.example/"+strings.Repeat("b", i))
+	}
+	score = Check(DefaultThresholds(), Input{
+		Body:       "one more https://z.example",
+		AccountAge: 30 * 24 * time.Hour,
+		Recent:     recent,
+	})
+	if !hasReason(score, ReasonLinkBurst) {
+		t.Fatalf("Expected a link burst, got %+v", score)
+	}
+}
+
+func TestNewAccountVelocity(t *testing.T) {
+	recent := []string{}
+	for i := 0; i < 10; i++ {
+		recent = append(recent, strings.Repeat("word ", i+1)+"unique"+strings.Repeat("x", i))
+	}
+
+	in := Input{Body: "hello there", AccountAge: time.Hour, Recent: recent}
+	if score := Check(DefaultThresholds(), in); !hasReason(score, ReasonNewAccountBurst) {
+		t.Fatalf("Expected a new account posting fast to be caught, got %+v", score)
+	}
+
+	in.AccountAge = 30 * 24 * time.Hour
+	if score := Check(DefaultThresholds(), in); hasReason(score, ReasonNewAccountBurst) {
+		t.Fatalf("Expected an old account to post freely, got %+v", score)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	score := Check(DefaultThresholds(), Input{
+		Body:       "@a @b @c @d @e @f hi",
+		AccountAge: 30 * 24 * time.Hour,
+	})
+	if !hasReason(score, ReasonTooManyMentions) {
+		t.Fatalf("Expected too many mentions, got %+v", score)
+	}
+
+	score = Check(DefaultThresholds(), Input{
+		Body:       "@Victim answer me",
+		AccountAge: 30 * 24 * time.Hour,
+		Recent:     []string{"@victim hello", "@victim are you there", "@victim ??", "@victim hey", "@victim please"},
+	})
+	if !hasReason(score, ReasonRepeatedMentions) {
+		t.Fatalf("Expected repeated mentions, got %+v", score)
+	}
+}
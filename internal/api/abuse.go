@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"github.com/dabates/httpServer/internal/abuse"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/types"
+	"net/http"
+	"time"
+)
+
+// scoreChirp runs the abuse heuristics on body, which user is about to post,
+// against the author's chirps in the thresholds' window.
+func scoreChirp(ctx context.Context, thresholds abuse.Thresholds, db *database.Queries, user database.User, body string) (abuse.Score, error) {
+	recent, err := db.GetRecentChirpsByUser(ctx, database.GetRecentChirpsByUserParams{
+		UserID:        user.ID,
+		WindowSeconds: thresholds.Window.Seconds(),
+	})
+	if err != nil {
+		return abuse.Score{}, err
+	}
+
+	in := abuse.Input{
+		Body:       body,
+		AccountAge: time.Since(user.CreatedAt),
+	}
+	for _, chirp := range recent {
+		in.Recent = append(in.Recent, chirp.Body)
+	}
+
+	return abuse.Check(thresholds, in), nil
+}
+
+type flaggedChirpBody struct {
+	Chirp   chirpsBody `json:"chirp"`
+	Score   float64    `json:"score"`
+	Reasons []string   `json:"reasons"`
+}
+
+// FlaggedChirps lists the chirps the abuse heuristics flagged, newest first.
+// ?limit= caps the list, at most maxPageSize.
+func FlaggedChirps(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	if err := authenticateAdmin(r, config); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	limit, ok := pageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := config.Db.GetFlaggedChirps(r.Context(), limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resp := []flaggedChirpBody{}
+	for _, row := range rows {
+		resp = append(resp, flaggedChirpBody{
+			Chirp: newChirpsBody(database.Chirp{
+				ID:        row.ID,
+				Body:      row.Body,
+				UserID:    row.UserID,
+				CreatedAt: row.CreatedAt,
+				UpdatedAt: row.UpdatedAt,
+			}),
+			Score:   row.Score,
+			Reasons: row.Reasons,
+		})
+	}
+
+	respond(w, enc, http.StatusOK, resp)
+}
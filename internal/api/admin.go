@@ -0,0 +1,27 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/dabates/httpServer/internal/auth"
+	"github.com/dabates/httpServer/internal/types"
+	"net/http"
+)
+
+// authenticateAdmin checks the request carries the ADMIN_KEY as
+// "Authorization: ApiKey <key>". Admin endpoints are off when no key is set.
+func authenticateAdmin(r *http.Request, config *types.ApiConfig) error {
+	if config.AdminApiKey == "" {
+		return errors.New("Admin API is disabled")
+	}
+
+	apiKey, err := auth.GetApiKey(r.Header)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(apiKey), []byte(config.AdminApiKey)) != 1 {
+		return errors.New("Invalid API key")
+	}
+
+	return nil
+}
@@ -2,50 +2,163 @@ package api
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"github.com/dabates/httpServer/internal/auth"
+	"github.com/dabates/httpServer/internal/database"
 	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
 	"log"
 	"net/http"
 	"time"
 )
 
+// authenticate returns the id of the user whose JWT is in the request's
+// Authorization header. Suspended users are refused, since tokens issued
+// before the suspension stay valid until they expire.
+func authenticate(r *http.Request, config *types.ApiConfig) (uuid.UUID, error) {
+	userID, err := bearerUserID(r, config)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := checkNotSuspended(r.Context(), config, userID); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// bearerUserID is authenticate without the suspension check, for telling
+// callers apart rather than letting them in.
+func bearerUserID(r *http.Request, config *types.ApiConfig) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return auth.ValidateJWT(token, config.Secret)
+}
+
+// checkNotSuspended returns errUserSuspended if the user is suspended, and
+// errUserNotFound if they were deleted.
+func checkNotSuspended(ctx context.Context, config *types.ApiConfig, userID uuid.UUID) error {
+	user, err := config.Db.GetUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if user.SuspendedAt.Valid {
+		return errUserSuspended
+	}
+	return nil
+}
+
+// loginBody is the answer to a successful login.
+type loginBody struct {
+	Id        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	Email     string `json:"email"`
+	Handle    string `json:"handle,omitempty"`
+	ChirpyRed bool   `json:"is_chirpy_red"`
+	// EmailVerified is false until the address is verified; unverified
+	// users cannot post chirps or send messages.
+	EmailVerified bool   `json:"email_verified"`
+	Token         string `json:"token"`
+	RefreshToken  string `json:"refresh_token"`
+}
+
+// Login checks a login and password. Users with two-factor authentication
+// get a challenge for LoginTwoFactor instead of tokens.
 func Login(w http.ResponseWriter, r *http.Request, a *types.ApiConfig) {
+	// Either field may carry an email address or a handle.
 	type reqBody struct {
 		Email    string `json:"email"`
+		Handle   string `json:"handle"`
 		Password string `json:"password"`
 	}
 
-	type respBody struct {
-		Id           string `json:"id"`
-		CreatedAt    string `json:"created_at"`
-		UpdatedAt    string `json:"updated_at"`
-		Email        string `json:"email"`
-		ChirpyRed    bool   `json:"is_chirpy_red"`
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
 	}
 
 	bodyData := reqBody{}
 	err := json.NewDecoder(r.Body).Decode(&bodyData)
 	if err != nil {
-		log.Fatal(err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
 	}
 
-	user, err := a.Db.GetUserByEmail(r.Context(), bodyData.Email)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+	login := bodyData.Email
+	if login == "" {
+		login = bodyData.Handle
+	}
+	user, err := a.Db.GetUserByLogin(r.Context(), login)
+	found := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(err.Error()))
 		return
 	}
 
-	ok := auth.CheckPasswordHash(bodyData.Password, user.HashedPassword)
+	attempt, ok := beginLoginAttempt(w, r, a, loginKeys(r, a, user, found, login))
 	if !ok {
+		return
+	}
+
+	// Unknown logins are checked against a dummy hash, so they take as long
+	// to fail as wrong passwords and get the same answer.
+	hash := user.HashedPassword
+	if !found {
+		hash, err = dummyPasswordHash()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}
+	ok = auth.CheckPasswordHash(bodyData.Password, hash)
+	if !ok || !found {
+		failLoginAttempt(a, attempt, user, found)
 		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Invalid password"))
+		w.Write([]byte(errLoginFailed.Error()))
 		return
 	}
 
+	// Hashes made with bcrypt or older parameters are upgraded while the
+	// password is at hand.
+	if auth.NeedsRehash(user.HashedPassword) {
+		rehashPassword(r.Context(), a, user, bodyData.Password)
+	}
+
+	enabled, err := twoFactorEnabled(r.Context(), a, user.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if enabled {
+		// The count is only cleared once the second factor checks out
+		// too, so that cannot be guessed at any faster than the password.
+		forgiveLoginAttempt(r.Context(), a, attempt.keys)
+		sendLoginChallenge(w, r, enc, a, user)
+		return
+	}
+
+	succeedLoginAttempt(r.Context(), a, attempt)
+	if user.SuspendedAt.Valid {
+		refuseSuspended(w, enc, a, user.ID)
+		return
+	}
+	issueLogin(w, enc, a, user)
+}
+
+// issueLogin answers a successful login with user and fresh tokens.
+func issueLogin(w http.ResponseWriter, enc encoder, a *types.ApiConfig, user database.User) {
 	//Get token for auth
 	token, err := auth.MakeJWT(user.ID, a.Secret, time.Duration(3600)*time.Second)
 	if err != nil {
@@ -61,23 +174,19 @@ func Login(w http.ResponseWriter, r *http.Request, a *types.ApiConfig) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	resp := respBody{
-		Id:           user.ID.String(),
-		CreatedAt:    user.CreatedAt.String(),
-		UpdatedAt:    user.UpdatedAt.String(),
-		Email:        user.Email,
-		ChirpyRed:    user.IsChirpyRed,
-		Token:        token,
-		RefreshToken: refreshToken,
-	}
-	data, err := json.Marshal(resp)
-	if err != nil {
-		log.Fatal(err)
+	resp := loginBody{
+		Id:            user.ID.String(),
+		CreatedAt:     user.CreatedAt.String(),
+		UpdatedAt:     user.UpdatedAt.String(),
+		Email:         user.Email,
+		Handle:        user.Handle.String,
+		ChirpyRed:     user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Token:         token,
+		RefreshToken:  refreshToken,
 	}
 
-	w.Header().Set("content-type", "application/json")
-	w.Write(data)
+	respond(w, enc, http.StatusOK, resp)
 }
 
 func Refresh(w http.ResponseWriter, r *http.Request, a *types.ApiConfig) {
@@ -92,7 +201,12 @@ func Refresh(w http.ResponseWriter, r *http.Request, a *types.ApiConfig) {
 		return
 	}
 
-	ok, _ := auth.ValidateRefreshToken(refreshToken, a.Db)
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	ok, _ = auth.ValidateRefreshToken(refreshToken, a.Db)
 	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		w.Write([]byte("Invalid refresh token"))
@@ -105,6 +219,10 @@ func Refresh(w http.ResponseWriter, r *http.Request, a *types.ApiConfig) {
 		w.Write([]byte(err.Error()))
 		return
 	}
+	if user.SuspendedAt.Valid {
+		refuseSuspended(w, enc, a, user.UserID)
+		return
+	}
 
 	//Get token for auth
 	token, err := auth.MakeJWT(user.UserID, a.Secret, time.Duration(3600)*time.Second)
@@ -115,18 +233,11 @@ func Refresh(w http.ResponseWriter, r *http.Request, a *types.ApiConfig) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
 	resp := respBody{
 		Token: token,
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	w.Header().Set("content-type", "application/json")
-	w.Write(data)
+	respond(w, enc, http.StatusOK, resp)
 }
 
 func Revoke(w http.ResponseWriter, r *http.Request, a *types.ApiConfig) {
@@ -146,3 +257,19 @@ func Revoke(w http.ResponseWriter, r *http.Request, a *types.ApiConfig) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// rehashPassword replaces user's password hash with one made with the
+// current parameters. Failing only delays the upgrade to the next login.
+func rehashPassword(ctx context.Context, a *types.ApiConfig, user database.User, password string) {
+	hashed, err := auth.HashPassword(password)
+	if err == nil {
+		_, err = a.Db.RehashPassword(ctx, database.RehashPasswordParams{
+			ID:      user.ID,
+			OldHash: user.HashedPassword,
+			NewHash: hashed,
+		})
+	}
+	if err != nil {
+		log.Printf("auth: rehashing password: %v", err)
+	}
+}
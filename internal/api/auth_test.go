@@ -0,0 +1,40 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/dabates/httpServer/internal/auth"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/types"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthenticateRefusesSuspendedUsers(t *testing.T) {
+	active, suspended := testUser(), testUser()
+	suspended.SuspendedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	config := &types.ApiConfig{Secret: "secret"}
+	config.DB, config.Db = newFakeDB(active, suspended).open()
+
+	cases := []struct {
+		name string
+		user database.User
+		want error
+	}{
+		{"active", active, nil},
+		// The token was issued before the suspension and has not expired.
+		{"suspended", suspended, errUserSuspended},
+	}
+	for _, c := range cases {
+		token, err := auth.MakeJWT(c.user.ID, config.Secret, time.Hour)
+		if err != nil {
+			t.Fatalf("MakeJWT failed: %v", err)
+		}
+		r := httptest.NewRequest("GET", "/api/users/me/profile", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		if _, err := authenticate(r, config); !errors.Is(err, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/events"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"log"
+	"net/http"
+)
+
+// maxBatchSize caps the number of operations in one POST /api/batch.
+const maxBatchSize = 100
+
+// errBatchOperationFailed rolls back an operation whose result is an error.
+var errBatchOperationFailed = errors.New("batch operation failed")
+
+const (
+	batchCreateChirp = "create_chirp"
+	batchDeleteChirp = "delete_chirp"
+	batchLike        = "like"
+	batchFollow      = "follow"
+)
+
+type batchOperation struct {
+	Op      string `json:"op"`
+	Body    string `json:"body,omitempty"`
+	ID      string `json:"id,omitempty"`
+	ChirpID string `json:"chirp_id,omitempty"`
+	UserID  string `json:"user_id,omitempty"`
+}
+
+type batchResult struct {
+	Index  int         `json:"index"`
+	Status int         `json:"status"`
+	Body   interface{} `json:"body,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Atomic    bool          `json:"atomic"`
+	Committed bool          `json:"committed"`
+	Results   []batchResult `json:"results"`
+}
+
+// Batch runs several operations under the caller's JWT. Each one gets its
+// own status. Chirps created count against the limit of POST /api/chirps,
+// and those over it get a 429. With "atomic" set they share a transaction: the first failure
+// rolls everything back and becomes the status of the whole batch.
+func Batch(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Atomic     bool             `json:"atomic"`
+		Operations []batchOperation `json:"operations"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if len(bodyData.Operations) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("No operations provided"))
+		return
+	}
+	if len(bodyData.Operations) > maxBatchSize {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("A batch may contain at most %d operations", maxBatchSize)))
+		return
+	}
+
+	if bodyData.Atomic {
+		status, resp := runAtomicBatch(r.Context(), config, userID, bodyData.Operations)
+		respond(w, enc, status, resp)
+		return
+	}
+
+	resp := batchResponse{Committed: true}
+	for i, op := range bodyData.Operations {
+		resp.Results = append(resp.Results, runBatchOperationAlone(r.Context(), config, userID, i, op))
+	}
+
+	respond(w, enc, http.StatusOK, resp)
+}
+
+// runAtomicBatch runs ops in a single transaction and returns the status of
+// the batch: 200 when it committed, otherwise that of the failed operation.
+// Events are only published once the transaction has committed.
+func runAtomicBatch(ctx context.Context, config *types.ApiConfig, userID uuid.UUID, ops []batchOperation) (int, batchResponse) {
+	resp := batchResponse{Atomic: true}
+
+	tx, err := config.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("batch: starting transaction: %v", err)
+		return http.StatusInternalServerError, abortBatch(resp, ops, -1)
+	}
+	defer tx.Rollback()
+	db := config.Db.WithTx(tx)
+
+	var pending []events.Event
+	for i, op := range ops {
+		result, event := runBatchOperation(ctx, config, db, userID, i, op)
+		resp.Results = append(resp.Results, result)
+		if result.Error != "" {
+			return result.Status, abortBatch(resp, ops, i)
+		}
+		if event != nil {
+			pending = append(pending, *event)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("batch: committing transaction: %v", err)
+		return http.StatusInternalServerError, abortBatch(resp, ops, -1)
+	}
+
+	resp.Committed = true
+	for _, event := range pending {
+		config.Events.Publish(event)
+	}
+	return http.StatusOK, resp
+}
+
+// runBatchOperationAlone runs one operation of a non-atomic batch in its own
+// transaction, so a failed operation leaves nothing half done, and publishes
+// its event once it commits.
+func runBatchOperationAlone(ctx context.Context, config *types.ApiConfig, userID uuid.UUID, index int, op batchOperation) batchResult {
+	var result batchResult
+	var event *events.Event
+	err := withTx(ctx, config, func(db *database.Queries) error {
+		result, event = runBatchOperation(ctx, config, db, userID, index, op)
+		if result.Error != "" {
+			return errBatchOperationFailed
+		}
+		return nil
+	})
+	if errors.Is(err, errBatchOperationFailed) {
+		return result
+	}
+	if err != nil {
+		log.Printf("batch: operation %d: %v", index, err)
+		return batchResult{Index: index, Status: http.StatusInternalServerError, Error: err.Error()}
+	}
+
+	if event != nil {
+		config.Events.Publish(*event)
+	}
+	return result
+}
+
+// abortBatch rewrites the results of a rolled back batch. Operations before
+// failed were undone and those after it never ran; both get 424. A failed
+// index of -1 means the transaction itself failed.
+func abortBatch(resp batchResponse, ops []batchOperation, failed int) batchResponse {
+	results := make([]batchResult, len(ops))
+	for i := range ops {
+		switch {
+		case i == failed:
+			results[i] = resp.Results[i]
+		case i < len(resp.Results):
+			results[i] = batchResult{Index: i, Status: http.StatusFailedDependency, Error: "Rolled back"}
+		default:
+			results[i] = batchResult{Index: i, Status: http.StatusFailedDependency, Error: "Not attempted"}
+		}
+	}
+
+	resp.Results = results
+	return resp
+}
+
+// runBatchOperation runs one operation against db, which must be a
+// transaction, and queues its webhooks there too. It returns the event to
+// publish once the transaction commits, if any.
+func runBatchOperation(ctx context.Context, config *types.ApiConfig, db *database.Queries, userID uuid.UUID, index int, op batchOperation) (batchResult, *events.Event) {
+	fail := func(status int, err error) (batchResult, *events.Event) {
+		return batchResult{Index: index, Status: status, Error: err.Error()}, nil
+	}
+
+	switch op.Op {
+	case batchCreateChirp:
+		if err := chargeRoute(ctx, config, userID, createChirpRoute); err != nil {
+			return fail(chirpErrorStatus(err), err)
+		}
+		chirp, err := insertChirp(ctx, config, db, userID, op.Body)
+		if err != nil {
+			return fail(chirpErrorStatus(err), err)
+		}
+		event := events.Event{Type: events.ChirpCreated, Chirp: chirp}
+		if err := enqueueWebhooks(ctx, config, db, event); err != nil {
+			return fail(http.StatusInternalServerError, err)
+		}
+		return batchResult{Index: index, Status: http.StatusCreated, Body: newChirpsBody(chirp)}, &event
+
+	case batchDeleteChirp:
+		id, err := uuid.Parse(op.ID)
+		if err != nil {
+			return fail(http.StatusBadRequest, err)
+		}
+		chirp, err := removeChirp(ctx, db, userID, id)
+		if err != nil {
+			return fail(chirpErrorStatus(err), err)
+		}
+		event := events.Event{Type: events.ChirpDeleted, Chirp: chirp}
+		if err := enqueueWebhooks(ctx, config, db, event); err != nil {
+			return fail(http.StatusInternalServerError, err)
+		}
+		return batchResult{Index: index, Status: http.StatusNoContent}, &event
+
+	case batchLike:
+		id, err := uuid.Parse(op.ChirpID)
+		if err != nil {
+			return fail(http.StatusBadRequest, err)
+		}
+		if err := likeChirp(ctx, db, userID, id); err != nil {
+			return fail(chirpErrorStatus(err), err)
+		}
+		return batchResult{Index: index, Status: http.StatusNoContent}, nil
+
+	case batchFollow:
+		id, err := uuid.Parse(op.UserID)
+		if err != nil {
+			return fail(http.StatusBadRequest, err)
+		}
+		if err := followUser(ctx, db, userID, id); err != nil {
+			return fail(chirpErrorStatus(err), err)
+		}
+		return batchResult{Index: index, Status: http.StatusNoContent}, nil
+	}
+
+	return fail(http.StatusBadRequest, fmt.Errorf("Unknown operation %q", op.Op))
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+var (
+	errRelateSelf = errors.New("Users cannot block or mute themselves")
+	errBlocked    = errors.New("Blocked by this user")
+)
+
+// checkNotBlocked returns errBlocked if ownerID has blocked userID, which
+// stops userID from interacting with ownerID or their chirps.
+func checkNotBlocked(ctx context.Context, db *database.Queries, ownerID uuid.UUID, userID uuid.UUID) error {
+	blocked, err := db.IsBlocked(ctx, database.IsBlockedParams{
+		BlockerID: ownerID,
+		BlockedID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return errBlocked
+	}
+	return nil
+}
+
+type relationBody struct {
+	UserId    string `json:"user_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Block stops the user in the path from following, liking or mentioning the
+// caller, and removes any follows between the two.
+func Block(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	relate(w, r, config, func(ctx context.Context, userID uuid.UUID, targetID uuid.UUID) error {
+		return withTx(ctx, config, func(db *database.Queries) error {
+			err := db.BlockUser(ctx, database.BlockUserParams{BlockerID: userID, BlockedID: targetID})
+			if err != nil {
+				return err
+			}
+			return db.DeleteFollowsBetween(ctx, database.DeleteFollowsBetweenParams{FollowerID: userID, FolloweeID: targetID})
+		})
+	})
+}
+
+func Unblock(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	relate(w, r, config, func(ctx context.Context, userID uuid.UUID, targetID uuid.UUID) error {
+		return config.Db.UnblockUser(ctx, database.UnblockUserParams{BlockerID: userID, BlockedID: targetID})
+	})
+}
+
+// Mute hides the chirps of the user in the path from the caller's chirp
+// lists. Unlike a block, the muted user is not told and can still interact.
+func Mute(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	relate(w, r, config, func(ctx context.Context, userID uuid.UUID, targetID uuid.UUID) error {
+		return config.Db.MuteUser(ctx, database.MuteUserParams{MuterID: userID, MutedID: targetID})
+	})
+}
+
+func Unmute(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	relate(w, r, config, func(ctx context.Context, userID uuid.UUID, targetID uuid.UUID) error {
+		return config.Db.UnmuteUser(ctx, database.UnmuteUserParams{MuterID: userID, MutedID: targetID})
+	})
+}
+
+// relate is the shared part of the block and mute handlers: it checks the
+// caller and the user in the path, then lets change relate them.
+func relate(w http.ResponseWriter, r *http.Request, config *types.ApiConfig, change func(ctx context.Context, userID uuid.UUID, targetID uuid.UUID) error) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	targetID, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	err = errRelateSelf
+	if targetID != userID {
+		_, err = config.Db.GetUser(r.Context(), targetID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		err = errUserNotFound
+	}
+	if err == nil {
+		err = change(r.Context(), userID, targetID)
+	}
+	if err != nil {
+		status := chirpErrorStatus(err)
+		if errors.Is(err, errRelateSelf) {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MyBlocks lists the users the caller has blocked, newest first.
+func MyBlocks(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	listRelations(w, r, config, func(ctx context.Context, userID uuid.UUID) ([]relationBody, error) {
+		blocks, err := config.Db.GetBlocks(ctx, userID)
+		resp := []relationBody{}
+		for _, block := range blocks {
+			resp = append(resp, newRelationBody(block.BlockedID, block.CreatedAt))
+		}
+		return resp, err
+	})
+}
+
+// MyMutes lists the users the caller has muted, newest first.
+func MyMutes(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	listRelations(w, r, config, func(ctx context.Context, userID uuid.UUID) ([]relationBody, error) {
+		mutes, err := config.Db.GetMutes(ctx, userID)
+		resp := []relationBody{}
+		for _, mute := range mutes {
+			resp = append(resp, newRelationBody(mute.MutedID, mute.CreatedAt))
+		}
+		return resp, err
+	})
+}
+
+func newRelationBody(userID uuid.UUID, createdAt time.Time) relationBody {
+	return relationBody{
+		UserId:    userID.String(),
+		CreatedAt: createdAt.String(),
+	}
+}
+
+func listRelations(w http.ResponseWriter, r *http.Request, config *types.ApiConfig, list func(ctx context.Context, userID uuid.UUID) ([]relationBody, error)) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	resp, err := list(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusOK, resp)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cache-Control policies used by the routes in main.go.
+const (
+	// CacheChirp lets clients and proxies keep a single chirp for a short
+	// while; afterwards they revalidate with its strong ETag.
+	CacheChirp = "public, max-age=60, must-revalidate"
+	// CacheChirpList forces revalidation of feeds on every use, which is
+	// cheap thanks to their weak ETag.
+	CacheChirpList = "public, no-cache"
+	// CacheChirpListPrivate replaces CacheChirpList for signed in callers,
+	// whose lists are filtered for them alone.
+	CacheChirpListPrivate = "private, no-cache"
+	// CacheMedia is used for uploaded images. Their URLs are named by the
+	// hash of their contents, so they never change.
+	CacheMedia = "public, max-age=31536000, immutable"
+	// CacheStatic is used for the /app/ file server.
+	CacheStatic = "public, max-age=300"
+	// CacheNoStore is used for anything carrying credentials or user data.
+	CacheNoStore = "no-store"
+)
+
+// WithCacheControl sets the Cache-Control header before calling next.
+func WithCacheControl(policy string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", policy)
+		next(w, r)
+	}
+}
+
+// chirpETag is a strong validator for a single chirp. The variant covers
+// everything in the request that changes the representation, such as
+// fields= or expand=.
+func chirpETag(chirp database.Chirp, author *database.User, variant string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%d|%s", chirp.ID, chirp.UpdatedAt.UnixNano(), variant)
+	if author != nil {
+		fmt.Fprintf(h, "|%d", author.UpdatedAt.UnixNano())
+	}
+
+	return `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
+
+// chirpsListETag is a weak validator for a list of chirps. It changes when a
+// chirp is added, removed or edited, when an embedded author changes, or
+// when a chirp gains or loses the mark of a filter.
+func chirpsListETag(chirps []database.Chirp, authors map[uuid.UUID]database.User, marks map[uuid.UUID]*filteredBody, variant string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%d", variant, len(chirps))
+	for _, chirp := range chirps {
+		fmt.Fprintf(h, "|%s:%d", chirp.ID, chirp.UpdatedAt.UnixNano())
+		if mark := marks[chirp.ID]; mark != nil {
+			fmt.Fprintf(h, "=%s", mark.FilterId)
+		}
+	}
+	for _, chirp := range chirps {
+		if author, ok := authors[chirp.UserID]; ok {
+			fmt.Fprintf(h, "|%s:%d", author.ID, author.UpdatedAt.UnixNano())
+		}
+	}
+
+	return `W/"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
+
+// checkNotModified sets the validators on the response and reports whether
+// the request's preconditions allow a 304. If-None-Match takes precedence
+// over If-Modified-Since; a zero lastModified disables the latter.
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
+
+// etagMatches implements the weak comparison If-None-Match uses.
+func etagMatches(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestChirpsListETagCoversMarks(t *testing.T) {
+	chirps := []database.Chirp{{ID: uuid.New(), UpdatedAt: time.Now()}}
+	plain := chirpsListETag(chirps, nil, nil, "")
+
+	marked := chirpsListETag(chirps, nil, map[uuid.UUID]*filteredBody{
+		chirps[0].ID: {FilterId: uuid.NewString()},
+	}, "")
+	if marked == plain {
+		t.Error("Expected marking a chirp to change the ETag")
+	}
+
+	other := chirpsListETag(chirps, nil, map[uuid.UUID]*filteredBody{
+		chirps[0].ID: {FilterId: uuid.NewString()},
+	}, "")
+	if other == marked {
+		t.Error("Expected a mark by another filter to change the ETag")
+	}
+}
@@ -2,36 +2,62 @@ package api
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
-	"fmt"
-	"github.com/dabates/httpServer/internal/auth"
+	"errors"
 	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/events"
 	"github.com/dabates/httpServer/internal/types"
 	"github.com/google/uuid"
-	"log"
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 )
 
 type chirpsBody struct {
-	Id        string `json:"id"`
-	Body      string `json:"body"`
-	UserId    string `json:"user_id"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	Id        string      `json:"id"`
+	Body      string      `json:"body"`
+	UserId    string      `json:"user_id"`
+	CreatedAt string      `json:"created_at"`
+	UpdatedAt string      `json:"updated_at"`
+	Author    *authorBody `json:"author,omitempty"`
+	// Filtered is set on chirps matching one of the caller's "mark" filters.
+	Filtered *filteredBody `json:"filtered,omitempty"`
 }
 
 func GetChirps(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
 	id := r.PathValue("id")
-	fmt.Println("ID:", id)
 
 	sortDir := r.URL.Query().Get("sort")
 	if sortDir == "" {
 		sortDir = "asc"
 	}
 
-	fmt.Println("SORT DIR:", sortDir)
+	view, err := parseChirpsView(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, id == "")
+	if !ok {
+		return
+	}
+	// Anything that changes the representation must change the validators.
+	variant := enc.ContentType() + "|" + r.URL.RawQuery
+
+	// Signed in callers get lists without the authors they muted or blocked.
+	filter := chirpFilter{}
+	if id == "" {
+		filter, err = loadChirpFilter(r, config)
+		if err != nil {
+			w.WriteHeader(chirpErrorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}
 
 	author_id := r.URL.Query().Get("author_id")
 	if len(author_id) > 0 {
@@ -56,24 +82,7 @@ func GetChirps(w http.ResponseWriter, r *http.Request, config *types.ApiConfig)
 			return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
 		})
 
-		w.WriteHeader(http.StatusOK)
-		resp := make([]chirpsBody, len(chirps))
-		for i, chirp := range chirps {
-			resp[i] = chirpsBody{
-				Id:        chirp.ID.String(),
-				Body:      chirp.Body,
-				UserId:    chirp.UserID.String(),
-				CreatedAt: chirp.CreatedAt.String(),
-				UpdatedAt: chirp.UpdatedAt.String(),
-			}
-		}
-
-		data, err := json.Marshal(resp)
-		if err != nil {
-			log.Fatal(err)
-		}
-		w.Header().Set("content-type", "application/json")
-		w.Write(data)
+		writeChirpsList(w, r, config, enc, variant, chirps, view, filter)
 		return
 	}
 
@@ -85,32 +94,45 @@ func GetChirps(w http.ResponseWriter, r *http.Request, config *types.ApiConfig)
 			return
 		}
 
-		chirp, err := config.Db.GetChirp(r.Context(), id)
+		chirp, err := config.Db.GetVisibleChirp(r.Context(), id)
 		if err != nil {
 			w.WriteHeader(http.StatusNotFound)
 			w.Write([]byte(err.Error()))
 			return
 		}
 
-		resp := chirpsBody{
-			Id:        chirp.ID.String(),
-			Body:      chirp.Body,
-			UserId:    chirp.UserID.String(),
-			CreatedAt: chirp.CreatedAt.String(),
-			UpdatedAt: chirp.UpdatedAt.String(),
-		}
-		data, err := json.Marshal(resp)
+		authors, err := viewAuthors(r.Context(), config, []database.Chirp{chirp}, view)
 		if err != nil {
-			log.Fatal(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		var author *database.User
+		lastModified := chirp.UpdatedAt
+		if user, ok := authors[chirp.UserID]; ok {
+			author = &user
+			if user.UpdatedAt.After(lastModified) {
+				lastModified = user.UpdatedAt
+			}
+		}
+
+		w.Header().Set("Cache-Control", CacheChirp)
+		if checkNotModified(w, r, chirpETag(chirp, author, variant), lastModified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
 		}
-		w.Header().Set("content-type", "application/json")
-		w.Write(data)
+
+		resp := renderChirps([]database.Chirp{chirp}, authors, nil, view)
+		respond(w, enc, http.StatusOK, resp[0])
 		return
 	}
 
 	chirps, err := config.Db.GetChirps(r.Context())
 	if err != nil {
-		log.Fatal(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
 	}
 
 	sort.Slice(chirps, func(i, j int) bool {
@@ -120,99 +142,249 @@ func GetChirps(w http.ResponseWriter, r *http.Request, config *types.ApiConfig)
 		return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
 	})
 
-	resp := make([]chirpsBody, len(chirps))
-	for i, chirp := range chirps {
-		resp[i] = chirpsBody{
-			Id:        chirp.ID.String(),
-			Body:      chirp.Body,
-			UserId:    chirp.UserID.String(),
-			CreatedAt: chirp.CreatedAt.String(),
-			UpdatedAt: chirp.UpdatedAt.String(),
-		}
-	}
-	data, err := json.Marshal(resp)
+	writeChirpsList(w, r, config, enc, variant, chirps, view, filter)
+}
+
+// writeChirpsList renders a feed of chirps as seen through filter, answering
+// with 304 when the client already holds the current version.
+func writeChirpsList(w http.ResponseWriter, r *http.Request, config *types.ApiConfig, enc encoder, variant string, chirps []database.Chirp, view chirpsView, filter chirpFilter) {
+	chirps, marks := filter.apply(chirps)
+	authors, err := viewAuthors(r.Context(), config, chirps, view)
 	if err != nil {
-		log.Fatal(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
 	}
-	w.Header().Set("content-type", "application/json")
-	w.Write(data)
 
+	// Lists carry no Last-Modified: a deleted chirp would not move it forward.
+	w.Header().Set("Cache-Control", CacheChirpList)
+	if filter.personal() {
+		w.Header().Set("Cache-Control", CacheChirpListPrivate)
+		w.Header().Add("Vary", "Authorization")
+		variant += "|" + filter.viewer.String()
+	}
+	if checkNotModified(w, r, chirpsListETag(chirps, authors, marks, variant), time.Time{}) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	respond(w, enc, http.StatusOK, renderChirps(chirps, authors, marks, view))
 }
 
-func Chirps(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
-	type reqBody struct {
-		Body   string `json:"body"`
-		UserId string `json:"user_id"`
+var (
+	errChirpTooLong   = errors.New("Body is too long")
+	errChirpNotFound  = errors.New("Chirp not found")
+	errChirpForbidden = errors.New("Not allowed to delete this chirp")
+	errChirpSpam      = errors.New("Chirp looks like spam")
+	errUserSuspended  = errors.New("Account suspended")
+)
+
+// chirpErrorStatus maps the errors of the shared chirp and user helpers to
+// HTTP status codes.
+func chirpErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errChirpTooLong), errors.Is(err, errFollowSelf):
+		return http.StatusBadRequest
+	case errors.Is(err, errUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, errChirpNotFound), errors.Is(err, errUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errChirpForbidden), errors.Is(err, errUserSuspended), errors.Is(err, errBlocked),
+		errors.Is(err, errEmailUnverified):
+		return http.StatusForbidden
+	case errors.Is(err, errChirpSpam):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, errRateLimited):
+		return http.StatusTooManyRequests
 	}
+	return http.StatusInternalServerError
+}
 
-	//Validate the jwt
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(err.Error()))
-		return
+// createChirpRoute is the route whose rate limit every chirp counts
+// against, however it was created.
+const createChirpRoute = "POST /api/chirps"
+
+// createChirp validates body and stores it as a chirp by userID. Every
+// transport that creates chirps goes through here, so the rules stay the same,
+// the rate limit of POST /api/chirps included. The chirp, its abuse score and
+// its mentions are stored together or not at all, and the event is only
+// published once they are.
+func createChirp(ctx context.Context, config *types.ApiConfig, userID uuid.UUID, body string) (database.Chirp, error) {
+	if err := chargeRoute(ctx, config, userID, createChirpRoute); err != nil {
+		return database.Chirp{}, err
 	}
 
-	userID, err := auth.ValidateJWT(token, config.Secret)
+	var chirp database.Chirp
+	err := withTx(ctx, config, func(db *database.Queries) error {
+		var err error
+		chirp, err = insertChirp(ctx, config, db, userID, body)
+		if err != nil {
+			return err
+		}
+		return enqueueWebhooks(ctx, config, db, events.Event{Type: events.ChirpCreated, Chirp: chirp})
+	})
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(err.Error()))
-		return
+		return database.Chirp{}, err
 	}
 
-	bodyData := reqBody{}
-	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	config.Events.Publish(events.Event{Type: events.ChirpCreated, Chirp: chirp})
+	return chirp, nil
+}
+
+// deleteChirp removes chirp id, provided it was written by userID.
+func deleteChirp(ctx context.Context, config *types.ApiConfig, userID uuid.UUID, id uuid.UUID) error {
+	var chirp database.Chirp
+	err := withTx(ctx, config, func(db *database.Queries) error {
+		var err error
+		chirp, err = removeChirp(ctx, db, userID, id)
+		if err != nil {
+			return err
+		}
+		return enqueueWebhooks(ctx, config, db, events.Event{Type: events.ChirpDeleted, Chirp: chirp})
+	})
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Fatal(err)
+		return err
 	}
 
-	if len(bodyData.Body) > 140 {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Fatal("Body is too long")
+	config.Events.Publish(events.Event{Type: events.ChirpDeleted, Chirp: chirp})
+	return nil
+}
+
+// insertChirp is createChirp without the transaction or the event, for
+// callers that run inside their own transaction, queue its webhooks and
+// publish once it commits.
+// db must be a transaction, as it makes several writes.
+func insertChirp(ctx context.Context, config *types.ApiConfig, db *database.Queries, userID uuid.UUID, body string) (database.Chirp, error) {
+	if len(body) > 140 {
+		return database.Chirp{}, errChirpTooLong
 	}
 
-	line := bodyData.Body
+	line := body
 	line = replaceBadWord(line, "kerfuffle")
 	line = replaceBadWord(line, "sharbert")
 	line = replaceBadWord(line, "fornax")
 
-	chirp, err := config.Db.CreateChirp(r.Context(), database.CreateChirpParams{
+	user, err := db.GetUser(ctx, userID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if user.SuspendedAt.Valid {
+		return database.Chirp{}, errUserSuspended
+	}
+	if !user.EmailVerifiedAt.Valid {
+		return database.Chirp{}, errEmailUnverified
+	}
+
+	if config.Abuse == nil {
+		chirp, err := db.CreateChirp(ctx, database.CreateChirpParams{
+			Body:   line,
+			UserID: userID,
+		})
+		if err == nil {
+			err = notifyMentions(ctx, db, chirp)
+		}
+		if err != nil {
+			return database.Chirp{}, err
+		}
+		return chirp, nil
+	}
+
+	score, err := scoreChirp(ctx, *config.Abuse, db, user, line)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if score.Rejected(*config.Abuse) {
+		return database.Chirp{}, errChirpSpam
+	}
+
+	chirp, err := db.CreateChirp(ctx, database.CreateChirpParams{
 		Body:   line,
 		UserID: userID,
 	})
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Fatal(err)
+		return database.Chirp{}, err
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	w.Header().Set("content-type", "application/json")
-	resp := chirpsBody{
-		Id:        chirp.ID.String(),
-		Body:      chirp.Body,
-		UserId:    chirp.UserID.String(),
-		CreatedAt: chirp.CreatedAt.String(),
-		UpdatedAt: chirp.UpdatedAt.String(),
+	err = db.CreateChirpAbuseScore(ctx, database.CreateChirpAbuseScoreParams{
+		ChirpID: chirp.ID,
+		Score:   score.Total,
+		Reasons: append([]string{}, score.Reasons...),
+		Flagged: score.Flagged(*config.Abuse),
+	})
+	if err == nil {
+		err = notifyMentions(ctx, db, chirp)
 	}
-	data, err := json.Marshal(resp)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Fatal(err)
+		return database.Chirp{}, err
 	}
 
-	w.Write(data)
+	return chirp, nil
 }
 
-func DeleteChirp(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
-	token, err := auth.GetBearerToken(r.Header)
+// removeChirp is deleteChirp without the event. It returns the deleted chirp.
+func removeChirp(ctx context.Context, db *database.Queries, userID uuid.UUID, id uuid.UUID) (database.Chirp, error) {
+	// verify the chirp is by this user
+	chirp, err := db.GetChirp(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Chirp{}, errChirpNotFound
+	}
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if chirp.UserID != userID {
+		return database.Chirp{}, errChirpForbidden
+	}
+
+	err = db.DeleteChirp(ctx, database.DeleteChirpParams{
+		ID:     chirp.ID,
+		UserID: userID,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+func Chirps(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Body   string `json:"body"`
+		UserId string `json:"user_id"`
+	}
+
+	//Validate the jwt
+	userID, err := authenticate(r, config)
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		w.Write([]byte(err.Error()))
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, config.Secret)
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	chirp, err := createChirp(r.Context(), config, userID, bodyData.Body)
+	if err != nil {
+		w.WriteHeader(chirpErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusCreated, newChirpsBody(chirp))
+}
+
+func DeleteChirp(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		w.Write([]byte(err.Error()))
@@ -225,27 +397,16 @@ func DeleteChirp(w http.ResponseWriter, r *http.Request, config *types.ApiConfig
 		return
 	}
 
-	// verify the chirp is by this user
-	chirp, err := config.Db.GetChirp(r.Context(), uuid.MustParse(id))
+	chirpID, err := uuid.Parse(id)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(err.Error()))
 		return
 	}
-	if chirp.UserID != userID {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("Not allowed to delete this chirp"))
-
-		return
-	}
-
-	err = config.Db.DeleteChirp(context.Background(), database.DeleteChirpParams{
-		ID:     chirp.ID,
-		UserID: userID,
-	})
 
+	err = deleteChirp(r.Context(), config, userID, chirpID)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(chirpErrorStatus(err))
 		w.Write([]byte(err.Error()))
 		return
 	}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/dabates/httpServer/internal/auth"
+	"github.com/dabates/httpServer/internal/chirpypb"
+	"github.com/dabates/httpServer/internal/ratelimit"
+	"github.com/dabates/httpServer/internal/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// emptyChirpBucket returns a config whose user has used up their chirps for
+// the minute, and a token for them.
+func emptyChirpBucket(t *testing.T) (*types.ApiConfig, string) {
+	t.Helper()
+	user := testUser()
+	config := &types.ApiConfig{Secret: "secret"}
+	config.DB, config.Db = newFakeDB(user).open()
+	config.RateLimiter = ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(1),
+		User:      ratelimit.PerMinute(1),
+		Red:       ratelimit.PerMinute(1),
+	})
+
+	_, res, err := config.RateLimiter.Allow(context.Background(), createChirpRoute, "user:"+user.ID.String(), ratelimit.User)
+	if err != nil || !res.Allowed {
+		t.Fatalf("Draining the bucket: allowed %v, err %v", res.Allowed, err)
+	}
+
+	token, err := auth.MakeJWT(user.ID, config.Secret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	return config, token
+}
+
+func TestGraphQLCreateChirpIsRateLimited(t *testing.T) {
+	config, token := emptyChirpBucket(t)
+
+	query := `{"query": "mutation { a: createChirp(body: \"hi\") { id } b: createChirp(body: \"hi\") { id } }"}`
+	r := httptest.NewRequest("POST", "/api/graphql", strings.NewReader(query))
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	GraphQL(w, r, config)
+
+	var resp struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Decoding %s: %v", w.Body, err)
+	}
+	if len(resp.Errors) == 0 {
+		t.Fatalf("Expected the mutation to be refused, got %s", w.Body)
+	}
+	for _, e := range resp.Errors {
+		if e.Message != errRateLimited.Error() {
+			t.Errorf("Expected %q, got %q", errRateLimited, e.Message)
+		}
+	}
+}
+
+func TestGRPCCreateChirpIsRateLimited(t *testing.T) {
+	config, token := emptyChirpBucket(t)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	_, err := (&grpcServer{config: config}).CreateChirp(ctx, &chirpypb.CreateChirpRequest{Body: "hi"})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Expected ResourceExhausted, got %v", err)
+	}
+}
+
+func TestCreateChirpIsNotChargedTwice(t *testing.T) {
+	config, token := emptyChirpBucket(t)
+	userID, err := auth.ValidateJWT(token, config.Secret)
+	if err != nil {
+		t.Fatalf("ValidateJWT failed: %v", err)
+	}
+
+	// POST /api/chirps was already counted by RateLimit, so the empty bucket
+	// does not stop it; the fake database does.
+	ctx := context.WithValue(context.Background(), chargedRouteKey{}, createChirpRoute)
+	_, err = createChirp(ctx, config, userID, "hi")
+	if err == nil || errors.Is(err, errRateLimited) {
+		t.Fatalf("Expected the chirp to get past the rate limit, got %v", err)
+	}
+}
+
+func TestGetChirpsAnswersDatabaseErrors(t *testing.T) {
+	config := &types.ApiConfig{}
+	config.DB, config.Db = newFakeDB().open()
+
+	w := httptest.NewRecorder()
+	GetChirps(w, httptest.NewRequest("GET", "/api/chirps", nil), config)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d: %s", w.Code, w.Body)
+	}
+}
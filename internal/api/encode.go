@@ -0,0 +1,291 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/dabates/httpServer/internal/middleware"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// encoder writes a response body in one representation. Every encoder names
+// fields after the json struct tags, so clients see the same keys (or CSV
+// columns) whatever format they ask for.
+type encoder interface {
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string { return "application/json" }
+
+func (jsonEncoder) Encode(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) ContentType() string { return "application/msgpack" }
+
+func (msgpackEncoder) Encode(w io.Writer, v interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+// csvEncoder writes a list as CSV with a header row. Nested objects are
+// flattened into dotted columns, e.g. author.id.
+type csvEncoder struct{}
+
+func (csvEncoder) ContentType() string { return "text/csv; charset=utf-8" }
+
+func (csvEncoder) Encode(w io.Writer, v interface{}) error {
+	items := reflect.ValueOf(v)
+	if items.Kind() != reflect.Slice {
+		return fmt.Errorf("csv can only encode lists, got %T", v)
+	}
+
+	var header []string
+	index := map[string]int{}
+	rows := make([]map[string]string, items.Len())
+	for i := 0; i < items.Len(); i++ {
+		row := map[string]string{}
+		for _, col := range flatten("", items.Index(i).Interface()) {
+			if _, ok := index[col.name]; !ok {
+				index[col.name] = len(header)
+				header = append(header, col.name)
+			}
+			row[col.name] = col.value
+		}
+		rows[i] = row
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for i, name := range header {
+			record[i] = row[name]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+type column struct {
+	name  string
+	value string
+}
+
+func flatten(prefix string, v interface{}) []column {
+	if r, ok := v.(record); ok {
+		var cols []column
+		for i, name := range r.names {
+			cols = append(cols, flatten(prefix+name+".", r.values[i])...)
+		}
+		return cols
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		var cols []column
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			cols = append(cols, flatten(prefix+name+".", rv.Field(i).Interface())...)
+		}
+		return cols
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		var cols []column
+		for _, key := range keys {
+			cols = append(cols, flatten(prefix+key.String()+".", rv.MapIndex(key).Interface())...)
+		}
+		return cols
+	}
+
+	name := strings.TrimSuffix(prefix, ".")
+	switch rv.Kind() {
+	case reflect.Bool:
+		return []column{{name, strconv.FormatBool(rv.Bool())}}
+	case reflect.Slice:
+		data, _ := json.Marshal(rv.Interface())
+		return []column{{name, string(data)}}
+	}
+	return []column{{name, fmt.Sprint(rv.Interface())}}
+}
+
+// record is an ordered set of named fields. Sparse fieldsets use it so every
+// encoder emits the fields in the order the client asked for them.
+type record struct {
+	names  []string
+	values []interface{}
+}
+
+func (r *record) set(name string, value interface{}) {
+	r.names = append(r.names, name)
+	r.values = append(r.values, value)
+}
+
+func (r record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range r.names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func (r record) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeMapLen(len(r.names)); err != nil {
+		return err
+	}
+	for i, name := range r.names {
+		if err := enc.EncodeString(name); err != nil {
+			return err
+		}
+		if err := enc.Encode(r.values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// negotiate picks the encoder for the request's Accept header. CSV is only
+// offered when the endpoint returns a list. When nothing acceptable is left
+// it answers 406 and returns false.
+func negotiate(w http.ResponseWriter, r *http.Request, list bool) (encoder, bool) {
+	w.Header().Add("Vary", "Accept")
+
+	offers := []encoder{jsonEncoder{}, msgpackEncoder{}}
+	if list {
+		offers = append(offers, csvEncoder{})
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return jsonEncoder{}, true
+	}
+
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, q := middleware.ParseQuality(part)
+		if mediaType != "" && q > 0 {
+			ranges = append(ranges, mediaRange{mediaType, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		// More specific ranges win ties, e.g. text/csv over */*.
+		return strings.Count(ranges[i].mediaType, "*") < strings.Count(ranges[j].mediaType, "*")
+	})
+
+	for _, mr := range ranges {
+		for _, offer := range offers {
+			if mediaTypeMatches(mr.mediaType, offer) {
+				return offer, true
+			}
+		}
+	}
+
+	supported := make([]string, len(offers))
+	for i, offer := range offers {
+		supported[i], _, _ = strings.Cut(offer.ContentType(), ";")
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusNotAcceptable)
+	w.Write([]byte("Supported types: " + strings.Join(supported, ", ")))
+
+	return nil, false
+}
+
+// msgpackAliases are the other names MessagePack goes by in the wild.
+var msgpackAliases = []string{"application/x-msgpack", "application/vnd.msgpack"}
+
+func mediaTypeMatches(mediaType string, offer encoder) bool {
+	offered, _, _ := strings.Cut(offer.ContentType(), ";")
+	if mediaType == "*/*" || mediaType == offered {
+		return true
+	}
+	if strings.HasSuffix(mediaType, "/*") {
+		return strings.HasPrefix(offered, strings.TrimSuffix(mediaType, "*"))
+	}
+	if _, ok := offer.(msgpackEncoder); ok {
+		for _, alias := range msgpackAliases {
+			if mediaType == alias {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// respond writes v with the negotiated encoder.
+func respond(w http.ResponseWriter, enc encoder, status int, v interface{}) {
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, v); err != nil {
+		log.Printf("encoding %s response: %v", enc.ContentType(), err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", enc.ContentType())
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
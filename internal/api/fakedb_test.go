@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/google/uuid"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeDB stands in for Postgres in handler tests. It answers GetUser from
+// users and fails every other query, with the error in errs under the
+// query's name if there is one, so tests only get as far as the code they
+// mean to exercise.
+type fakeDB struct {
+	mu    sync.Mutex
+	users map[uuid.UUID]database.User
+	errs  map[string]error
+}
+
+var errFakeQuery = errors.New("fake database: query not supported")
+
+func newFakeDB(users ...database.User) *fakeDB {
+	db := &fakeDB{users: map[uuid.UUID]database.User{}, errs: map[string]error{}}
+	for _, user := range users {
+		db.users[user.ID] = user
+	}
+	return db
+}
+
+// open returns db as a *sql.DB and the queries over it, for ApiConfig.
+func (db *fakeDB) open() (*sql.DB, *database.Queries) {
+	conn := sql.OpenDB(db)
+	return conn, database.New(conn)
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return nil, errFakeQuery }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) { return nil, s.err() }
+
+// err is the error the query fails with.
+func (s fakeStmt) err() error {
+	name, _, _ := strings.Cut(strings.TrimPrefix(s.query, "-- name: "), " ")
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if err, ok := s.db.errs[name]; ok {
+		return err
+	}
+	return errFakeQuery
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if !strings.HasPrefix(s.query, "-- name: GetUser :one") {
+		return nil, s.err()
+	}
+
+	id, _ := uuid.Parse(args[0].(string))
+	s.db.mu.Lock()
+	user, ok := s.db.users[id]
+	s.db.mu.Unlock()
+	rows := &fakeRows{columns: []string{"id", "email", "created_at", "updated_at", "hashed_password",
+		"is_chirpy_red", "suspended_at", "email_verified_at", "handle", "display_name", "bio", "website",
+		"avatar", "banner"}}
+	if ok {
+		rows.values = [][]driver.Value{{user.ID.String(), user.Email, user.CreatedAt, user.UpdatedAt,
+			user.HashedPassword, user.IsChirpyRed, nullTime(user.SuspendedAt), nullTime(user.EmailVerifiedAt),
+			nil, user.DisplayName, user.Bio, user.Website, "{}", "{}"}}
+	}
+	return rows, nil
+}
+
+func nullTime(t sql.NullTime) driver.Value {
+	if !t.Valid {
+		return nil
+	}
+	return t.Time
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// testUser is a verified user in good standing.
+func testUser() database.User {
+	now := time.Now()
+	return database.User{
+		ID:              uuid.New(),
+		Email:           "user@example.com",
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		EmailVerifiedAt: sql.NullTime{Time: now, Valid: true},
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+// chirpFilter is what a signed in caller has asked not to see in chirp
+// lists. The zero value, used for anonymous callers, lets everything through.
+type chirpFilter struct {
+	viewer  uuid.UUID
+	authors map[uuid.UUID]bool
+	hide    *contentMatcher
+	mark    *contentMatcher
+}
+
+// loadChirpFilter returns the filter of the caller, if the request carries
+// a JWT: chirps by users they muted or blocked, and chirps matching their
+// content filters, are left out or marked.
+func loadChirpFilter(r *http.Request, config *types.ApiConfig) (chirpFilter, error) {
+	if r.Header.Get("Authorization") == "" {
+		return chirpFilter{}, nil
+	}
+
+	viewer, err := authenticate(r, config)
+	if err != nil {
+		return chirpFilter{}, fmt.Errorf("%w: %v", errUnauthenticated, err)
+	}
+
+	ids, err := config.Db.GetHiddenAuthorIDs(r.Context(), viewer)
+	if err != nil {
+		return chirpFilter{}, err
+	}
+	filters, err := config.Db.GetUserFilters(r.Context(), viewer)
+	if err != nil {
+		return chirpFilter{}, err
+	}
+
+	hide, mark := activeFilters(filters, time.Now())
+	filter := chirpFilter{
+		viewer:  viewer,
+		authors: map[uuid.UUID]bool{},
+		hide:    newContentMatcher(hide),
+		mark:    newContentMatcher(mark),
+	}
+	for _, id := range ids {
+		filter.authors[id] = true
+	}
+	return filter, nil
+}
+
+func (f chirpFilter) personal() bool {
+	return f.viewer != uuid.Nil
+}
+
+// apply returns the chirps that pass the filter, reusing the slice, and the
+// filters that matched the chirps to be marked, by chirp id.
+func (f chirpFilter) apply(chirps []database.Chirp) ([]database.Chirp, map[uuid.UUID]*filteredBody) {
+	if len(f.authors) == 0 && f.hide == nil && f.mark == nil {
+		return chirps, nil
+	}
+
+	kept := chirps[:0]
+	marks := map[uuid.UUID]*filteredBody{}
+	for _, chirp := range chirps {
+		if f.authors[chirp.UserID] || f.hide.match(chirp.Body) != nil {
+			continue
+		}
+		if matched := f.mark.match(chirp.Body); matched != nil {
+			marks[chirp.ID] = newFilteredBody(matched)
+		}
+		kept = append(kept, chirp)
+	}
+	return kept, marks
+}
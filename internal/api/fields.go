@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"net/url"
+	"strings"
+)
+
+// authorBody is the public view of a user embedded in chirps. It never
+// carries the email address.
+type authorBody struct {
+	Id          string `json:"id"`
+	Handle      string `json:"handle,omitempty"`
+	DisplayName string `json:"display_name"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+	ChirpyRed   bool   `json:"is_chirpy_red"`
+}
+
+// chirpFields lists the chirpsBody fields a client may ask for with fields=.
+var chirpFields = []string{"id", "body", "user_id", "created_at", "updated_at"}
+
+// chirpsView describes how chirps should be rendered for a request.
+type chirpsView struct {
+	fields       []string
+	expandAuthor bool
+}
+
+func parseChirpsView(query url.Values) (chirpsView, error) {
+	view := chirpsView{}
+
+	if raw := query.Get("fields"); raw != "" {
+		for _, field := range strings.Split(raw, ",") {
+			field = strings.TrimSpace(field)
+			if !isChirpField(field) {
+				return view, fmt.Errorf("unknown field %q", field)
+			}
+			view.fields = append(view.fields, field)
+		}
+	}
+
+	if raw := query.Get("expand"); raw != "" {
+		for _, name := range strings.Split(raw, ",") {
+			name = strings.TrimSpace(name)
+			if name != "author" {
+				return view, fmt.Errorf("cannot expand %q", name)
+			}
+			view.expandAuthor = true
+		}
+	}
+
+	return view, nil
+}
+
+func isChirpField(field string) bool {
+	for _, f := range chirpFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func newChirpsBody(chirp database.Chirp) chirpsBody {
+	return chirpsBody{
+		Id:        chirp.ID.String(),
+		Body:      chirp.Body,
+		UserId:    chirp.UserID.String(),
+		CreatedAt: chirp.CreatedAt.String(),
+		UpdatedAt: chirp.UpdatedAt.String(),
+	}
+}
+
+func newAuthorBody(user database.User) *authorBody {
+	return &authorBody{
+		Id:          user.ID.String(),
+		Handle:      user.Handle.String,
+		DisplayName: user.DisplayName,
+		CreatedAt:   user.CreatedAt.String(),
+		UpdatedAt:   user.UpdatedAt.String(),
+		ChirpyRed:   user.IsChirpyRed,
+	}
+}
+
+// loadAuthors fetches the authors of the given chirps with a single query.
+func loadAuthors(ctx context.Context, config *types.ApiConfig, chirps []database.Chirp) (map[uuid.UUID]database.User, error) {
+	seen := make(map[uuid.UUID]bool)
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		if !seen[chirp.UserID] {
+			seen[chirp.UserID] = true
+			ids = append(ids, chirp.UserID)
+		}
+	}
+
+	authors := make(map[uuid.UUID]database.User, len(ids))
+	if len(ids) == 0 {
+		return authors, nil
+	}
+
+	users, err := config.Db.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		authors[user.ID] = user
+	}
+
+	return authors, nil
+}
+
+// viewAuthors loads the authors needed by the view, if any.
+func viewAuthors(ctx context.Context, config *types.ApiConfig, chirps []database.Chirp, view chirpsView) (map[uuid.UUID]database.User, error) {
+	if !view.expandAuthor {
+		return nil, nil
+	}
+
+	return loadAuthors(ctx, config, chirps)
+}
+
+// renderChirps builds the response items for chirps according to the view.
+// Items are chirpsBody values unless a sparse fieldset was requested, in
+// which case they are records holding only the selected fields. marks holds
+// the filters that matched chirps, by id.
+func renderChirps(chirps []database.Chirp, authors map[uuid.UUID]database.User, marks map[uuid.UUID]*filteredBody, view chirpsView) []interface{} {
+	items := make([]interface{}, len(chirps))
+	for i, chirp := range chirps {
+		body := newChirpsBody(chirp)
+		if author, ok := authors[chirp.UserID]; ok {
+			body.Author = newAuthorBody(author)
+		}
+		body.Filtered = marks[chirp.ID]
+
+		if len(view.fields) == 0 {
+			items[i] = body
+			continue
+		}
+		items[i] = selectFields(body, view.fields)
+	}
+
+	return items
+}
+
+func selectFields(body chirpsBody, fields []string) record {
+	selected := record{}
+	for _, field := range fields {
+		switch field {
+		case "id":
+			selected.set(field, body.Id)
+		case "body":
+			selected.set(field, body.Body)
+		case "user_id":
+			selected.set(field, body.UserId)
+		case "created_at":
+			selected.set(field, body.CreatedAt)
+		case "updated_at":
+			selected.set(field, body.UpdatedAt)
+		}
+	}
+	if body.Author != nil {
+		selected.set("author", body.Author)
+	}
+	if body.Filtered != nil {
+		selected.set("filtered", body.Filtered)
+	}
+
+	return selected
+}
@@ -0,0 +1,382 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/types"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kinds of content filter.
+const (
+	filterKeyword = "keyword"
+	filterHashtag = "hashtag"
+	filterRegex   = "regex"
+)
+
+// What a content filter does to a matching chirp.
+const (
+	filterHide = "hide"
+	filterMark = "mark"
+)
+
+const (
+	// maxUserFilters caps how many filters one user may have.
+	maxUserFilters = 100
+	// maxFilterValue caps the length of a keyword, hashtag or regex.
+	maxFilterValue = 200
+)
+
+var hashtagPattern = regexp.MustCompile(`^\w+$`)
+
+// filterBody is a content filter as the API shows it.
+type filterBody struct {
+	Id        string `json:"id"`
+	Kind      string `json:"kind"`
+	Value     string `json:"value"`
+	Action    string `json:"action"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+func newFilterBody(filter database.UserFilter) filterBody {
+	body := filterBody{
+		Id:        filter.ID.String(),
+		Kind:      filter.Kind,
+		Value:     filter.Value,
+		Action:    filter.Action,
+		CreatedAt: filter.CreatedAt.String(),
+	}
+	if filter.ExpiresAt.Valid {
+		body.ExpiresAt = filter.ExpiresAt.Time.Format(time.RFC3339)
+	}
+	return body
+}
+
+// filteredBody is attached to chirps that matched a "mark" filter.
+type filteredBody struct {
+	FilterId string `json:"filter_id"`
+	Kind     string `json:"kind"`
+	Value    string `json:"value"`
+}
+
+// normalizeFilter checks a new filter and returns its value in the form
+// the matcher compares against.
+func normalizeFilter(kind string, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", errors.New("Filter value is empty")
+	}
+	if len(value) > maxFilterValue {
+		return "", fmt.Errorf("Filter value is longer than %d characters", maxFilterValue)
+	}
+
+	switch kind {
+	case filterKeyword:
+		return strings.ToLower(value), nil
+	case filterHashtag:
+		value = strings.ToLower(strings.TrimPrefix(value, "#"))
+		if !hashtagPattern.MatchString(value) {
+			return "", errors.New("Hashtags may only contain letters, digits and underscores")
+		}
+		return value, nil
+	case filterRegex:
+		if _, err := regexp.Compile("(?i)" + value); err != nil {
+			return "", fmt.Errorf("Invalid regex: %v", err)
+		}
+		return value, nil
+	}
+	return "", errors.New("Kind must be keyword, hashtag or regex")
+}
+
+// contentMatcher finds the first of a set of filters a chirp matches. Words
+// and hashtags are looked up in maps, and all regexes are tried as one, so
+// the cost per chirp barely grows with the number of filters.
+type contentMatcher struct {
+	words    map[string]*database.UserFilter
+	phrases  []*database.UserFilter
+	hashtags map[string]*database.UserFilter
+	regex    *regexp.Regexp
+	regexes  []*regexp.Regexp
+	patterns []*database.UserFilter
+}
+
+func newContentMatcher(filters []*database.UserFilter) *contentMatcher {
+	if len(filters) == 0 {
+		return nil
+	}
+
+	m := &contentMatcher{
+		words:    map[string]*database.UserFilter{},
+		hashtags: map[string]*database.UserFilter{},
+	}
+	var alternatives []string
+	for _, filter := range filters {
+		switch filter.Kind {
+		case filterKeyword:
+			if words := splitWords(filter.Value); len(words) == 1 && words[0] == filter.Value && !strings.Contains(filter.Value, "#") {
+				m.words[filter.Value] = filter
+			} else {
+				m.phrases = append(m.phrases, filter)
+			}
+		case filterHashtag:
+			m.hashtags[filter.Value] = filter
+		case filterRegex:
+			re, err := regexp.Compile("(?i)" + filter.Value)
+			if err != nil {
+				continue
+			}
+			m.regexes = append(m.regexes, re)
+			m.patterns = append(m.patterns, filter)
+			alternatives = append(alternatives, "(?:"+filter.Value+")")
+		}
+	}
+	if len(alternatives) > 0 {
+		m.regex = regexp.MustCompile("(?i)" + strings.Join(alternatives, "|"))
+	}
+
+	return m
+}
+
+// splitWords breaks text into lowercase words, keeping a leading # so
+// hashtags can be told apart.
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isWordRune(r) && r != '#'
+	})
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// containsWords reports whether phrase occurs in text without running into
+// the words around it, so that phrases, like single words, only match whole
+// words: "red car" is in "a red car!" but not in "bored cartoons".
+func containsWords(text string, phrase string) bool {
+	first, _ := utf8.DecodeRuneInString(phrase)
+	last, _ := utf8.DecodeLastRuneInString(phrase)
+	for offset := 0; offset < len(text); {
+		i := strings.Index(text[offset:], phrase)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(phrase)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !(isWordRune(first) && start > 0 && isWordRune(before)) &&
+			!(isWordRune(last) && end < len(text) && isWordRune(after)) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		offset = start + size
+	}
+	return false
+}
+
+// match returns the filter body matches, or nil.
+func (m *contentMatcher) match(body string) *database.UserFilter {
+	if m == nil {
+		return nil
+	}
+
+	for _, word := range splitWords(body) {
+		if tag, ok := strings.CutPrefix(word, "#"); ok {
+			if filter, ok := m.hashtags[strings.Trim(tag, "#")]; ok {
+				return filter
+			}
+		}
+		if filter, ok := m.words[strings.Trim(word, "#")]; ok {
+			return filter
+		}
+	}
+
+	if len(m.phrases) > 0 {
+		lower := strings.ToLower(body)
+		for _, filter := range m.phrases {
+			if containsWords(lower, filter.Value) {
+				return filter
+			}
+		}
+	}
+
+	if m.regex != nil && m.regex.MatchString(body) {
+		for i, re := range m.regexes {
+			if re.MatchString(body) {
+				return m.patterns[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+// MyFilters lists the caller's content filters, expired ones included.
+func MyFilters(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	filters, err := config.Db.GetUserFilters(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resp := []filterBody{}
+	for _, filter := range filters {
+		resp = append(resp, newFilterBody(filter))
+	}
+	respond(w, enc, http.StatusOK, resp)
+}
+
+// CreateFilter adds a content filter for the caller. Chirps matching it are
+// left out of their chirp lists, or marked as filtered when action is
+// "mark". A filter with expires_at stops applying at that time.
+func CreateFilter(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Kind      string     `json:"kind"`
+		Value     string     `json:"value"`
+		Action    string     `json:"action"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if bodyData.Action == "" {
+		bodyData.Action = filterHide
+	}
+	value, err := normalizeFilter(bodyData.Kind, bodyData.Value)
+	if err == nil && bodyData.Action != filterHide && bodyData.Action != filterMark {
+		err = errors.New("Action must be hide or mark")
+	}
+	if err == nil && bodyData.ExpiresAt != nil && !bodyData.ExpiresAt.After(time.Now()) {
+		err = errors.New("expires_at is in the past")
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	count, err := config.Db.CountUserFilters(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if count >= maxUserFilters {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(fmt.Sprintf("Users may have at most %d filters", maxUserFilters)))
+		return
+	}
+
+	params := database.CreateUserFilterParams{
+		UserID: userID,
+		Kind:   bodyData.Kind,
+		Value:  value,
+		Action: bodyData.Action,
+	}
+	if bodyData.ExpiresAt != nil {
+		params.ExpiresAt.Time = bodyData.ExpiresAt.UTC()
+		params.ExpiresAt.Valid = true
+	}
+	filter, err := config.Db.CreateUserFilter(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusCreated, newFilterBody(filter))
+}
+
+func DeleteFilter(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	filterID, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := config.Db.DeleteUserFilter(r.Context(), database.DeleteUserFilterParams{
+		ID:     filterID,
+		UserID: userID,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("Filter not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// activeFilters splits the filters still in force into those that hide
+// chirps and those that mark them.
+func activeFilters(filters []database.UserFilter, now time.Time) (hide []*database.UserFilter, mark []*database.UserFilter) {
+	for i := range filters {
+		filter := &filters[i]
+		if filter.ExpiresAt.Valid && !filter.ExpiresAt.Time.After(now) {
+			continue
+		}
+		if filter.Action == filterMark {
+			mark = append(mark, filter)
+		} else {
+			hide = append(hide, filter)
+		}
+	}
+	return hide, mark
+}
+
+func newFilteredBody(filter *database.UserFilter) *filteredBody {
+	return &filteredBody{
+		FilterId: filter.ID.String(),
+		Kind:     filter.Kind,
+		Value:    filter.Value,
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"github.com/dabates/httpServer/internal/database"
+	"testing"
+)
+
+func testMatcher(t *testing.T, filters ...database.UserFilter) *contentMatcher {
+	t.Helper()
+	ptrs := make([]*database.UserFilter, len(filters))
+	for i := range filters {
+		value, err := normalizeFilter(filters[i].Kind, filters[i].Value)
+		if err != nil {
+			t.Fatalf("normalizeFilter(%q, %q) failed: %v", filters[i].Kind, filters[i].Value, err)
+		}
+		filters[i].Value = value
+		ptrs[i] = &filters[i]
+	}
+	return newContentMatcher(ptrs)
+}
+
+func TestContentMatcherMatch(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter database.UserFilter
+		body   string
+		want   bool
+	}{
+		{"word", database.UserFilter{Kind: filterKeyword, Value: "Spoiler"}, "Big SPOILER ahead", true},
+		{"word inside another", database.UserFilter{Kind: filterKeyword, Value: "cat"}, "concatenate these", false},
+		{"word as hashtag", database.UserFilter{Kind: filterKeyword, Value: "cat"}, "look #cat", true},
+		{"phrase", database.UserFilter{Kind: filterKeyword, Value: "red car"}, "a Red car!", true},
+		// Phrases match whole words, as single words do.
+		{"phrase inside words", database.UserFilter{Kind: filterKeyword, Value: "red car"}, "bored cartoons", false},
+		{"phrase at a later occurrence", database.UserFilter{Kind: filterKeyword, Value: "red car"}, "bored cart, red car", true},
+		{"punctuation", database.UserFilter{Kind: filterKeyword, Value: "c++"}, "I write C++ daily", true},
+		{"punctuation inside a word", database.UserFilter{Kind: filterKeyword, Value: "c++"}, "abc++", false},
+		{"hashtag", database.UserFilter{Kind: filterHashtag, Value: "#Golang"}, "hello #golang", true},
+		{"hashtag as word", database.UserFilter{Kind: filterHashtag, Value: "golang"}, "hello golang", false},
+		{"regex", database.UserFilter{Kind: filterRegex, Value: `\bfoo\d+`}, "FOO42 is here", true},
+		{"regex miss", database.UserFilter{Kind: filterRegex, Value: `\bfoo\d+`}, "foo bar", false},
+	}
+	for _, c := range cases {
+		m := testMatcher(t, c.filter)
+		if got := m.match(c.body) != nil; got != c.want {
+			t.Errorf("%s: match(%q) = %v, want %v", c.name, c.body, got, c.want)
+		}
+	}
+}
+
+func TestContentMatcherReturnsTheMatchingFilter(t *testing.T) {
+	m := testMatcher(t,
+		database.UserFilter{Kind: filterKeyword, Value: "alpha"},
+		database.UserFilter{Kind: filterRegex, Value: "be+ta"},
+		database.UserFilter{Kind: filterRegex, Value: "gam+a"},
+	)
+	for body, want := range map[string]string{"x alpha": "alpha", "beeeta": "be+ta", "gammma": "gam+a"} {
+		got := m.match(body)
+		if got == nil || got.Value != want {
+			t.Errorf("match(%q) = %v, want the %q filter", body, got, want)
+		}
+	}
+	if got := m.match("nothing here"); got != nil {
+		t.Errorf("Expected no match, got %q", got.Value)
+	}
+
+	var none *contentMatcher
+	if none.match("alpha") != nil {
+		t.Error("Expected a nil matcher to match nothing")
+	}
+}
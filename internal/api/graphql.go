@@ -0,0 +1,639 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Limits applied to every GraphQL operation before it runs.
+const (
+	graphqlMaxDepth      = 8
+	graphqlMaxComplexity = 1000
+)
+
+var (
+	errUnauthenticated = errors.New("authentication required")
+	errInvalidFirst    = fmt.Errorf("first must be between 1 and %d", maxPageSize)
+)
+
+type graphqlContextKey struct{}
+
+// graphqlRequest is the per-request state shared by the resolvers.
+type graphqlRequest struct {
+	config *types.ApiConfig
+	// userID is uuid.Nil for anonymous requests. authErr holds the reason
+	// when a token was sent but did not validate.
+	userID  uuid.UUID
+	authErr error
+	users   *userLoader
+}
+
+func graphqlRequestFrom(ctx context.Context) *graphqlRequest {
+	return ctx.Value(graphqlContextKey{}).(*graphqlRequest)
+}
+
+func (g *graphqlRequest) requireUser() (uuid.UUID, error) {
+	if g.authErr != nil {
+		return uuid.Nil, g.authErr
+	}
+	if g.userID == uuid.Nil {
+		return uuid.Nil, errUnauthenticated
+	}
+	return g.userID, nil
+}
+
+// userLoader batches user lookups made while resolving one level of a query
+// into a single GetUsersByIDs call, dataloader style. Resolvers call load and
+// return the thunk; graphql-go runs the thunks only after every sibling has
+// queued its id.
+type userLoader struct {
+	ctx context.Context
+	db  *database.Queries
+
+	mu      sync.Mutex
+	pending []uuid.UUID
+	queued  map[uuid.UUID]bool
+	cache   map[uuid.UUID]*database.User
+}
+
+func newUserLoader(ctx context.Context, db *database.Queries) *userLoader {
+	return &userLoader{
+		ctx:    ctx,
+		db:     db,
+		queued: map[uuid.UUID]bool{},
+		cache:  map[uuid.UUID]*database.User{},
+	}
+}
+
+func (l *userLoader) load(id uuid.UUID) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, ok := l.cache[id]; !ok && !l.queued[id] {
+		l.queued[id] = true
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		if err := l.flush(); err != nil {
+			return nil, err
+		}
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if user := l.cache[id]; user != nil {
+			return *user, nil
+		}
+		return nil, nil
+	}
+}
+
+func (l *userLoader) flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.pending) == 0 {
+		return nil
+	}
+	ids := l.pending
+	l.pending = nil
+
+	users, err := l.db.GetUsersByIDs(l.ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		delete(l.queued, id)
+		l.cache[id] = nil
+	}
+	for i := range users {
+		l.cache[users[i].ID] = &users[i]
+	}
+
+	return nil
+}
+
+func parseIDArg(args map[string]interface{}, name string) (uuid.UUID, error) {
+	raw, _ := args[name].(string)
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	return id, nil
+}
+
+func pageArgs(args map[string]interface{}) (int, string) {
+	first := defaultPageSize
+	if v, ok := args["first"].(int); ok {
+		first = v
+	}
+	after, _ := args["after"].(string)
+
+	return first, after
+}
+
+var (
+	graphqlSchema    graphql.Schema
+	graphqlSchemaErr error
+	graphqlOnce      sync.Once
+)
+
+func getGraphqlSchema() (graphql.Schema, error) {
+	graphqlOnce.Do(func() {
+		graphqlSchema, graphqlSchemaErr = newGraphqlSchema()
+	})
+	return graphqlSchema, graphqlSchemaErr
+}
+
+func newGraphqlSchema() (graphql.Schema, error) {
+	var userType, chirpType, connectionType *graphql.Object
+
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(chirpPage).hasNextPage, nil
+				},
+			},
+			"endCursor": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					conn := p.Source.(chirpPage)
+					if len(conn.chirps) == 0 {
+						return nil, nil
+					}
+					return encodeCursor(conn.chirps[len(conn.chirps)-1]), nil
+				},
+			},
+		},
+	})
+
+	pageArgsConfig := graphql.FieldConfigArgument{
+		"first": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+		"after": &graphql.ArgumentConfig{Type: graphql.String},
+	}
+
+	userType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.ID),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(database.User).ID.String(), nil
+					},
+				},
+				"createdAt": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(database.User).CreatedAt.String(), nil
+					},
+				},
+				"updatedAt": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(database.User).UpdatedAt.String(), nil
+					},
+				},
+				"isChirpyRed": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.Boolean),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(database.User).IsChirpyRed, nil
+					},
+				},
+				"email": &graphql.Field{
+					Type:        graphql.String,
+					Description: "Only visible to the user themselves.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						user := p.Source.(database.User)
+						if graphqlRequestFrom(p.Context).userID != user.ID {
+							return nil, nil
+						}
+						return user.Email, nil
+					},
+				},
+				"chirps": &graphql.Field{
+					Type: graphql.NewNonNull(connectionType),
+					Args: pageArgsConfig,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						user := p.Source.(database.User)
+						first, after := pageArgs(p.Args)
+						return chirpsPage(p.Context, graphqlRequestFrom(p.Context).config, uuid.NullUUID{UUID: user.ID, Valid: true}, first, after)
+					},
+				},
+			}
+		}),
+	})
+
+	chirpType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Chirp",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(database.Chirp).ID.String(), nil
+				},
+			},
+			"body": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(database.Chirp).Body, nil
+				},
+			},
+			"userId": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(database.Chirp).UserID.String(), nil
+				},
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(database.Chirp).CreatedAt.String(), nil
+				},
+			},
+			"updatedAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(database.Chirp).UpdatedAt.String(), nil
+				},
+			},
+			"author": &graphql.Field{
+				Type: userType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					chirp := p.Source.(database.Chirp)
+					return graphqlRequestFrom(p.Context).users.load(chirp.UserID), nil
+				},
+			},
+		},
+	})
+
+	edgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ChirpEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return encodeCursor(p.Source.(database.Chirp)), nil
+				},
+			},
+			"node": &graphql.Field{
+				Type: graphql.NewNonNull(chirpType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				},
+			},
+		},
+	})
+
+	connectionType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "ChirpConnection",
+		Fields: graphql.Fields{
+			"edges": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(chirpPage).chirps, nil
+				},
+			},
+			"pageInfo": &graphql.Field{
+				Type: graphql.NewNonNull(pageInfoType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				},
+			},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"chirp": &graphql.Field{
+				Type: chirpType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := parseIDArg(p.Args, "id")
+					if err != nil {
+						return nil, err
+					}
+					chirp, err := graphqlRequestFrom(p.Context).config.Db.GetVisibleChirp(p.Context, id)
+					if errors.Is(err, sql.ErrNoRows) {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, err
+					}
+					return chirp, nil
+				},
+			},
+			"chirps": &graphql.Field{
+				Type: graphql.NewNonNull(connectionType),
+				Args: graphql.FieldConfigArgument{
+					"first":    pageArgsConfig["first"],
+					"after":    pageArgsConfig["after"],
+					"authorId": &graphql.ArgumentConfig{Type: graphql.ID},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					authorID := uuid.NullUUID{}
+					if _, ok := p.Args["authorId"]; ok {
+						id, err := parseIDArg(p.Args, "authorId")
+						if err != nil {
+							return nil, err
+						}
+						authorID = uuid.NullUUID{UUID: id, Valid: true}
+					}
+					first, after := pageArgs(p.Args)
+					return chirpsPage(p.Context, graphqlRequestFrom(p.Context).config, authorID, first, after)
+				},
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := parseIDArg(p.Args, "id")
+					if err != nil {
+						return nil, err
+					}
+					return graphqlRequestFrom(p.Context).users.load(id), nil
+				},
+			},
+			"me": &graphql.Field{
+				Type: userType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					req := graphqlRequestFrom(p.Context)
+					userID, err := req.requireUser()
+					if err != nil {
+						return nil, err
+					}
+					return req.users.load(userID), nil
+				},
+			},
+		},
+	})
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createChirp": &graphql.Field{
+				Type: graphql.NewNonNull(chirpType),
+				Args: graphql.FieldConfigArgument{
+					"body": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					req := graphqlRequestFrom(p.Context)
+					userID, err := req.requireUser()
+					if err != nil {
+						return nil, err
+					}
+					body, _ := p.Args["body"].(string)
+					return createChirp(p.Context, req.config, userID, body)
+				},
+			},
+			"deleteChirp": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					req := graphqlRequestFrom(p.Context)
+					userID, err := req.requireUser()
+					if err != nil {
+						return nil, err
+					}
+					id, err := parseIDArg(p.Args, "id")
+					if err != nil {
+						return nil, err
+					}
+					if err := deleteChirp(p.Context, req.config, userID, id); err != nil {
+						return nil, err
+					}
+					return true, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    queryType,
+		Mutation: mutationType,
+	})
+}
+
+// queryCost measures the depth and complexity of a selection set. Each field
+// costs one, and the cost of a paginated field's children is multiplied by
+// the page size it asks for. Introspection fields are free.
+type queryCost struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// paginatedFields default to defaultPageSize items when first is omitted.
+var paginatedFields = map[string]bool{"chirps": true}
+
+func (qc queryCost) measure(set *ast.SelectionSet, depth int, visiting map[string]bool) (int, int, error) {
+	if set == nil {
+		return depth - 1, 0, nil
+	}
+
+	maxDepth, cost := depth, 0
+	for _, selection := range set.Selections {
+		var d, c int
+		var err error
+		switch sel := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name.Value, "__") {
+				continue
+			}
+			d, c, err = qc.measure(sel.SelectionSet, depth+1, visiting)
+			if err != nil {
+				return 0, 0, err
+			}
+			n, err := qc.multiplier(sel)
+			if err != nil {
+				return 0, 0, err
+			}
+			c = 1 + n*c
+		case *ast.InlineFragment:
+			d, c, err = qc.measure(sel.SelectionSet, depth, visiting)
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			fragment, ok := qc.fragments[name]
+			if !ok || visiting[name] {
+				continue
+			}
+			visiting[name] = true
+			d, c, err = qc.measure(fragment.SelectionSet, depth, visiting)
+			delete(visiting, name)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if d > maxDepth {
+			maxDepth = d
+		}
+		// Past the limit the exact cost no longer matters, and stopping
+		// there keeps deep nesting from overflowing it.
+		cost = min(cost+c, graphqlMaxComplexity+1)
+	}
+
+	return maxDepth, cost, nil
+}
+
+// multiplier is the page size a field asks for, or 1 for fields that are
+// not lists. A first outside 1..maxPageSize is an error, as chirpsPage
+// would refuse it anyway.
+func (qc queryCost) multiplier(field *ast.Field) (int, error) {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		n, given := 0, false
+		switch value := arg.Value.(type) {
+		case *ast.IntValue:
+			parsed, err := strconv.Atoi(value.Value)
+			if err != nil {
+				return 0, errInvalidFirst
+			}
+			n, given = parsed, true
+		case *ast.Variable:
+			raw, ok := qc.variables[value.Name.Value]
+			if !ok || raw == nil {
+				break
+			}
+			f, ok := raw.(float64)
+			if !ok || f != math.Trunc(f) || math.Abs(f) > math.MaxInt32 {
+				return 0, errInvalidFirst
+			}
+			n, given = int(f), true
+		}
+		if given {
+			if n < 1 || n > maxPageSize {
+				return 0, errInvalidFirst
+			}
+			return n, nil
+		}
+	}
+
+	if paginatedFields[field.Name.Value] {
+		return defaultPageSize, nil
+	}
+	return 1, nil
+}
+
+// checkQueryLimits rejects operations that nest too deeply or would fan out
+// into too many rows.
+func checkQueryLimits(doc *ast.Document, variables map[string]interface{}) error {
+	qc := queryCost{
+		fragments: map[string]*ast.FragmentDefinition{},
+		variables: variables,
+	}
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			qc.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		depth, cost, err := qc.measure(op.SelectionSet, 1, map[string]bool{})
+		if err != nil {
+			return err
+		}
+		if depth > graphqlMaxDepth {
+			return fmt.Errorf("query depth %d exceeds the limit of %d", depth, graphqlMaxDepth)
+		}
+		if cost > graphqlMaxComplexity {
+			return fmt.Errorf("query complexity %d exceeds the limit of %d", cost, graphqlMaxComplexity)
+		}
+	}
+
+	return nil
+}
+
+func GraphQL(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+
+	bodyData := reqBody{}
+	err := json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	schema, err := getGraphqlSchema()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(bodyData.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		respond(w, jsonEncoder{}, http.StatusBadRequest, &graphql.Result{
+			Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)},
+		})
+		return
+	}
+
+	if err := checkQueryLimits(doc, bodyData.Variables); err != nil {
+		respond(w, jsonEncoder{}, http.StatusBadRequest, &graphql.Result{
+			Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(err.Error())},
+		})
+		return
+	}
+
+	validation := graphql.ValidateDocument(&schema, doc, nil)
+	if !validation.IsValid {
+		respond(w, jsonEncoder{}, http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+
+	req := &graphqlRequest{
+		config: config,
+		users:  newUserLoader(r.Context(), config.Db),
+	}
+	if r.Header.Get("Authorization") != "" {
+		req.userID, req.authErr = authenticate(r, config)
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        schema,
+		AST:           doc,
+		OperationName: bodyData.OperationName,
+		Args:          bodyData.Variables,
+		Context:       context.WithValue(r.Context(), graphqlContextKey{}, req),
+	})
+
+	respond(w, jsonEncoder{}, http.StatusOK, result)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"errors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"testing"
+)
+
+func checkQuery(t *testing.T, query string, variables map[string]interface{}) error {
+	t.Helper()
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(query),
+	})})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return checkQueryLimits(doc, variables)
+}
+
+func TestQueryLimitsRejectFirstOutOfRange(t *testing.T) {
+	// Without the check, the negative alias would cancel out the cost of
+	// the expensive one next to it.
+	for _, first := range []string{"-100000", "0", "101", "99999999999999999999"} {
+		query := `{
+			a: chirps(first: ` + first + `) { edges { id } }
+			b: chirps(first: 100) { edges { author { chirps(first: 100) { edges { id } } } } }
+		}`
+		if err := checkQuery(t, query, nil); !errors.Is(err, errInvalidFirst) {
+			t.Errorf("first: %s: expected errInvalidFirst, got %v", first, err)
+		}
+	}
+}
+
+func TestQueryLimitsRejectFirstVariablesOutOfRange(t *testing.T) {
+	query := `query($n: Int) { chirps(first: $n) { edges { id } } }`
+	for _, n := range []float64{-100000, 0, 101, 1e300, 2.5} {
+		if err := checkQuery(t, query, map[string]interface{}{"n": n}); !errors.Is(err, errInvalidFirst) {
+			t.Errorf("$n = %v: expected errInvalidFirst, got %v", n, err)
+		}
+	}
+	if err := checkQuery(t, query, map[string]interface{}{"n": float64(50)}); err != nil {
+		t.Errorf("$n = 50: expected the query to pass, got %v", err)
+	}
+}
+
+func TestQueryLimitsCountPageSizes(t *testing.T) {
+	cheap := `{ chirps(first: 5) { edges { id } } }`
+	if err := checkQuery(t, cheap, nil); err != nil {
+		t.Fatalf("Expected a cheap query to pass, got %v", err)
+	}
+
+	expensive := `{ chirps(first: 100) { edges { author { chirps(first: 100) { edges { id } } } } } }`
+	if err := checkQuery(t, expensive, nil); err == nil || errors.Is(err, errInvalidFirst) {
+		t.Fatalf("Expected the complexity limit to refuse the query, got %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/dabates/httpServer/internal/auth"
+	"github.com/dabates/httpServer/internal/chirpypb"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/events"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net/http"
+)
+
+// grpcServer implements chirpypb.ChirpServiceServer on top of the same
+// helpers as the REST handlers, so both apply identical rules.
+type grpcServer struct {
+	chirpypb.UnimplementedChirpServiceServer
+	config *types.ApiConfig
+}
+
+// NewGRPCServer returns a gRPC server with the chirp service registered.
+func NewGRPCServer(config *types.ApiConfig) *grpc.Server {
+	s := grpc.NewServer()
+	chirpypb.RegisterChirpServiceServer(s, &grpcServer{config: config})
+	return s
+}
+
+// grpcAuthenticate reads the JWT from the "authorization" metadata, and
+// refuses suspended users as authenticate does.
+func grpcAuthenticate(ctx context.Context, config *types.ApiConfig) (uuid.UUID, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := http.Header{}
+	for _, value := range md.Get("authorization") {
+		header.Add("Authorization", value)
+	}
+
+	token, err := auth.GetBearerToken(header)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	userID, err := auth.ValidateJWT(token, config.Secret)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err := checkNotSuspended(ctx, config, userID); err != nil {
+		return uuid.Nil, grpcError(err)
+	}
+
+	return userID, nil
+}
+
+// grpcError converts the errors of the shared chirp helpers to gRPC statuses.
+func grpcError(err error) error {
+	switch chirpErrorStatus(err) {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case http.StatusNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case http.StatusForbidden:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case http.StatusUnauthorized:
+		return status.Error(codes.Unauthenticated, err.Error())
+	case http.StatusTooManyRequests:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func parseUUID(field string, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "invalid %s: %v", field, err)
+	}
+	return id, nil
+}
+
+func toProtoChirp(chirp database.Chirp) *chirpypb.Chirp {
+	return &chirpypb.Chirp{
+		Id:        chirp.ID.String(),
+		Body:      chirp.Body,
+		UserId:    chirp.UserID.String(),
+		CreatedAt: timestamppb.New(chirp.CreatedAt),
+		UpdatedAt: timestamppb.New(chirp.UpdatedAt),
+	}
+}
+
+func (s *grpcServer) CreateChirp(ctx context.Context, req *chirpypb.CreateChirpRequest) (*chirpypb.Chirp, error) {
+	userID, err := grpcAuthenticate(ctx, s.config)
+	if err != nil {
+		return nil, err
+	}
+
+	chirp, err := createChirp(ctx, s.config, userID, req.GetBody())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return toProtoChirp(chirp), nil
+}
+
+func (s *grpcServer) GetChirp(ctx context.Context, req *chirpypb.GetChirpRequest) (*chirpypb.Chirp, error) {
+	id, err := parseUUID("id", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	chirp, err := s.config.Db.GetVisibleChirp(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, grpcError(errChirpNotFound)
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return toProtoChirp(chirp), nil
+}
+
+func (s *grpcServer) ListChirps(ctx context.Context, req *chirpypb.ListChirpsRequest) (*chirpypb.ListChirpsResponse, error) {
+	pageSize := int(req.GetPageSize())
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+
+	authorID := uuid.NullUUID{}
+	if req.GetAuthorId() != "" {
+		id, err := parseUUID("author_id", req.GetAuthorId())
+		if err != nil {
+			return nil, err
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	page, err := chirpsPage(ctx, s.config, authorID, pageSize, req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp := &chirpypb.ListChirpsResponse{}
+	for _, chirp := range page.chirps {
+		resp.Chirps = append(resp.Chirps, toProtoChirp(chirp))
+	}
+	if page.hasNextPage {
+		resp.NextPageToken = encodeCursor(page.chirps[len(page.chirps)-1])
+	}
+
+	return resp, nil
+}
+
+func (s *grpcServer) DeleteChirp(ctx context.Context, req *chirpypb.DeleteChirpRequest) (*chirpypb.DeleteChirpResponse, error) {
+	userID, err := grpcAuthenticate(ctx, s.config)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseUUID("id", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := deleteChirp(ctx, s.config, userID, id); err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &chirpypb.DeleteChirpResponse{}, nil
+}
+
+func (s *grpcServer) WatchChirps(req *chirpypb.WatchChirpsRequest, stream grpc.ServerStreamingServer[chirpypb.ChirpEvent]) error {
+	authorID := uuid.Nil
+	if req.GetAuthorId() != "" {
+		id, err := parseUUID("author_id", req.GetAuthorId())
+		if err != nil {
+			return err
+		}
+		authorID = id
+	}
+
+	if s.config.Events == nil {
+		return status.Error(codes.Unavailable, "chirp events are not enabled")
+	}
+	updates, stop := s.config.Events.Subscribe(64)
+	defer stop()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if authorID != uuid.Nil && e.Chirp.UserID != authorID {
+				continue
+			}
+
+			event := &chirpypb.ChirpEvent{Chirp: toProtoChirp(e.Chirp)}
+			switch e.Type {
+			case events.ChirpCreated:
+				event.Type = chirpypb.ChirpEvent_TYPE_CREATED
+			case events.ChirpDeleted:
+				event.Type = chirpypb.ChirpEvent_TYPE_DELETED
+			default:
+				continue
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,472 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/events"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/dabates/httpServer/internal/webhooks"
+	"github.com/google/uuid"
+	"net/http"
+	"net/url"
+)
+
+// webhookEvents are the events users can subscribe webhooks to. Each is
+// about the webhook owner's own chirps or account.
+var webhookEvents = map[string]bool{
+	events.ChirpCreated: true,
+	events.ChirpDeleted: true,
+	events.UserUpgraded: true,
+}
+
+// maxWebhooks caps how many webhooks one user may register.
+const maxWebhooks = 10
+
+var (
+	errWebhookNotFound  = errors.New("Webhook not found")
+	errDeliveryNotFound = errors.New("Delivery not found")
+	errWebhookDisabled  = errors.New("Webhook is disabled")
+)
+
+type webhookBody struct {
+	Id           string   `json:"id"`
+	Url          string   `json:"url"`
+	Events       []string `json:"events"`
+	Enabled      bool     `json:"enabled"`
+	FailureCount int32    `json:"failure_count"`
+	DisabledAt   string   `json:"disabled_at,omitempty"`
+	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string   `json:"updated_at"`
+	// Secret is only shown when the webhook is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+func newWebhookBody(hook database.Webhook) webhookBody {
+	body := webhookBody{
+		Id:           hook.ID.String(),
+		Url:          hook.Url,
+		Events:       hook.Events,
+		Enabled:      !hook.DisabledAt.Valid,
+		FailureCount: hook.FailureCount,
+		CreatedAt:    hook.CreatedAt.String(),
+		UpdatedAt:    hook.UpdatedAt.String(),
+	}
+	if hook.DisabledAt.Valid {
+		body.DisabledAt = hook.DisabledAt.Time.String()
+	}
+	return body
+}
+
+type deliveryBody struct {
+	Id            string `json:"id"`
+	WebhookId     string `json:"webhook_id"`
+	EventId       string `json:"event_id"`
+	EventType     string `json:"event_type"`
+	Status        string `json:"status"`
+	Attempts      int32  `json:"attempts"`
+	ResponseCode  *int32 `json:"response_code,omitempty"`
+	Error         string `json:"error,omitempty"`
+	NextAttemptAt string `json:"next_attempt_at,omitempty"`
+	DeliveredAt   string `json:"delivered_at,omitempty"`
+	CreatedAt     string `json:"created_at"`
+}
+
+func newDeliveryBody(delivery database.WebhookDelivery) deliveryBody {
+	body := deliveryBody{
+		Id:        delivery.ID.String(),
+		WebhookId: delivery.WebhookID.String(),
+		EventId:   delivery.EventID.String(),
+		EventType: delivery.EventType,
+		Status:    delivery.Status,
+		Attempts:  delivery.Attempts,
+		Error:     delivery.Error.String,
+		CreatedAt: delivery.CreatedAt.String(),
+	}
+	if delivery.ResponseCode.Valid {
+		body.ResponseCode = &delivery.ResponseCode.Int32
+	}
+	if delivery.NextAttemptAt.Valid {
+		body.NextAttemptAt = delivery.NextAttemptAt.Time.String()
+	}
+	if delivery.DeliveredAt.Valid {
+		body.DeliveredAt = delivery.DeliveredAt.Time.String()
+	}
+	return body
+}
+
+// webhookRequest is the body of creating or updating a webhook.
+type webhookRequest struct {
+	Url     string   `json:"url"`
+	Events  []string `json:"events"`
+	Enabled *bool    `json:"enabled"`
+}
+
+// validate checks the URL and events, dropping repeated events.
+func (req *webhookRequest) validate() error {
+	u, err := url.Parse(req.Url)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+
+	if len(req.Events) == 0 {
+		return errors.New("Subscribe to at least one event")
+	}
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, event := range req.Events {
+		if !webhookEvents[event] {
+			return fmt.Errorf("Unknown event %q", event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			unique = append(unique, event)
+		}
+	}
+	req.Events = unique
+	return nil
+}
+
+// ownWebhook loads a webhook of userID, returning errWebhookNotFound for
+// other users' webhooks too.
+func ownWebhook(ctx context.Context, db *database.Queries, userID uuid.UUID, id uuid.UUID) (database.Webhook, error) {
+	hook, err := db.GetWebhook(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && hook.UserID != userID) {
+		return database.Webhook{}, errWebhookNotFound
+	}
+	return hook, err
+}
+
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errWebhookNotFound), errors.Is(err, errDeliveryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errWebhookDisabled):
+		return http.StatusConflict
+	}
+	return chirpErrorStatus(err)
+}
+
+// CreateWebhook registers a URL to be sent the caller's events. The response
+// carries the signing secret, which is not shown again.
+func CreateWebhook(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	bodyData := webhookRequest{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err == nil {
+		err = bodyData.validate()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	count, err := config.Db.CountWebhooksByUser(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if count >= maxWebhooks {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(fmt.Sprintf("Users may have at most %d webhooks", maxWebhooks)))
+		return
+	}
+
+	hook, err := config.Db.CreateWebhook(r.Context(), database.CreateWebhookParams{
+		UserID: userID,
+		Url:    bodyData.Url,
+		Secret: webhooks.NewSecret(),
+		Events: bodyData.Events,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resp := newWebhookBody(hook)
+	resp.Secret = hook.Secret
+	respond(w, enc, http.StatusCreated, resp)
+}
+
+// MyWebhooks lists the caller's webhooks, oldest first.
+func MyWebhooks(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	hooks, err := config.Db.GetWebhooksByUser(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resp := []webhookBody{}
+	for _, hook := range hooks {
+		resp = append(resp, newWebhookBody(hook))
+	}
+	respond(w, enc, http.StatusOK, resp)
+}
+
+func GetWebhook(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	id, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	hook, err := ownWebhook(r.Context(), config.Db, userID, id)
+	if err != nil {
+		w.WriteHeader(webhookErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusOK, newWebhookBody(hook))
+}
+
+// UpdateWebhook replaces a webhook's URL and events. enabled defaults to
+// true, so updating a webhook that was disabled after repeated failures turns
+// it back on; deliveries still pending are then retried.
+func UpdateWebhook(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	id, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	bodyData := webhookRequest{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err == nil {
+		err = bodyData.validate()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, err = ownWebhook(r.Context(), config.Db, userID, id)
+	if err != nil {
+		w.WriteHeader(webhookErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	hook, err := config.Db.UpdateWebhook(r.Context(), database.UpdateWebhookParams{
+		ID:      id,
+		Url:     bodyData.Url,
+		Events:  bodyData.Events,
+		Enabled: bodyData.Enabled == nil || *bodyData.Enabled,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusOK, newWebhookBody(hook))
+}
+
+func DeleteWebhook(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	id, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	_, err = ownWebhook(r.Context(), config.Db, userID, id)
+	if err == nil {
+		err = config.Db.DeleteWebhook(r.Context(), id)
+	}
+	if err != nil {
+		w.WriteHeader(webhookErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// WebhookDeliveries is the delivery log of a webhook, newest first.
+func WebhookDeliveries(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	id, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	limit, ok := pageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	_, err = ownWebhook(r.Context(), config.Db, userID, id)
+	if err != nil {
+		w.WriteHeader(webhookErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	deliveries, err := config.Db.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		WebhookID: id,
+		PageSize:  limit,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resp := []deliveryBody{}
+	for _, delivery := range deliveries {
+		resp = append(resp, newDeliveryBody(delivery))
+	}
+	respond(w, enc, http.StatusOK, resp)
+}
+
+// RedeliverWebhook queues a past delivery again as a new delivery with the
+// same event id and payload, so receivers can tell it is a repeat.
+func RedeliverWebhook(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	id, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(r.PathValue("delivery_id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	delivery, err := redeliver(r.Context(), config.Db, userID, id, deliveryID)
+	if err != nil {
+		w.WriteHeader(webhookErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusAccepted, newDeliveryBody(delivery))
+}
+
+func redeliver(ctx context.Context, db *database.Queries, userID uuid.UUID, id uuid.UUID, deliveryID uuid.UUID) (database.WebhookDelivery, error) {
+	hook, err := ownWebhook(ctx, db, userID, id)
+	if err != nil {
+		return database.WebhookDelivery{}, err
+	}
+	if hook.DisabledAt.Valid {
+		return database.WebhookDelivery{}, errWebhookDisabled
+	}
+
+	past, err := db.GetWebhookDelivery(ctx, deliveryID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && past.WebhookID != hook.ID) {
+		return database.WebhookDelivery{}, errDeliveryNotFound
+	}
+	if err != nil {
+		return database.WebhookDelivery{}, err
+	}
+
+	return db.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+		WebhookID: hook.ID,
+		EventID:   past.EventID,
+		EventType: past.EventType,
+		Payload:   past.Payload,
+	})
+}
+
+// enqueueWebhooks queues e for the webhooks of the user it is about. db must
+// be the transaction that makes the change e describes, so a delivery is
+// queued for every change that commits and for no other.
+func enqueueWebhooks(ctx context.Context, config *types.ApiConfig, db *database.Queries, e events.Event) error {
+	if config.Webhooks == nil {
+		return nil
+	}
+
+	var userID uuid.UUID
+	var data interface{}
+	switch e.Type {
+	case events.ChirpCreated, events.ChirpDeleted:
+		userID, data = e.Chirp.UserID, newChirpsBody(e.Chirp)
+	case events.UserUpgraded:
+		userID, data = e.User.ID, newAuthorBody(e.User)
+	default:
+		return nil
+	}
+	return config.Webhooks.Enqueue(ctx, db, userID, e.Type, data)
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"github.com/dabates/httpServer/internal/auth"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/types"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxIdempotencyKey caps the length of the Idempotency-Key header.
+	maxIdempotencyKey = 255
+	// idempotencyLease is how long a request may hold its key without
+	// recording a response. After that the request is taken to have been
+	// abandoned, say by a crash, and a retry may claim the key.
+	idempotencyLease = time.Minute
+)
+
+// WithIdempotency makes next safe to retry. A request carrying an
+// Idempotency-Key header has its response recorded, and repeats of it within
+// 24 hours get that response back instead of running next again.
+// Reusing a key for a different request is a 409.
+//
+// Keys are scoped to the caller: the user in the JWT, the API key, or for
+// anonymous requests the client address.
+// Server errors are not recorded, so such requests can be retried, and
+// neither are requests whose handler panicked or that outlived
+// idempotencyLease.
+func WithIdempotency(config *types.ApiConfig, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Idempotency-Key is too long"))
+			return
+		}
+
+		scope, ok := idempotencyScope(r, config)
+		if !ok {
+			// Let the handler turn this into its usual 401.
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(r, body)
+		claimed, err := config.Db.ClaimIdempotencyKey(r.Context(), database.ClaimIdempotencyKeyParams{
+			Scope:        scope,
+			Key:          key,
+			Fingerprint:  fingerprint,
+			LeaseSeconds: int32(idempotencyLease.Seconds()),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			replayIdempotent(w, r, config, scope, key, fingerprint)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// The outcome is recorded even if the client has gone away, so that
+		// its retry finds it.
+		ctx := context.WithoutCancel(r.Context())
+		release := func() error {
+			return config.Db.DeleteIdempotencyKey(ctx, database.DeleteIdempotencyKeyParams{
+				Scope: claimed.Scope,
+				Key:   claimed.Key,
+			})
+		}
+		finished := false
+		defer func() {
+			if finished {
+				return
+			}
+			// next panicked; let the request be retried.
+			if err := release(); err != nil {
+				log.Printf("idempotency: releasing key %q: %v", key, err)
+			}
+		}()
+
+		rec := &idempotencyRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		finished = true
+
+		if rec.status >= http.StatusInternalServerError {
+			err = release()
+		} else {
+			err = config.Db.SaveIdempotentResponse(ctx, database.SaveIdempotentResponseParams{
+				Scope:       claimed.Scope,
+				Key:         claimed.Key,
+				Status:      sql.NullInt32{Int32: int32(rec.status), Valid: true},
+				ContentType: sql.NullString{String: rec.Header().Get("Content-Type"), Valid: true},
+				Body:        rec.body.Bytes(),
+			})
+		}
+		if err != nil {
+			log.Printf("idempotency: recording response for key %q: %v", key, err)
+		}
+	}
+}
+
+// idempotencyScope names the caller whose keys a request's key is among.
+// It reports false for a bearer token that does not check out.
+func idempotencyScope(r *http.Request, config *types.ApiConfig) (string, bool) {
+	header := r.Header.Get("Authorization")
+	switch {
+	case header == "":
+		return "anonymous:" + clientIP(r, config), true
+	case strings.HasPrefix(header, "ApiKey "):
+		// Webhook senders such as Polka authenticate with a key rather
+		// than as a user. The key is hashed so it is not stored.
+		apiKey, err := auth.GetApiKey(r.Header)
+		if err != nil {
+			return "", false
+		}
+		return "apikey:" + auth.HashSecretToken(apiKey), true
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		return "", false
+	}
+	return "user:" + userID.String(), true
+}
+
+// replayIdempotent answers a request whose key has been seen before.
+func replayIdempotent(w http.ResponseWriter, r *http.Request, config *types.ApiConfig, scope string, key string, fingerprint string) {
+	stored, err := config.Db.GetIdempotencyKey(r.Context(), database.GetIdempotencyKeyParams{
+		Scope: scope,
+		Key:   key,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if stored.Fingerprint != fingerprint {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("Idempotency-Key was already used for a different request"))
+		return
+	}
+	if !stored.Status.Valid {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("A request with this Idempotency-Key is still being processed"))
+		return
+	}
+
+	if stored.ContentType.String != "" {
+		w.Header().Set("Content-Type", stored.ContentType.String)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(int(stored.Status.Int32))
+	w.Write(stored.Body)
+}
+
+// requestFingerprint identifies a request for comparison with a later one
+// that carries the same key.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// PurgeIdempotencyKeys deletes expired keys every interval until the process
+// exits. Expired keys are already ignored, this only keeps the table small.
+func PurgeIdempotencyKeys(config *types.ApiConfig, interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := config.Db.DeleteExpiredIdempotencyKeys(context.Background()); err != nil {
+			log.Printf("idempotency: purging expired keys: %v", err)
+		}
+	}
+}
+
+// idempotencyRecorder passes a response through while keeping a copy.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.wroteHeader = true
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(p []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
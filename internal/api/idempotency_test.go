@@ -0,0 +1,20 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestFingerprintCoversTheQuery(t *testing.T) {
+	body := []byte(`{"body": "hi"}`)
+	fingerprint := func(target string) string {
+		return requestFingerprint(httptest.NewRequest("POST", target, nil), body)
+	}
+
+	if fingerprint("/api/chirps?fields=id") == fingerprint("/api/chirps?fields=body") {
+		t.Error("Expected different query parameters to change the fingerprint")
+	}
+	if fingerprint("/api/chirps?fields=id") != fingerprint("/api/chirps?fields=id") {
+		t.Error("Expected the same request to have the same fingerprint")
+	}
+}
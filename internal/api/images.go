@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/media"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// maxImageUpload caps the size of an uploaded avatar or banner.
+const maxImageUpload = 8 << 20
+
+// imageURLs maps the sizes of spec to the URLs of the renditions named in
+// names, which are stored in the same order.
+func imageURLs(config *types.ApiConfig, spec media.Spec, names []string) map[string]string {
+	if len(names) == 0 || config.Media == nil {
+		return nil
+	}
+
+	urls := make(map[string]string, len(names))
+	for i, name := range names {
+		if i < len(spec.Sizes) {
+			urls[spec.Sizes[i].String()] = config.Media.URL(name)
+		}
+	}
+	return urls
+}
+
+// UploadAvatar replaces the caller's avatar with the JPEG, PNG or WebP image
+// in the body, cropped square.
+func UploadAvatar(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	uploadImage(w, r, config, media.Avatar, func(ctx context.Context, userID uuid.UUID, names []string) (database.User, error) {
+		return config.Db.UpdateAvatar(ctx, database.UpdateAvatarParams{ID: userID, Avatar: names})
+	})
+}
+
+// UploadBanner replaces the caller's banner with the JPEG, PNG or WebP image
+// in the body, cropped to 3:1.
+func UploadBanner(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	uploadImage(w, r, config, media.Banner, func(ctx context.Context, userID uuid.UUID, names []string) (database.User, error) {
+		return config.Db.UpdateBanner(ctx, database.UpdateBannerParams{ID: userID, Banner: names})
+	})
+}
+
+// uploadImage renders the image in the body at every size of spec, stores
+// the renditions and hands their names to save. It responds with the
+// caller's updated profile.
+func uploadImage(w http.ResponseWriter, r *http.Request, config *types.ApiConfig, spec media.Spec, save func(ctx context.Context, userID uuid.UUID, names []string) (database.User, error)) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImageUpload))
+	var renditions []media.Rendition
+	if err == nil {
+		renditions, err = media.Process(data, spec)
+	}
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		status := http.StatusBadRequest
+		switch {
+		case errors.As(err, &tooBig), errors.Is(err, media.ErrTooLarge):
+			status = http.StatusRequestEntityTooLarge
+		case errors.Is(err, media.ErrUnsupported):
+			status = http.StatusUnsupportedMediaType
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	names := make([]string, 0, len(renditions))
+	for _, rendition := range renditions {
+		name, err := config.Media.Put(rendition)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		names = append(names, name)
+	}
+
+	user, err := save(r.Context(), userID, names)
+	var counts database.GetProfileCountsRow
+	if err == nil {
+		counts, err = config.Db.GetProfileCounts(r.Context(), userID)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusOK, newProfileBody(config, user, counts))
+}
+
+// ServeMedia serves a stored rendition. Its name is the hash of its
+// contents, which doubles as a strong ETag.
+func ServeMedia(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	name := r.PathValue("name")
+	path, ok := config.Media.Path(name)
+	var file *os.File
+	var err error
+	if ok {
+		file, err = os.Open(path)
+	}
+	if !ok || err != nil {
+		w.Header().Set("Cache-Control", CacheNoStore)
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		w.Header().Set("Cache-Control", CacheNoStore)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	hash, _, _ := strings.Cut(name, ".")
+	w.Header().Set("ETag", `"`+hash+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, info.ModTime(), file)
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/dabates/httpServer/internal/auth"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/lockout"
+	"github.com/dabates/httpServer/internal/mailer"
+	"github.com/dabates/httpServer/internal/types"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errLoginFailed is the answer to every failed login, so it does not reveal
+// whether the login or the password was wrong.
+var errLoginFailed = errors.New("Invalid login or password")
+
+var dummyHash struct {
+	mu   sync.Mutex
+	hash string
+}
+
+// dummyPasswordHash is a hash no password is checked against for real. It is
+// made on first use, with the current parameters, so checking it costs what
+// checking a real hash does. A failure is tried again on the next call.
+func dummyPasswordHash() (string, error) {
+	dummyHash.mu.Lock()
+	defer dummyHash.mu.Unlock()
+	if dummyHash.hash != "" {
+		return dummyHash.hash, nil
+	}
+
+	token, _ := auth.MakeSecretToken()
+	hash, err := auth.HashPassword(token)
+	if err != nil {
+		return "", err
+	}
+	dummyHash.hash = hash
+	return hash, nil
+}
+
+// loginKey is what failed logins are counted against.
+type loginKey struct {
+	key    string
+	policy lockout.Policy
+}
+
+// loginKeys returns the account and the address a login attempt counts
+// against. Logins that match no account are counted by the login itself, so
+// they are throttled the same way and do not reveal that it is unknown.
+func loginKeys(r *http.Request, config *types.ApiConfig, user database.User, found bool, login string) []loginKey {
+	account := "login:" + strings.ToLower(login)
+	if found {
+		account = accountLoginKey(user.ID.String())
+	}
+	return []loginKey{
+		{key: account, policy: lockout.AccountPolicy()},
+		{key: "ip:" + clientIP(r, config), policy: lockout.AddressPolicy()},
+	}
+}
+
+func accountLoginKey(userID string) string {
+	return "user:" + userID
+}
+
+// loginAttempt is a login attempt, counted against its keys before the
+// password is checked.
+type loginAttempt struct {
+	keys []loginKey
+	// locked is set when this attempt locked the account out.
+	locked bool
+}
+
+// beginLoginAttempt counts an attempt against keys, or answers 429 with
+// Retry-After if any of them must wait. The attempt is counted as a failure
+// up front, and the delay it earns set, under a lock on each key's row, so
+// guesses sent in parallel cannot all slip in before the first is counted.
+// finishLoginAttempt settles it once the outcome is known.
+func beginLoginAttempt(w http.ResponseWriter, r *http.Request, config *types.ApiConfig, keys []loginKey) (loginAttempt, bool) {
+	attempt := loginAttempt{keys: keys}
+	var wait time.Duration
+	err := withTx(r.Context(), config, func(db *database.Queries) error {
+		// Rows are always locked account first, so attempts cannot deadlock.
+		states := make([]database.LockLoginThrottleRow, len(keys))
+		for i, k := range keys {
+			if err := db.EnsureLoginThrottle(r.Context(), k.key); err != nil {
+				return err
+			}
+			state, err := db.LockLoginThrottle(r.Context(), k.key)
+			if err != nil {
+				return err
+			}
+			states[i] = state
+			wait = max(wait, time.Duration(state.WaitSeconds*float64(time.Second)))
+		}
+		if wait > 0 {
+			return nil
+		}
+
+		for i, k := range keys {
+			failures := int(states[i].Failures) + 1
+			if states[i].IdleSeconds > k.policy.Window.Seconds() {
+				failures = 1
+			}
+			penalty := k.policy.After(failures)
+			if penalty.Locked {
+				// A lockout starts the count over, so it is not extended
+				// by the first failure after it ends.
+				failures = 0
+			}
+			err := db.SetLoginThrottle(r.Context(), database.SetLoginThrottleParams{
+				Key:         k.key,
+				Failures:    int32(failures),
+				WaitSeconds: penalty.Wait.Seconds(),
+				Locked:      penalty.Locked,
+			})
+			if err != nil {
+				return err
+			}
+			if i == 0 && penalty.Locked {
+				attempt.locked = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return attempt, false
+	}
+
+	if wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(fmt.Sprintf("Too many failed logins; try again in %d seconds", seconds)))
+		return attempt, false
+	}
+	return attempt, true
+}
+
+// failLoginAttempt tells the user by email if the failed attempt locked
+// their account. It was already counted by beginLoginAttempt.
+func failLoginAttempt(config *types.ApiConfig, attempt loginAttempt, user database.User, found bool) {
+	if !attempt.locked || !found {
+		return
+	}
+	lockFor := attempt.keys[0].policy.LockFor
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := sendLockoutNotice(ctx, config, user, lockFor); err != nil {
+			log.Printf("lockout: sending notice: %v", err)
+		}
+	}()
+}
+
+// succeedLoginAttempt starts the account's count over after a successful
+// login. The address only has this attempt taken back, since it may be
+// guessing at other accounts.
+func succeedLoginAttempt(ctx context.Context, config *types.ApiConfig, attempt loginAttempt) {
+	account := attempt.keys[0].key
+	if _, err := config.Db.ClearLoginThrottle(ctx, account); err != nil {
+		log.Printf("lockout: clearing %s: %v", account, err)
+	}
+	forgiveLoginAttempt(ctx, config, attempt.keys[1:])
+}
+
+// forgiveLoginAttempt takes the attempt back from keys, for a right
+// password that still needs a second factor.
+func forgiveLoginAttempt(ctx context.Context, config *types.ApiConfig, keys []loginKey) {
+	for _, k := range keys {
+		if err := config.Db.ForgiveLoginAttempt(ctx, k.key); err != nil {
+			log.Printf("lockout: forgiving %s: %v", k.key, err)
+		}
+	}
+}
+
+// sendLockoutNotice tells user their account was locked for d after too
+// many failed logins.
+func sendLockoutNotice(ctx context.Context, config *types.ApiConfig, user database.User, d time.Duration) error {
+	if config.Mailer == nil {
+		return nil
+	}
+
+	return config.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy account has been locked",
+		Body: fmt.Sprintf("There were too many failed attempts to log in to your Chirpy account, "+
+			"so logging in is blocked for the next %s.\n\n"+
+			"If this was not you, someone may be guessing your password. "+
+			"Once the lock ends, consider changing it with POST /api/password/forgot.\n", d),
+	})
+}
+
+// UnlockUser lifts a lockout from failed logins on an account, along with
+// any delay before its next attempt.
+func UnlockUser(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	if err := authenticateAdmin(r, config); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	id, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := config.Db.GetUser(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(errUserNotFound.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if _, err := config.Db.ClearLoginThrottle(r.Context(), accountLoginKey(id.String())); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
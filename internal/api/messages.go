@@ -0,0 +1,547 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// maxConversationMembers caps group conversations, the caller included.
+	maxConversationMembers = 10
+	// maxMessageLength caps the body of a direct message.
+	maxMessageLength = 2000
+)
+
+var (
+	errConversationNotFound = errors.New("Conversation not found")
+	errMessageNotFound      = errors.New("Message not found")
+	errNoMembers            = errors.New("A conversation needs at least one other member")
+	errTooManyMembers       = fmt.Errorf("Conversations have at most %d members", maxConversationMembers)
+	errMessageEmpty         = errors.New("Message is empty")
+	errMessageTooLong       = fmt.Errorf("Message is longer than %d characters", maxMessageLength)
+	errBlockedMember        = errors.New("You have blocked this user")
+)
+
+// messageErrorStatus maps the errors of the messaging helpers to HTTP
+// status codes, deferring to chirpErrorStatus for the rest.
+func messageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errNoMembers), errors.Is(err, errTooManyMembers),
+		errors.Is(err, errMessageEmpty), errors.Is(err, errMessageTooLong):
+		return http.StatusBadRequest
+	case errors.Is(err, errConversationNotFound), errors.Is(err, errMessageNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errBlockedMember):
+		return http.StatusForbidden
+	}
+	return chirpErrorStatus(err)
+}
+
+type conversationBody struct {
+	Id          string   `json:"id"`
+	Members     []string `json:"members"`
+	UnreadCount int64    `json:"unread_count"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+func newConversationBody(conversation database.Conversation, members []uuid.UUID, unread int64) conversationBody {
+	body := conversationBody{
+		Id:          conversation.ID.String(),
+		Members:     []string{},
+		UnreadCount: unread,
+		CreatedAt:   conversation.CreatedAt.String(),
+		UpdatedAt:   conversation.UpdatedAt.String(),
+	}
+	for _, member := range members {
+		body.Members = append(body.Members, member.String())
+	}
+	return body
+}
+
+type messageBody struct {
+	Id             string `json:"id"`
+	ConversationId string `json:"conversation_id"`
+	SenderId       string `json:"sender_id"`
+	Body           string `json:"body"`
+	CreatedAt      string `json:"created_at"`
+}
+
+func newMessageBody(message database.Message) messageBody {
+	return messageBody{
+		Id:             message.ID.String(),
+		ConversationId: message.ConversationID.String(),
+		SenderId:       message.SenderID.String(),
+		Body:           message.Body,
+		CreatedAt:      message.CreatedAt.String(),
+	}
+}
+
+// requireMember returns errConversationNotFound unless userID belongs to the
+// conversation, so outsiders cannot tell which conversations exist.
+func requireMember(ctx context.Context, db *database.Queries, conversationID uuid.UUID, userID uuid.UUID) error {
+	member, err := db.IsConversationMember(ctx, database.IsConversationMemberParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		return err
+	}
+	if !member {
+		return errConversationNotFound
+	}
+	return nil
+}
+
+// conversationMembers returns the members of each conversation, by id.
+func conversationMembers(ctx context.Context, db *database.Queries, ids []uuid.UUID) (map[uuid.UUID][]uuid.UUID, error) {
+	rows, err := db.GetConversationMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	members := map[uuid.UUID][]uuid.UUID{}
+	for _, row := range rows {
+		members[row.ConversationID] = append(members[row.ConversationID], row.UserID)
+	}
+	return members, nil
+}
+
+// StartConversation opens a conversation between the caller and the users in
+// member_ids. Starting a one-to-one conversation that already exists returns
+// it with a 200 instead of opening a second one. Users who blocked the caller,
+// or whom the caller blocked, cannot be added.
+func StartConversation(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		MemberIds []uuid.UUID `json:"member_ids"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	members := []uuid.UUID{userID}
+	seen := map[uuid.UUID]bool{userID: true}
+	for _, id := range bodyData.MemberIds {
+		if !seen[id] {
+			seen[id] = true
+			members = append(members, id)
+		}
+	}
+
+	status, conversation, err := startConversation(r.Context(), config, userID, members)
+	var unread int64
+	if err == nil && status == http.StatusOK {
+		unread, err = config.Db.GetConversationUnreadCount(r.Context(), database.GetConversationUnreadCountParams{
+			ConversationID: conversation.ID,
+			UserID:         userID,
+		})
+	}
+	if err != nil {
+		w.WriteHeader(messageErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, status, newConversationBody(conversation, members, unread))
+}
+
+func startConversation(ctx context.Context, config *types.ApiConfig, userID uuid.UUID, members []uuid.UUID) (int, database.Conversation, error) {
+	if len(members) < 2 {
+		return 0, database.Conversation{}, errNoMembers
+	}
+	if len(members) > maxConversationMembers {
+		return 0, database.Conversation{}, errTooManyMembers
+	}
+
+	for _, memberID := range members[1:] {
+		_, err := config.Db.GetUser(ctx, memberID)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = errUserNotFound
+		}
+		if err == nil {
+			err = checkNotBlocked(ctx, config.Db, memberID, userID)
+		}
+		if err == nil {
+			err = checkNotBlocked(ctx, config.Db, userID, memberID)
+			if errors.Is(err, errBlocked) {
+				err = errBlockedMember
+			}
+		}
+		if err != nil {
+			return 0, database.Conversation{}, err
+		}
+	}
+
+	if len(members) == 2 {
+		conversation, err := config.Db.GetDirectConversation(ctx, database.GetDirectConversationParams{
+			UserID:  userID,
+			OtherID: members[1],
+		})
+		if err == nil {
+			return http.StatusOK, conversation, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, database.Conversation{}, err
+		}
+	}
+
+	var conversation database.Conversation
+	err := withTx(ctx, config, func(db *database.Queries) error {
+		var err error
+		conversation, err = db.CreateConversation(ctx, uuid.NullUUID{UUID: userID, Valid: true})
+		if err != nil {
+			return err
+		}
+		for _, memberID := range members {
+			err = db.AddConversationMember(ctx, database.AddConversationMemberParams{
+				ConversationID: conversation.ID,
+				UserID:         memberID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return http.StatusCreated, conversation, err
+}
+
+// MyConversations lists the caller's conversations, most recently active
+// first, with how many messages in each they have not read.
+func MyConversations(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	limit, ok := pageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := config.Db.GetConversationsByUser(r.Context(), database.GetConversationsByUserParams{
+		UserID:   userID,
+		PageSize: limit,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	members, err := conversationMembers(r.Context(), config.Db, ids)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resp := []conversationBody{}
+	for _, row := range rows {
+		conversation := database.Conversation{
+			ID:        row.ID,
+			CreatedBy: row.CreatedBy,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+		}
+		resp = append(resp, newConversationBody(conversation, members[row.ID], row.UnreadCount))
+	}
+	respond(w, enc, http.StatusOK, resp)
+}
+
+// SendMessage posts a message to a conversation the caller belongs to. A
+// block by any other member stops the caller from sending, in groups as in
+// one-to-one conversations, so blocked users cannot reach the blocker
+// through a group they share.
+func SendMessage(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Body string `json:"body"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	conversationID, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	message, err := sendMessage(r.Context(), config, userID, conversationID, bodyData.Body)
+	if err != nil {
+		w.WriteHeader(messageErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusCreated, newMessageBody(message))
+}
+
+func sendMessage(ctx context.Context, config *types.ApiConfig, userID uuid.UUID, conversationID uuid.UUID, body string) (database.Message, error) {
+	if strings.TrimSpace(body) == "" {
+		return database.Message{}, errMessageEmpty
+	}
+	if len(body) > maxMessageLength {
+		return database.Message{}, errMessageTooLong
+	}
+
+	err := requireMember(ctx, config.Db, conversationID, userID)
+	if err != nil {
+		return database.Message{}, err
+	}
+
+	user, err := config.Db.GetUser(ctx, userID)
+	if err != nil {
+		return database.Message{}, err
+	}
+	if user.SuspendedAt.Valid {
+		return database.Message{}, errUserSuspended
+	}
+	if !user.EmailVerifiedAt.Valid {
+		return database.Message{}, errEmailUnverified
+	}
+
+	members, err := conversationMembers(ctx, config.Db, []uuid.UUID{conversationID})
+	if err != nil {
+		return database.Message{}, err
+	}
+	for _, memberID := range members[conversationID] {
+		if memberID == userID {
+			continue
+		}
+		if err := checkNotBlocked(ctx, config.Db, memberID, userID); err != nil {
+			return database.Message{}, err
+		}
+	}
+
+	var message database.Message
+	err = withTx(ctx, config, func(db *database.Queries) error {
+		var err error
+		message, err = db.CreateMessage(ctx, database.CreateMessageParams{
+			ConversationID: conversationID,
+			SenderID:       userID,
+			Body:           body,
+		})
+		if err != nil {
+			return err
+		}
+		return db.TouchConversation(ctx, conversationID)
+	})
+	return message, err
+}
+
+// ConversationMessages pages through a conversation, newest first. Pass the
+// next_cursor of a page as ?before= to get the one after it.
+func ConversationMessages(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type respBody struct {
+		Messages   []messageBody `json:"messages"`
+		NextCursor string        `json:"next_cursor,omitempty"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	conversationID, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	limit, ok := pageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	params := database.GetMessagesPageParams{
+		ConversationID: conversationID,
+		// Fetch one extra row to learn whether there is a next page.
+		PageSize: limit + 1,
+	}
+	if before := r.URL.Query().Get("before"); before != "" {
+		createdAt, id, err := decodeCursor(before)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		params.BeforeCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	err = requireMember(r.Context(), config.Db, conversationID, userID)
+	if err != nil {
+		w.WriteHeader(messageErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	messages, err := config.Db.GetMessagesPage(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resp := respBody{Messages: []messageBody{}}
+	if len(messages) > int(limit) {
+		messages = messages[:limit]
+		last := messages[len(messages)-1]
+		resp.NextCursor = encodeKeyset(last.CreatedAt, last.ID)
+	}
+	for _, message := range messages {
+		resp.Messages = append(resp.Messages, newMessageBody(message))
+	}
+	respond(w, enc, http.StatusOK, resp)
+}
+
+// MarkConversationRead marks a conversation read for the caller, up to the
+// message_id in the body or, without one, up to now.
+func MarkConversationRead(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		MessageId *uuid.UUID `json:"message_id"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	conversationID, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	err = requireMember(r.Context(), config.Db, conversationID, userID)
+	if err == nil {
+		err = markRead(r.Context(), config.Db, userID, conversationID, bodyData.MessageId)
+	}
+	if err != nil {
+		w.WriteHeader(messageErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func markRead(ctx context.Context, db *database.Queries, userID uuid.UUID, conversationID uuid.UUID, messageID *uuid.UUID) error {
+	params := database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	}
+	if messageID != nil {
+		message, err := db.GetMessage(ctx, *messageID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && message.ConversationID != conversationID) {
+			return errMessageNotFound
+		}
+		if err != nil {
+			return err
+		}
+		params.ReadAt = sql.NullTime{Time: message.CreatedAt, Valid: true}
+	}
+	return db.MarkConversationRead(ctx, params)
+}
+
+// UnreadMessages counts the caller's unread messages, and the conversations
+// they are in.
+func UnreadMessages(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type respBody struct {
+		Conversations int64 `json:"conversations"`
+		Messages      int64 `json:"messages"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	counts, err := config.Db.GetUnreadCounts(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusOK, respBody{
+		Conversations: counts.Conversations,
+		Messages:      counts.Messages,
+	})
+}
@@ -0,0 +1,752 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/dabates/httpServer/internal/auth"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/events"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Reasons a chirp or user can be reported for.
+var reportReasons = map[string]bool{
+	"spam":           true,
+	"harassment":     true,
+	"hate":           true,
+	"violence":       true,
+	"self_harm":      true,
+	"misinformation": true,
+	"other":          true,
+}
+
+// maxReportDetails caps the free text of a report or appeal.
+const maxReportDetails = 1000
+
+// Report and appeal statuses.
+const (
+	statusOpen      = "open"
+	statusResolved  = "resolved"
+	statusDismissed = "dismissed"
+	statusGranted   = "granted"
+	statusDenied    = "denied"
+)
+
+// Moderation actions. The appeal ones are recorded when an appeal is decided.
+const (
+	actionHide          = "hide"
+	actionDelete        = "delete"
+	actionSuspend       = "suspend"
+	actionDismiss       = "dismiss"
+	actionAppealGranted = "appeal_granted"
+	actionAppealDenied  = "appeal_denied"
+)
+
+// appealScope scopes the tokens suspended users get in place of a login.
+// They only work for listing and appealing moderation actions.
+const appealScope = "appeal"
+
+var (
+	errReportSelf      = errors.New("Users cannot report themselves")
+	errAlreadyReported = errors.New("Already reported")
+	errReportNotFound  = errors.New("Report not found")
+	errReportClosed    = errors.New("Report is not open")
+	errActionNotFound  = errors.New("Moderation action not found")
+	errAppealNotFound  = errors.New("Appeal not found")
+	errAppealForbidden = errors.New("Not allowed to appeal this action")
+	errAlreadyAppealed = errors.New("Already appealed")
+	errAppealClosed    = errors.New("Appeal is not open")
+	errNeedsChirp      = errors.New("This action needs a reported chirp")
+)
+
+// moderationErrorStatus maps the errors of the moderation helpers to HTTP
+// status codes, deferring to chirpErrorStatus for the rest.
+func moderationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errReportSelf), errors.Is(err, errNeedsChirp):
+		return http.StatusBadRequest
+	case errors.Is(err, errAppealForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, errReportNotFound), errors.Is(err, errActionNotFound), errors.Is(err, errAppealNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errAlreadyReported), errors.Is(err, errReportClosed),
+		errors.Is(err, errAlreadyAppealed), errors.Is(err, errAppealClosed):
+		return http.StatusConflict
+	}
+	return chirpErrorStatus(err)
+}
+
+type reportBody struct {
+	Id         string `json:"id"`
+	ReporterId string `json:"reporter_id"`
+	ChirpId    string `json:"chirp_id,omitempty"`
+	UserId     string `json:"user_id"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details"`
+	Status     string `json:"status"`
+	CreatedAt  string `json:"created_at"`
+	ResolvedAt string `json:"resolved_at,omitempty"`
+}
+
+func newReportBody(report database.Report) reportBody {
+	body := reportBody{
+		Id:         report.ID.String(),
+		ReporterId: report.ReporterID.String(),
+		UserId:     report.UserID.String(),
+		Reason:     report.Reason,
+		Details:    report.Details,
+		Status:     report.Status,
+		CreatedAt:  report.CreatedAt.String(),
+	}
+	if report.ChirpID.Valid {
+		body.ChirpId = report.ChirpID.UUID.String()
+	}
+	if report.ResolvedAt.Valid {
+		body.ResolvedAt = report.ResolvedAt.Time.String()
+	}
+	return body
+}
+
+type moderationActionBody struct {
+	Id        string `json:"id"`
+	ReportId  string `json:"report_id,omitempty"`
+	Action    string `json:"action"`
+	ChirpId   string `json:"chirp_id,omitempty"`
+	UserId    string `json:"user_id"`
+	Note      string `json:"note"`
+	CreatedAt string `json:"created_at"`
+}
+
+func newModerationActionBody(action database.ModerationAction) moderationActionBody {
+	body := moderationActionBody{
+		Id:        action.ID.String(),
+		Action:    action.Action,
+		UserId:    action.UserID.String(),
+		Note:      action.Note,
+		CreatedAt: action.CreatedAt.String(),
+	}
+	if action.ReportID.Valid {
+		body.ReportId = action.ReportID.UUID.String()
+	}
+	if action.ChirpID.Valid {
+		body.ChirpId = action.ChirpID.UUID.String()
+	}
+	return body
+}
+
+type appealBody struct {
+	Id         string `json:"id"`
+	ActionId   string `json:"action_id"`
+	UserId     string `json:"user_id"`
+	Message    string `json:"message"`
+	Status     string `json:"status"`
+	CreatedAt  string `json:"created_at"`
+	ResolvedAt string `json:"resolved_at,omitempty"`
+}
+
+func newAppealBody(appeal database.Appeal) appealBody {
+	body := appealBody{
+		Id:        appeal.ID.String(),
+		ActionId:  appeal.ActionID.String(),
+		UserId:    appeal.UserID.String(),
+		Message:   appeal.Message,
+		Status:    appeal.Status,
+		CreatedAt: appeal.CreatedAt.String(),
+	}
+	if appeal.ResolvedAt.Valid {
+		body.ResolvedAt = appeal.ResolvedAt.Time.String()
+	}
+	return body
+}
+
+// withTx runs fn in a transaction, committing if it returns nil.
+func withTx(ctx context.Context, config *types.ApiConfig, fn func(db *database.Queries) error) error {
+	tx, err := config.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(config.Db.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// pathUUID parses the {id} path value, writing a 400 if it is not a UUID.
+func pathUUID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// pageLimit reads ?limit=, writing a 400 if it is out of range.
+func pageLimit(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return defaultPageSize, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > maxPageSize {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("limit must be between 1 and " + strconv.Itoa(maxPageSize)))
+		return 0, false
+	}
+	return int32(n), true
+}
+
+type newReport struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
+}
+
+func (n newReport) validate() error {
+	if !reportReasons[n.Reason] {
+		return errors.New("Unknown reason")
+	}
+	if len(n.Details) > maxReportDetails {
+		return errors.New("Details are too long")
+	}
+	return nil
+}
+
+// ReportChirp files a report against a chirp and its author.
+func ReportChirp(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	fileReport(w, r, config, func(ctx context.Context, id uuid.UUID) (uuid.NullUUID, uuid.UUID, error) {
+		chirp, err := config.Db.GetVisibleChirp(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.NullUUID{}, uuid.Nil, errChirpNotFound
+		}
+		if err != nil {
+			return uuid.NullUUID{}, uuid.Nil, err
+		}
+		return uuid.NullUUID{UUID: chirp.ID, Valid: true}, chirp.UserID, nil
+	})
+}
+
+// ReportUser files a report against a user.
+func ReportUser(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	fileReport(w, r, config, func(ctx context.Context, id uuid.UUID) (uuid.NullUUID, uuid.UUID, error) {
+		user, err := config.Db.GetUser(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.NullUUID{}, uuid.Nil, errUserNotFound
+		}
+		if err != nil {
+			return uuid.NullUUID{}, uuid.Nil, err
+		}
+		return uuid.NullUUID{}, user.ID, nil
+	})
+}
+
+// fileReport is the shared part of ReportChirp and ReportUser. target looks
+// up the {id} of the path and returns the reported chirp, if any, and the
+// reported user.
+func fileReport(w http.ResponseWriter, r *http.Request, config *types.ApiConfig, target func(ctx context.Context, id uuid.UUID) (uuid.NullUUID, uuid.UUID, error)) {
+	reporterID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	id, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	bodyData := newReport{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err == nil {
+		err = bodyData.validate()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	chirpID, userID, err := target(r.Context(), id)
+	if err == nil && userID == reporterID {
+		err = errReportSelf
+	}
+	if err != nil {
+		w.WriteHeader(moderationErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	report, err := config.Db.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID: reporterID,
+		ChirpID:    chirpID,
+		UserID:     userID,
+		Reason:     bodyData.Reason,
+		Details:    bodyData.Details,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		err = errAlreadyReported
+	}
+	if err != nil {
+		w.WriteHeader(moderationErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusCreated, newReportBody(report))
+}
+
+// authenticateAppeal is authenticate, but also accepts the appeal tokens
+// suspended users get from Login and Refresh.
+func authenticateAppeal(r *http.Request, config *types.ApiConfig) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if userID, err := auth.ValidateJWT(token, config.Secret); err == nil {
+		return userID, nil
+	}
+	return auth.ValidateScopedJWT(token, config.Secret, appealScope)
+}
+
+// refuseSuspended answers a login or refresh by a suspended user with a 403
+// carrying an appeal token, so they can still see why they were suspended
+// and appeal it.
+func refuseSuspended(w http.ResponseWriter, enc encoder, config *types.ApiConfig, userID uuid.UUID) {
+	type respBody struct {
+		Error       string `json:"error"`
+		AppealToken string `json:"appeal_token"`
+	}
+
+	token, err := auth.MakeScopedJWT(userID, config.Secret, time.Hour, appealScope)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusForbidden, respBody{
+		Error:       errUserSuspended.Error(),
+		AppealToken: token,
+	})
+}
+
+// MyModerationActions lists the moderation actions taken against the
+// caller, which are what they can appeal. Suspended users may call it with
+// an appeal token.
+func MyModerationActions(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticateAppeal(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	actions, err := config.Db.GetModerationActionsByUser(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resp := []moderationActionBody{}
+	for _, action := range actions {
+		resp = append(resp, newModerationActionBody(action))
+	}
+	respond(w, enc, http.StatusOK, resp)
+}
+
+// Appeal lets the user a hide, delete or suspend action was taken against
+// ask for it to be reviewed. Each action can be appealed once. Suspended
+// users may call it with an appeal token.
+func Appeal(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Message string `json:"message"`
+	}
+
+	userID, err := authenticateAppeal(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	actionID, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err == nil && len(bodyData.Message) > maxReportDetails {
+		err = errors.New("Message is too long")
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	action, err := config.Db.GetModerationAction(r.Context(), actionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = errActionNotFound
+	}
+	if err == nil && (action.UserID != userID || !appealable(action.Action)) {
+		err = errAppealForbidden
+	}
+	if err != nil {
+		w.WriteHeader(moderationErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	appeal, err := config.Db.CreateAppeal(r.Context(), database.CreateAppealParams{
+		ActionID: action.ID,
+		UserID:   userID,
+		Message:  bodyData.Message,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		err = errAlreadyAppealed
+	}
+	if err != nil {
+		w.WriteHeader(moderationErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusCreated, newAppealBody(appeal))
+}
+
+func appealable(action string) bool {
+	return action == actionHide || action == actionDelete || action == actionSuspend
+}
+
+// ModerationReports lists reports, oldest first. ?status= picks open (the
+// default), resolved or dismissed ones.
+func ModerationReports(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	if err := authenticateAdmin(r, config); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+	limit, ok := pageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = statusOpen
+	}
+
+	reports, err := config.Db.GetReportsByStatus(r.Context(), database.GetReportsByStatusParams{
+		Status:   status,
+		PageSize: limit,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resp := []reportBody{}
+	for _, report := range reports {
+		resp = append(resp, newReportBody(report))
+	}
+	respond(w, enc, http.StatusOK, resp)
+}
+
+// ModerateReport takes an action on an open report: hide or delete the
+// reported chirp, suspend the reported user, or dismiss the report. The
+// decision is recorded with the moderator's note.
+func ModerateReport(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Action string `json:"action"`
+		Note   string `json:"note"`
+	}
+
+	if err := authenticateAdmin(r, config); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	reportID, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err := json.NewDecoder(r.Body).Decode(&bodyData)
+	if err == nil && !appealable(bodyData.Action) && bodyData.Action != actionDismiss {
+		err = errors.New("Unknown action")
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	var action database.ModerationAction
+	var deleted *database.Chirp
+	err = withTx(r.Context(), config, func(db *database.Queries) error {
+		// The lock makes a second moderator acting on the report wait, and
+		// then find it closed, instead of taking a second action.
+		report, err := db.LockReport(r.Context(), reportID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errReportNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if report.Status != statusOpen {
+			return errReportClosed
+		}
+
+		status := statusResolved
+		switch bodyData.Action {
+		case actionHide:
+			if !report.ChirpID.Valid {
+				return errNeedsChirp
+			}
+			err = db.HideChirp(r.Context(), report.ChirpID.UUID)
+		case actionDelete:
+			if !report.ChirpID.Valid {
+				return errNeedsChirp
+			}
+			var chirp database.Chirp
+			chirp, err = removeChirp(r.Context(), db, report.UserID, report.ChirpID.UUID)
+			if err == nil {
+				err = enqueueWebhooks(r.Context(), config, db, events.Event{Type: events.ChirpDeleted, Chirp: chirp})
+			}
+			deleted = &chirp
+		case actionSuspend:
+			err = db.SuspendUser(r.Context(), report.UserID)
+		case actionDismiss:
+			status = statusDismissed
+		}
+		if err != nil {
+			return err
+		}
+
+		err = db.ResolveReport(r.Context(), database.ResolveReportParams{ID: report.ID, Status: status})
+		if err != nil {
+			return err
+		}
+
+		action, err = db.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+			ReportID: uuid.NullUUID{UUID: report.ID, Valid: true},
+			Action:   bodyData.Action,
+			ChirpID:  report.ChirpID,
+			UserID:   report.UserID,
+			Note:     bodyData.Note,
+		})
+		return err
+	})
+	if err != nil {
+		w.WriteHeader(moderationErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if deleted != nil {
+		config.Events.Publish(events.Event{Type: events.ChirpDeleted, Chirp: *deleted})
+	}
+	respond(w, enc, http.StatusCreated, newModerationActionBody(action))
+}
+
+// ModerationLog lists every moderation decision, newest first.
+func ModerationLog(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	if err := authenticateAdmin(r, config); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+	limit, ok := pageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	actions, err := config.Db.GetModerationActions(r.Context(), limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resp := []moderationActionBody{}
+	for _, action := range actions {
+		resp = append(resp, newModerationActionBody(action))
+	}
+	respond(w, enc, http.StatusOK, resp)
+}
+
+// ModerationAppeals lists appeals, oldest first. ?status= picks open (the
+// default), granted or denied ones.
+func ModerationAppeals(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	if err := authenticateAdmin(r, config); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+	limit, ok := pageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = statusOpen
+	}
+
+	appeals, err := config.Db.GetAppealsByStatus(r.Context(), database.GetAppealsByStatusParams{
+		Status:   status,
+		PageSize: limit,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resp := []appealBody{}
+	for _, appeal := range appeals {
+		resp = append(resp, newAppealBody(appeal))
+	}
+	respond(w, enc, http.StatusOK, resp)
+}
+
+// DecideAppeal grants or denies an open appeal. Granting one unhides the
+// chirp or lifts the suspension; a deleted chirp cannot be brought back.
+// The decision is recorded like any other moderation action.
+func DecideAppeal(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Decision string `json:"decision"`
+		Note     string `json:"note"`
+	}
+
+	if err := authenticateAdmin(r, config); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	appealID, ok := pathUUID(w, r)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err := json.NewDecoder(r.Body).Decode(&bodyData)
+	if err == nil && bodyData.Decision != "grant" && bodyData.Decision != "deny" {
+		err = errors.New("Decision must be grant or deny")
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	var decision database.ModerationAction
+	err = withTx(r.Context(), config, func(db *database.Queries) error {
+		// As in ModerateReport, the lock stops two decisions on one appeal.
+		appeal, err := db.LockAppeal(r.Context(), appealID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errAppealNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if appeal.Status != statusOpen {
+			return errAppealClosed
+		}
+
+		action, err := db.GetModerationAction(r.Context(), appeal.ActionID)
+		if err != nil {
+			return err
+		}
+
+		status, recorded := statusDenied, actionAppealDenied
+		if bodyData.Decision == "grant" {
+			status, recorded = statusGranted, actionAppealGranted
+			switch action.Action {
+			case actionHide:
+				err = db.UnhideChirp(r.Context(), action.ChirpID.UUID)
+			case actionSuspend:
+				err = db.UnsuspendUser(r.Context(), action.UserID)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		err = db.ResolveAppeal(r.Context(), database.ResolveAppealParams{ID: appeal.ID, Status: status})
+		if err != nil {
+			return err
+		}
+
+		decision, err = db.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+			ReportID: action.ReportID,
+			Action:   recorded,
+			ChirpID:  action.ChirpID,
+			UserID:   action.UserID,
+			Note:     bodyData.Note,
+		})
+		return err
+	})
+	if err != nil {
+		w.WriteHeader(moderationErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusCreated, newModerationActionBody(decision))
+}
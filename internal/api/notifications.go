@@ -0,0 +1,386 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Notification types. Every type is on until the user turns it off.
+const (
+	notifyLike    = "like"
+	notifyFollow  = "follow"
+	notifyMention = "mention"
+)
+
+var notificationTypes = []string{notifyLike, notifyFollow, notifyMention}
+
+// maxGroupActors caps how many actors a notification group lists; the rest
+// are only counted.
+const maxGroupActors = 3
+
+// notify tells userID that actorID did something, unless it was userID
+// themselves, the type is turned off or userID muted actorID.
+func notify(ctx context.Context, db *database.Queries, userID uuid.UUID, actorID uuid.UUID, kind string, chirpID uuid.NullUUID) error {
+	if userID == actorID {
+		return nil
+	}
+
+	return db.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  userID,
+		ActorID: actorID,
+		Type:    kind,
+		ChirpID: chirpID,
+	})
+}
+
+// mentionPattern finds @handle mentions. The @ must not follow a word
+// character, so email addresses are not mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_]{3,30})\b`)
+
+// notifyMentions tells every user mentioned in chirp, except those who
+// blocked its author.
+func notifyMentions(ctx context.Context, db *database.Queries, chirp database.Chirp) error {
+	seen := map[string]bool{}
+	handles := []string{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(chirp.Body, -1) {
+		handle := strings.ToLower(match[1])
+		if !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+	if len(handles) == 0 {
+		return nil
+	}
+
+	users, err := db.GetUsersByHandles(ctx, handles)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		err := checkNotBlocked(ctx, db, user.ID, chirp.UserID)
+		if errors.Is(err, errBlocked) {
+			continue
+		}
+		if err == nil {
+			err = notify(ctx, db, user.ID, chirp.UserID, notifyMention, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notificationGroupBody is a run of similar notifications: the same type
+// about the same chirp.
+type notificationGroupBody struct {
+	Type       string   `json:"type"`
+	ChirpId    string   `json:"chirp_id,omitempty"`
+	Summary    string   `json:"summary"`
+	ActorIds   []string `json:"actor_ids"`
+	ActorCount int      `json:"actor_count"`
+	Ids        []string `json:"ids"`
+	Read       bool     `json:"read"`
+	LatestAt   string   `json:"latest_at"`
+	Cursor     string   `json:"cursor"`
+}
+
+// groupNotifications folds notifications, newest first, into groups ordered
+// by their newest notification.
+func groupNotifications(notifications []database.Notification) []notificationGroupBody {
+	groups := []notificationGroupBody{}
+	index := map[string]int{}
+	actors := map[string]map[uuid.UUID]bool{}
+
+	for _, n := range notifications {
+		key := n.Type + "|" + n.ChirpID.UUID.String()
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			actors[key] = map[uuid.UUID]bool{}
+			group := notificationGroupBody{
+				Type:     n.Type,
+				ActorIds: []string{},
+				Read:     true,
+				LatestAt: n.CreatedAt.String(),
+				Cursor:   encodeKeyset(n.CreatedAt, n.ID),
+			}
+			if n.ChirpID.Valid {
+				group.ChirpId = n.ChirpID.UUID.String()
+			}
+			groups = append(groups, group)
+		}
+
+		group := &groups[i]
+		group.Ids = append(group.Ids, n.ID.String())
+		group.Read = group.Read && n.ReadAt.Valid
+		if !actors[key][n.ActorID] {
+			actors[key][n.ActorID] = true
+			group.ActorCount++
+			if len(group.ActorIds) < maxGroupActors {
+				group.ActorIds = append(group.ActorIds, n.ActorID.String())
+			}
+		}
+	}
+
+	for i := range groups {
+		groups[i].Summary = notificationSummary(groups[i].Type, groups[i].ActorCount)
+	}
+	return groups
+}
+
+func notificationSummary(kind string, actors int) string {
+	who := "1 person"
+	if actors != 1 {
+		who = fmt.Sprintf("%d people", actors)
+	}
+
+	switch kind {
+	case notifyLike:
+		return who + " liked your chirp"
+	case notifyFollow:
+		return who + " followed you"
+	case notifyMention:
+		return who + " mentioned you"
+	}
+	return who + " interacted with you"
+}
+
+// Notifications lists the caller's notifications, newest first, grouped.
+// Groups only span one page: pass next_cursor as ?before= for older ones.
+// ?unread=true leaves out notifications already read.
+func Notifications(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type respBody struct {
+		Groups      []notificationGroupBody `json:"groups"`
+		UnreadCount int64                   `json:"unread_count"`
+		NextCursor  string                  `json:"next_cursor,omitempty"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	limit, ok := pageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	params := database.GetNotificationsPageParams{
+		UserID:     userID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		// Fetch one extra row to learn whether there is a next page.
+		PageSize: limit + 1,
+	}
+	if before := r.URL.Query().Get("before"); before != "" {
+		createdAt, id, err := decodeCursor(before)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		params.BeforeCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	notifications, err := config.Db.GetNotificationsPage(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	unread, err := config.Db.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resp := respBody{UnreadCount: unread}
+	if len(notifications) > int(limit) {
+		notifications = notifications[:limit]
+		last := notifications[len(notifications)-1]
+		resp.NextCursor = encodeKeyset(last.CreatedAt, last.ID)
+	}
+	resp.Groups = groupNotifications(notifications)
+	respond(w, enc, http.StatusOK, resp)
+}
+
+// ReadNotifications marks notifications read, either those listed in ids or
+// every one up to and including the one a group's cursor points at.
+func ReadNotifications(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Ids  []uuid.UUID `json:"ids"`
+		UpTo string      `json:"up_to"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	bodyData := reqBody{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err == nil && (len(bodyData.Ids) == 0) == (bodyData.UpTo == "") {
+		err = errors.New("Give either ids or up_to")
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if bodyData.UpTo != "" {
+		createdAt, id, err := decodeCursor(bodyData.UpTo)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		err = config.Db.MarkNotificationsReadUpTo(r.Context(), database.MarkNotificationsReadUpToParams{
+			UserID:        userID,
+			UpToCreatedAt: createdAt,
+			UpToID:        id,
+		})
+	} else {
+		err = config.Db.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+			UserID: userID,
+			Ids:    bodyData.Ids,
+		})
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// NotificationPreferences shows which notification types the caller gets.
+func NotificationPreferences(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	prefs, err := notificationPreferences(r.Context(), config.Db, userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusOK, prefs)
+}
+
+// UpdateNotificationPreferences turns notification types on or off. Types
+// left out of the body keep their setting.
+func UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	bodyData := map[string]bool{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err == nil {
+		for kind := range bodyData {
+			if !isNotificationType(kind) {
+				err = fmt.Errorf("Unknown notification type %q", kind)
+				break
+			}
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	var prefs map[string]bool
+	err = withTx(r.Context(), config, func(db *database.Queries) error {
+		for kind, enabled := range bodyData {
+			err := db.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+				UserID:  userID,
+				Type:    kind,
+				Enabled: enabled,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		var err error
+		prefs, err = notificationPreferences(r.Context(), db, userID)
+		return err
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusOK, prefs)
+}
+
+// notificationPreferences returns whether userID gets each notification type.
+func notificationPreferences(ctx context.Context, db *database.Queries, userID uuid.UUID) (map[string]bool, error) {
+	rows, err := db.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := map[string]bool{}
+	for _, kind := range notificationTypes {
+		prefs[kind] = true
+	}
+	for _, row := range rows {
+		if isNotificationType(row.Type) {
+			prefs[row.Type] = row.Enabled
+		}
+	}
+	return prefs, nil
+}
+
+func isNotificationType(kind string) bool {
+	for _, t := range notificationTypes {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
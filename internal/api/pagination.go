@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// Page sizes shared by every paginated transport.
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// chirpPage is one page of a keyset-paginated chirp feed.
+type chirpPage struct {
+	chirps      []database.Chirp
+	hasNextPage bool
+}
+
+func encodeCursor(chirp database.Chirp) string {
+	return encodeKeyset(chirp.CreatedAt, chirp.ID)
+}
+
+// encodeKeyset builds an opaque cursor from the (created_at, id) keyset
+// shared by the paginated tables. decodeCursor reverses it.
+func encodeKeyset(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor")
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor")
+	}
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor")
+	}
+
+	return t, u, nil
+}
+
+// chirpsPage loads up to first chirps after the cursor, oldest first. The
+// cursor is the one encodeCursor returns for the last chirp of a page.
+func chirpsPage(ctx context.Context, config *types.ApiConfig, authorID uuid.NullUUID, first int, after string) (chirpPage, error) {
+	if first <= 0 || first > maxPageSize {
+		return chirpPage{}, fmt.Errorf("page size must be between 1 and %d", maxPageSize)
+	}
+
+	params := database.GetChirpsPageParams{
+		AuthorID: authorID,
+		// Fetch one extra row to learn whether there is a next page.
+		PageSize: int32(first + 1),
+	}
+	if after != "" {
+		createdAt, id, err := decodeCursor(after)
+		if err != nil {
+			return chirpPage{}, err
+		}
+		params.AfterCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	chirps, err := config.Db.GetChirpsPage(ctx, params)
+	if err != nil {
+		return chirpPage{}, err
+	}
+
+	conn := chirpPage{chirps: chirps}
+	if len(chirps) > first {
+		conn.chirps = chirps[:first]
+		conn.hasNextPage = true
+	}
+
+	return conn, nil
+}
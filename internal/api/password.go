@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dabates/httpServer/internal/auth"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/mailer"
+	"github.com/dabates/httpServer/internal/passwords"
+	"github.com/dabates/httpServer/internal/types"
+	"log"
+	"net/http"
+	"time"
+)
+
+// checkPassword applies the password policy to a new password. userInputs
+// are the account's email addresses and handle, which it must not contain.
+func checkPassword(config *types.ApiConfig, password string, userInputs ...string) error {
+	policy := passwords.DefaultPolicy()
+	if config.Passwords != nil {
+		policy = *config.Passwords
+	}
+	return policy.Check(password, userInputs...)
+}
+
+func isPasswordPolicyError(err error) bool {
+	for _, target := range []error{passwords.ErrTooShort, passwords.ErrTooLong, passwords.ErrPersonal, passwords.ErrTooWeak, passwords.ErrBreached} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ForgotPassword emails a password reset token to the address in the body,
+// if it belongs to an account. The response is the same 202 either way, and
+// comes before any lookup, so it does not reveal which addresses have
+// accounts.
+func ForgotPassword(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Email string `json:"email"`
+	}
+
+	bodyData := reqBody{}
+	err := json.NewDecoder(r.Body).Decode(&bodyData)
+	if err == nil {
+		err = validateEmail(bodyData.Email)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := sendPasswordReset(ctx, config, bodyData.Email); err != nil {
+			log.Printf("password: sending reset email: %v", err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendPasswordReset emails a reset token, valid for an hour, to the account
+// with email. Tokens sent before stop working, and at most one is sent a
+// minute so the endpoint cannot be used to flood an inbox.
+func sendPasswordReset(ctx context.Context, config *types.ApiConfig, email string) error {
+	if config.Mailer == nil {
+		return nil
+	}
+
+	user, err := config.Db.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	recent, err := config.Db.CountRecentPasswordResets(ctx, user.ID)
+	if err != nil || recent > 0 {
+		return err
+	}
+
+	token, hash := auth.MakeSecretToken()
+	err = withTx(ctx, config, func(db *database.Queries) error {
+		if err := db.RevokePasswordResets(ctx, user.ID); err != nil {
+			return err
+		}
+		_, err := db.CreatePasswordReset(ctx, database.CreatePasswordResetParams{
+			UserID:    user.ID,
+			Email:     user.Email,
+			TokenHash: hash,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return config.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Chirpy account.\n\n"+
+			"To choose a new one, send this token with it to POST /api/password/reset:\n\n"+
+			"%s\n\n"+
+			"It expires in an hour. If it was not you, ignore this email; your password has not changed.\n", token),
+	})
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword. Every
+// refresh token of the account is revoked, signing out all its sessions.
+func ResetPassword(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	bodyData := reqBody{}
+	err := json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	err = resetPassword(r.Context(), config, bodyData.Token, bodyData.Password)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errInvalidToken) || isPasswordPolicyError(err) {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func resetPassword(ctx context.Context, config *types.ApiConfig, token string, password string) error {
+	reset, err := config.Db.GetPasswordResetByHash(ctx, auth.HashSecretToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return errInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := config.Db.GetUser(ctx, reset.UserID)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(config, password, user.Email, user.Handle.String); err != nil {
+		return err
+	}
+
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return withTx(ctx, config, func(db *database.Queries) error {
+		used, err := db.UsePasswordReset(ctx, reset.ID)
+		if err != nil {
+			return err
+		}
+		if used == 0 {
+			return errInvalidToken
+		}
+
+		_, err = db.ResetUserPassword(ctx, database.ResetUserPasswordParams{
+			ID:             reset.UserID,
+			Email:          reset.Email,
+			HashedPassword: hashed,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := db.RevokePasswordResets(ctx, reset.UserID); err != nil {
+			return err
+		}
+		return db.RevokeUserRefreshTokens(ctx, reset.UserID)
+	})
+}
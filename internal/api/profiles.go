@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/media"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxDisplayName = 50
+	maxBio         = 160
+	maxWebsite     = 200
+)
+
+// handlePattern is what a handle may look like. Handles are compared without
+// regard to case but shown as their owner typed them.
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+
+// reservedHandles would clash with routes under /api/users.
+var reservedHandles = map[string]bool{
+	"me": true,
+}
+
+var errHandleTaken = errors.New("Handle is taken")
+
+// profileBody is what anyone may see of a user: never their email.
+type profileBody struct {
+	Id             string `json:"id"`
+	Handle         string `json:"handle,omitempty"`
+	DisplayName    string `json:"display_name"`
+	Bio            string `json:"bio"`
+	Website        string `json:"website"`
+	ChirpyRed      bool   `json:"is_chirpy_red"`
+	ChirpCount     int64  `json:"chirp_count"`
+	FollowerCount  int64  `json:"follower_count"`
+	FollowingCount int64  `json:"following_count"`
+	CreatedAt      string `json:"created_at"`
+	// Avatar and Banner map each rendition's size, such as "96x96", to its
+	// URL. They are left out until an image is uploaded.
+	Avatar map[string]string `json:"avatar,omitempty"`
+	Banner map[string]string `json:"banner,omitempty"`
+}
+
+func newProfileBody(config *types.ApiConfig, user database.User, counts database.GetProfileCountsRow) profileBody {
+	return profileBody{
+		Id:             user.ID.String(),
+		Handle:         user.Handle.String,
+		DisplayName:    user.DisplayName,
+		Bio:            user.Bio,
+		Website:        user.Website,
+		ChirpyRed:      user.IsChirpyRed,
+		ChirpCount:     counts.ChirpCount,
+		FollowerCount:  counts.FollowerCount,
+		FollowingCount: counts.FollowingCount,
+		CreatedAt:      user.CreatedAt.String(),
+		Avatar:         imageURLs(config, media.Avatar, user.Avatar),
+		Banner:         imageURLs(config, media.Banner, user.Banner),
+	}
+}
+
+func validateHandle(handle string) error {
+	if !handlePattern.MatchString(handle) {
+		return errors.New("Handles are 3 to 30 letters, digits and underscores")
+	}
+	if reservedHandles[strings.ToLower(handle)] {
+		return fmt.Errorf("%q cannot be used as a handle", handle)
+	}
+	return nil
+}
+
+func validateWebsite(website string) error {
+	if website == "" {
+		return nil
+	}
+	if len(website) > maxWebsite {
+		return fmt.Errorf("Website is longer than %d characters", maxWebsite)
+	}
+	u, err := url.Parse(website)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("Website must be an http or https URL")
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is Postgres refusing a duplicate.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// lookupUser finds a user by UUID or, failing that, by handle.
+func lookupUser(ctx context.Context, db *database.Queries, idOrHandle string) (database.User, error) {
+	var user database.User
+	var err error
+	if id, parseErr := uuid.Parse(idOrHandle); parseErr == nil {
+		user, err = db.GetUser(ctx, id)
+	} else {
+		user, err = db.GetUserByHandle(ctx, idOrHandle)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, errUserNotFound
+	}
+	return user, err
+}
+
+// GetProfile shows the public profile of the user in the path, given by id
+// or handle.
+func GetProfile(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	user, err := lookupUser(r.Context(), config.Db, r.PathValue("id"))
+	var counts database.GetProfileCountsRow
+	if err == nil {
+		counts, err = config.Db.GetProfileCounts(r.Context(), user.ID)
+	}
+	if err != nil {
+		w.WriteHeader(chirpErrorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusOK, newProfileBody(config, user, counts))
+}
+
+// UpdateProfile changes the caller's handle, display name, bio or website.
+// Fields left out of the body keep their value; an empty handle removes it.
+func UpdateProfile(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		Website     *string `json:"website"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	user, err := config.Db.GetUser(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	params := database.UpdateProfileParams{
+		ID:          userID,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		Website:     user.Website,
+	}
+	if bodyData.Handle != nil {
+		params.Handle = sql.NullString{String: *bodyData.Handle, Valid: *bodyData.Handle != ""}
+		if params.Handle.Valid {
+			err = validateHandle(params.Handle.String)
+		}
+	}
+	if bodyData.DisplayName != nil {
+		params.DisplayName = strings.TrimSpace(*bodyData.DisplayName)
+		if len(params.DisplayName) > maxDisplayName {
+			err = fmt.Errorf("Display name is longer than %d characters", maxDisplayName)
+		}
+	}
+	if bodyData.Bio != nil {
+		params.Bio = strings.TrimSpace(*bodyData.Bio)
+		if len(params.Bio) > maxBio {
+			err = fmt.Errorf("Bio is longer than %d characters", maxBio)
+		}
+	}
+	if bodyData.Website != nil {
+		params.Website = strings.TrimSpace(*bodyData.Website)
+		if websiteErr := validateWebsite(params.Website); websiteErr != nil {
+			err = websiteErr
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	user, err = config.Db.UpdateProfile(r.Context(), params)
+	if isUniqueViolation(err) {
+		err = errHandleTaken
+	}
+	var counts database.GetProfileCountsRow
+	if err == nil {
+		counts, err = config.Db.GetProfileCounts(r.Context(), userID)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errHandleTaken) {
+			status = http.StatusConflict
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusOK, newProfileBody(config, user, counts))
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/dabates/httpServer/internal/ratelimit"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RateLimit counts every request against config.RateLimiter before handing
+// it to mux, which it also asks for the route pattern the limits are set
+// by. Signed in callers are limited by user id, everyone else by IP.
+// Requests are let through if the store fails, rather than taking the API
+// down with it.
+func RateLimit(config *types.ApiConfig, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if config.RateLimiter == nil {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		_, route := mux.Handler(r)
+		identity, tier := rateLimitIdentity(r, config)
+		limit, res, err := config.RateLimiter.Allow(r.Context(), route, identity, tier)
+		if err != nil {
+			log.Printf("ratelimit: %s: %v", route, err)
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		ratelimit.WriteHeaders(w, limit, res)
+		if !res.Allowed {
+			w.Header().Set("Cache-Control", CacheNoStore)
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(errRateLimited.Error()))
+			return
+		}
+
+		mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), chargedRouteKey{}, route)))
+	})
+}
+
+// chargedRouteKey holds the route RateLimit counted a request against, so
+// chargeRoute does not count it a second time.
+type chargedRouteKey struct{}
+
+// errRateLimited is the answer to requests over their route's limit.
+var errRateLimited = errors.New("Too many requests")
+
+func rateLimitIdentity(r *http.Request, config *types.ApiConfig) (string, ratelimit.Tier) {
+	userID, err := bearerUserID(r, config)
+	if err != nil {
+		return "ip:" + clientIP(r, config), ratelimit.Anonymous
+	}
+	return userRateLimitIdentity(r.Context(), config, userID)
+}
+
+func userRateLimitIdentity(ctx context.Context, config *types.ApiConfig, userID uuid.UUID) (string, ratelimit.Tier) {
+	tier := ratelimit.User
+	user, err := config.Db.GetUser(ctx, userID)
+	if err == nil && user.IsChirpyRed {
+		tier = ratelimit.Red
+	}
+	return "user:" + userID.String(), tier
+}
+
+// chargeRoute counts a request to route against the user, from the same
+// bucket as RateLimit does, for work that other routes and transports do on
+// route's behalf, so that they cannot be used to get around its limit. A
+// request RateLimit already counted against route is not counted again. It
+// returns errRateLimited when the bucket is empty, and lets the work through
+// if the store fails, as RateLimit does.
+func chargeRoute(ctx context.Context, config *types.ApiConfig, userID uuid.UUID, route string) error {
+	if config.RateLimiter == nil {
+		return nil
+	}
+	if charged, _ := ctx.Value(chargedRouteKey{}).(string); charged == route {
+		return nil
+	}
+
+	identity, tier := userRateLimitIdentity(ctx, config, userID)
+	_, res, err := config.RateLimiter.Allow(ctx, route, identity, tier)
+	if err != nil {
+		log.Printf("ratelimit: %s: %v", route, err)
+		return nil
+	}
+	if !res.Allowed {
+		return errRateLimited
+	}
+	return nil
+}
+
+// clientIP is the address the request came from, without the port. When
+// the connection comes from one of config.TrustedProxies, it is the last
+// address in X-Forwarded-For that is not a trusted proxy, so users behind a
+// load balancer are not all counted as one address.
+func clientIP(r *http.Request, config *types.ApiConfig) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trustedProxy(host, config.TrustedProxies) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		host = hop
+		if !trustedProxy(hop, config.TrustedProxies) {
+			break
+		}
+	}
+	return host
+}
+
+func trustedProxy(host string, proxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	for _, proxy := range proxies {
+		if proxy.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"github.com/dabates/httpServer/internal/types"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIPTrustsOnlyConfiguredProxies(t *testing.T) {
+	config := &types.ApiConfig{
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	}
+
+	cases := []struct {
+		remote    string
+		forwarded string
+		want      string
+	}{
+		{"203.0.113.7:4000", "", "203.0.113.7"},
+		// Anyone can send the header; it only counts from a proxy.
+		{"203.0.113.7:4000", "198.51.100.1", "203.0.113.7"},
+		{"10.0.0.2:4000", "198.51.100.1", "198.51.100.1"},
+		// A client's own X-Forwarded-For entries are to the left of the
+		// ones the proxies added, and are not believed.
+		{"10.0.0.2:4000", "1.2.3.4, 198.51.100.1, 10.0.0.3", "198.51.100.1"},
+		{"10.0.0.2:4000", "10.0.0.4, 10.0.0.3", "10.0.0.4"},
+		{"10.0.0.2:4000", "", "10.0.0.2"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "/api/login", nil)
+		r.RemoteAddr = c.remote
+		if c.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if got := clientIP(r, config); got != c.want {
+			t.Errorf("%s with X-Forwarded-For %q: got %s, want %s", c.remote, c.forwarded, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/google/uuid"
+)
+
+var (
+	errUserNotFound = errors.New("User not found")
+	errFollowSelf   = errors.New("Users cannot follow themselves")
+)
+
+// likeChirp records that userID likes chirp chirpID. Liking twice is a no-op.
+func likeChirp(ctx context.Context, db *database.Queries, userID uuid.UUID, chirpID uuid.UUID) error {
+	chirp, err := db.GetVisibleChirp(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errChirpNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkNotBlocked(ctx, db, chirp.UserID, userID); err != nil {
+		return err
+	}
+
+	liked, err := db.LikeChirp(ctx, database.LikeChirpParams{
+		ChirpID: chirpID,
+		UserID:  userID,
+	})
+	if err != nil || liked == 0 {
+		return err
+	}
+	return notify(ctx, db, chirp.UserID, userID, notifyLike, uuid.NullUUID{UUID: chirpID, Valid: true})
+}
+
+// followUser makes followerID follow followeeID. Following twice is a no-op.
+func followUser(ctx context.Context, db *database.Queries, followerID uuid.UUID, followeeID uuid.UUID) error {
+	if followerID == followeeID {
+		return errFollowSelf
+	}
+
+	_, err := db.GetUser(ctx, followeeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkNotBlocked(ctx, db, followeeID, followerID); err != nil {
+		return err
+	}
+
+	followed, err := db.FollowUser(ctx, database.FollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil || followed == 0 {
+		return err
+	}
+	return notify(ctx, db, followeeID, followerID, notifyFollow, uuid.NullUUID{})
+}
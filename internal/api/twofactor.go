@@ -0,0 +1,536 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/dabates/httpServer/internal/auth"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/totp"
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+// totpIssuer names the account in authenticator apps.
+const totpIssuer = "Chirpy"
+
+var (
+	errTwoFactorOff     = errors.New("Two-factor authentication is not available on this server")
+	errTwoFactorOn      = errors.New("Two-factor authentication is already on")
+	errTwoFactorPending = errors.New("Start two-factor setup first")
+	errTwoFactorNotOn   = errors.New("Two-factor authentication is off")
+	errInvalidCode      = errors.New("Invalid code")
+	errWrongPassword    = errors.New("Invalid password")
+)
+
+// twoFactorEnabled reports whether the user has confirmed a TOTP secret.
+func twoFactorEnabled(ctx context.Context, config *types.ApiConfig, userID uuid.UUID) (bool, error) {
+	secret, err := config.Db.GetTotpSecret(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return secret.ConfirmedAt.Valid, nil
+}
+
+// SetupTwoFactor starts turning on two-factor authentication with a new
+// secret, as a URI and a QR code for an authenticator app. It stays off
+// until ConfirmTwoFactor gets a code from the app; starting over replaces
+// the secret. The caller must confirm their password.
+func SetupTwoFactor(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Password string `json:"password"`
+	}
+	type respBody struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauth_uri"`
+		// QRCode is a PNG of URI.
+		QRCode []byte `json:"qr_code"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if config.TOTP == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(errTwoFactorOff.Error()))
+		return
+	}
+
+	user, err := config.Db.GetUser(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if !confirmPassword(w, r, config, user, bodyData.Password) {
+		return
+	}
+
+	secret := totp.NewSecret()
+	uri := totp.URI(totpIssuer, user.Email, secret)
+	qrCode, err := totp.QRCode(uri, 256)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	rows, err := config.Db.SetPendingTotpSecret(r.Context(), database.SetPendingTotpSecretParams{
+		UserID: userID,
+		Secret: config.TOTP.Seal(secret),
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if rows == 0 {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(errTwoFactorOn.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusOK, respBody{
+		Secret: totp.EncodeSecret(secret),
+		URI:    uri,
+		QRCode: qrCode,
+	})
+}
+
+// ConfirmTwoFactor turns two-factor authentication on once the user sends
+// a code from their app, and answers with their recovery codes. They are
+// only ever shown here, and each can stand in for a code once.
+func ConfirmTwoFactor(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Code string `json:"code"`
+	}
+	type respBody struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if config.TOTP == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(errTwoFactorOff.Error()))
+		return
+	}
+
+	pending, err := config.Db.GetTotpSecret(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(errTwoFactorPending.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if pending.ConfirmedAt.Valid {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(errTwoFactorOn.Error()))
+		return
+	}
+
+	secret, err := config.TOTP.Open(pending.Secret)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	step, ok := totp.Validate(secret, bodyData.Code, time.Now())
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(errInvalidCode.Error()))
+		return
+	}
+
+	var codes []string
+	err = withTx(r.Context(), config, func(db *database.Queries) error {
+		rows, err := db.ConfirmTotpSecret(r.Context(), database.ConfirmTotpSecretParams{
+			UserID:   userID,
+			LastStep: step,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return errTwoFactorOn
+		}
+		codes, err = replaceRecoveryCodes(r.Context(), db, userID)
+		return err
+	})
+	if errors.Is(err, errTwoFactorOn) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusOK, respBody{RecoveryCodes: codes})
+}
+
+// sendLoginChallenge answers a right password from a user with two-factor
+// authentication with a challenge token, good for five minutes, to send to
+// LoginTwoFactor along with a code.
+func sendLoginChallenge(w http.ResponseWriter, r *http.Request, enc encoder, config *types.ApiConfig, user database.User) {
+	type respBody struct {
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		ChallengeToken    string `json:"challenge_token"`
+		ExpiresAt         string `json:"expires_at"`
+	}
+
+	token, hash := auth.MakeSecretToken()
+	challenge, err := config.Db.CreateLoginChallenge(r.Context(), database.CreateLoginChallengeParams{
+		UserID:    user.ID,
+		TokenHash: hash,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusAccepted, respBody{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         challenge.ExpiresAt.Format(time.RFC3339),
+	})
+}
+
+// checkSecondFactor reports whether code is a current TOTP code or an
+// unused recovery code of the user's, using it up if so. db may be a
+// transaction, so the code is only used up if it commits.
+func checkSecondFactor(ctx context.Context, config *types.ApiConfig, db *database.Queries, userID uuid.UUID, code string) (bool, error) {
+	if !totp.LooksLikeCode(code) {
+		rows, err := db.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashSecretToken(totp.NormalizeRecoveryCode(code)),
+		})
+		return rows == 1, err
+	}
+
+	if config.TOTP == nil {
+		return false, errTwoFactorOff
+	}
+	stored, err := db.GetTotpSecret(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	secret, err := config.TOTP.Open(stored.Secret)
+	if err != nil {
+		return false, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	rows, err := db.UseTotpStep(ctx, database.UseTotpStepParams{
+		UserID:   userID,
+		LastStep: step,
+	})
+	return rows == 1, err
+}
+
+// LoginTwoFactor finishes a login that Login answered with a challenge,
+// issuing tokens for a code from the user's app or one of their recovery
+// codes. Wrong codes count as failed logins.
+func LoginTwoFactor(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err := json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	challenge, err := config.Db.GetLoginChallengeByHash(r.Context(), auth.HashSecretToken(bodyData.ChallengeToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(errInvalidToken.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	user, err := config.Db.GetUser(r.Context(), challenge.UserID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	attempt, ok := beginLoginAttempt(w, r, config, loginKeys(r, config, user, true, ""))
+	if !ok {
+		return
+	}
+
+	// The challenge and the code are used up together, so a challenge that
+	// was already used, or a login refused for a suspension, leaves the code
+	// for another try. Each challenge finishes one login.
+	err = withTx(r.Context(), config, func(db *database.Queries) error {
+		rows, err := db.DeleteLoginChallenge(r.Context(), challenge.ID)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return errInvalidToken
+		}
+
+		ok, err := checkSecondFactor(r.Context(), config, db, user.ID, bodyData.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errInvalidCode
+		}
+
+		if user.SuspendedAt.Valid {
+			return errUserSuspended
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errInvalidToken):
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	case errors.Is(err, errInvalidCode):
+		failLoginAttempt(config, attempt, user, true)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	case errors.Is(err, errUserSuspended):
+		succeedLoginAttempt(r.Context(), config, attempt)
+		refuseSuspended(w, enc, config, user.ID)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	succeedLoginAttempt(r.Context(), config, attempt)
+	issueLogin(w, enc, config, user)
+}
+
+// confirmPassword checks the password a signed-in user sent to confirm a
+// change to how they sign in, so that a stolen access token alone cannot
+// make it. Wrong passwords count as failed logins.
+func confirmPassword(w http.ResponseWriter, r *http.Request, config *types.ApiConfig, user database.User, password string) bool {
+	attempt, ok := beginLoginAttempt(w, r, config, loginKeys(r, config, user, true, ""))
+	if !ok {
+		return false
+	}
+	if !auth.CheckPasswordHash(password, user.HashedPassword) {
+		failLoginAttempt(config, attempt, user, true)
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(errWrongPassword.Error()))
+		return false
+	}
+	forgiveLoginAttempt(r.Context(), config, attempt.keys)
+	return true
+}
+
+// replaceRecoveryCodes gives the user a new set of recovery codes in place
+// of any they had, returning them; only their hashes are stored.
+func replaceRecoveryCodes(ctx context.Context, db *database.Queries, userID uuid.UUID) ([]string, error) {
+	codes := totp.NewRecoveryCodes(totp.RecoveryCodeCount)
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashSecretToken(totp.NormalizeRecoveryCode(code))
+	}
+
+	if err := db.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+	err := db.CreateRecoveryCodes(ctx, database.CreateRecoveryCodesParams{
+		UserID:     userID,
+		CodeHashes: hashes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes with new
+// ones, once they confirm their password. The old codes stop working.
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Password string `json:"password"`
+	}
+	type respBody struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	user, err := config.Db.GetUser(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if !confirmPassword(w, r, config, user, bodyData.Password) {
+		return
+	}
+
+	enabled, err := twoFactorEnabled(r.Context(), config, userID)
+	if err == nil && !enabled {
+		err = errTwoFactorNotOn
+	}
+	var codes []string
+	if err == nil {
+		err = withTx(r.Context(), config, func(db *database.Queries) error {
+			codes, err = replaceRecoveryCodes(r.Context(), db, userID)
+			return err
+		})
+	}
+	if errors.Is(err, errTwoFactorNotOn) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusOK, respBody{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor turns two-factor authentication off, once the caller
+// confirms their password, deleting their secret and recovery codes. It
+// also drops a setup that was never confirmed.
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Password string `json:"password"`
+	}
+
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	bodyData := reqBody{}
+	err = json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	user, err := config.Db.GetUser(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if !confirmPassword(w, r, config, user, bodyData.Password) {
+		return
+	}
+
+	err = withTx(r.Context(), config, func(db *database.Queries) error {
+		rows, err := db.DeleteTotpSecret(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return errTwoFactorNotOn
+		}
+		return db.DeleteRecoveryCodes(r.Context(), userID)
+	})
+	if errors.Is(err, errTwoFactorNotOn) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
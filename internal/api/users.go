@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/dabates/httpServer/internal/auth"
 	"github.com/dabates/httpServer/internal/database"
 	"github.com/dabates/httpServer/internal/types"
@@ -10,6 +11,8 @@ import (
 	"net/http"
 )
 
+var errEmailTaken = errors.New("Email is already registered")
+
 type reqBody struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -20,120 +23,157 @@ type respBody struct {
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
 	Email     string `json:"email"`
+	Handle    string `json:"handle,omitempty"`
 	ChirpyRed bool   `json:"is_chirpy_red"`
+	// EmailVerified is false until the address is verified; unverified users
+	// cannot post chirps or send messages.
+	EmailVerified bool `json:"email_verified"`
 }
 
 func CreateUser(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
 
 	bodyData := reqBody{}
 	err := json.NewDecoder(r.Body).Decode(&bodyData)
 	if err != nil {
-		log.Fatal(err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
 	}
 
-	if bodyData.Email == "" {
-		log.Fatal("Email is empty")
+	err = validateEmail(bodyData.Email)
+	if err == nil {
+		err = checkPassword(config, bodyData.Password, bodyData.Email)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
 	}
 
 	password, err := auth.HashPassword(bodyData.Password)
 	if err != nil {
-		log.Fatal(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
 	}
 
 	user, err := config.Db.CreateUser(r.Context(), database.CreateUserParams{
 		Email:          bodyData.Email,
 		HashedPassword: password,
 	})
-
+	if isUniqueViolation(err) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(errEmailTaken.Error()))
+		return
+	}
 	if err != nil {
-		log.Fatal(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
 	}
 
-	resp := respBody{
-		Id:        user.ID.String(),
-		CreatedAt: user.CreatedAt.String(),
-		UpdatedAt: user.UpdatedAt.String(),
-		Email:     user.Email,
-		ChirpyRed: user.IsChirpyRed,
+	if err := sendVerification(r.Context(), config, user); err != nil {
+		log.Printf("users: sending verification email: %v", err)
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		log.Fatal(err)
+	resp := respBody{
+		Id:            user.ID.String(),
+		CreatedAt:     user.CreatedAt.String(),
+		UpdatedAt:     user.UpdatedAt.String(),
+		Email:         user.Email,
+		Handle:        user.Handle.String,
+		ChirpyRed:     user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	w.Header().Set("content-type", "application/json")
-	w.Write(data)
+	respond(w, enc, http.StatusCreated, resp)
 }
 
 func UpdateUser(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
-	token, err := auth.GetBearerToken(r.Header)
+	userId, err := authenticate(r, config)
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		w.Write([]byte(err.Error()))
 		return
 	}
 
-	userId, err := auth.ValidateJWT(token, config.Secret)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(err.Error()))
+	enc, ok := negotiate(w, r, false)
+	if !ok {
 		return
 	}
 
 	bodyData := reqBody{}
 	err = json.NewDecoder(r.Body).Decode(&bodyData)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(err.Error()))
 		return
 	}
 
-	if bodyData.Email == "" {
-		log.Fatal("Email is empty")
+	before, err := config.Db.GetUser(r.Context(), userId)
+	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("No email was provided"))
+		w.Write([]byte(err.Error()))
 		return
 	}
 
-	if bodyData.Password == "" {
-		log.Fatal("Password is empty")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("No password was provided"))
+	err = validateEmail(bodyData.Email)
+	if err == nil {
+		err = checkPassword(config, bodyData.Password, bodyData.Email, before.Email, before.Handle.String)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
 		return
 	}
 
 	password, err := auth.HashPassword(bodyData.Password)
 	if err != nil {
-		log.Fatal(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
 	}
 
 	user, err := config.Db.UpdateUser(context.Background(), database.UpdateUserParams{
-		userId,
-		bodyData.Email,
-		password,
+		ID:             userId,
+		Email:          bodyData.Email,
+		HashedPassword: password,
 	})
+	if isUniqueViolation(err) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(errEmailTaken.Error()))
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(err.Error()))
 		return
 	}
 
-	resp := respBody{
-		Id:        user.ID.String(),
-		CreatedAt: user.CreatedAt.String(),
-		UpdatedAt: user.UpdatedAt.String(),
-		Email:     user.Email,
-		ChirpyRed: user.IsChirpyRed,
+	// A new address has to be verified again.
+	if user.Email != before.Email {
+		err := config.Db.RevokeEmailVerifications(r.Context(), user.ID)
+		if err == nil {
+			err = sendVerification(r.Context(), config, user)
+		}
+		if err != nil {
+			log.Printf("users: sending verification email: %v", err)
+		}
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		log.Fatal(err)
+	resp := respBody{
+		Id:            user.ID.String(),
+		CreatedAt:     user.CreatedAt.String(),
+		UpdatedAt:     user.UpdatedAt.String(),
+		Email:         user.Email,
+		Handle:        user.Handle.String,
+		ChirpyRed:     user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("content-type", "application/json")
-	w.Write(data)
+	respond(w, enc, http.StatusOK, resp)
 }
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"github.com/dabates/httpServer/internal/types"
+	"github.com/lib/pq"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateUserRejectsMalformedJSON(t *testing.T) {
+	config := &types.ApiConfig{}
+	config.DB, config.Db = newFakeDB().open()
+
+	r := httptest.NewRequest("POST", "/api/users", strings.NewReader(`{"email": `))
+	w := httptest.NewRecorder()
+	CreateUser(w, r, config)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestCreateUserAnswersDatabaseErrors(t *testing.T) {
+	config := &types.ApiConfig{}
+	config.DB, config.Db = newFakeDB().open()
+
+	r := httptest.NewRequest("POST", "/api/users", strings.NewReader(`{"email": "new@example.com", "password": "correct horse battery staple"}`))
+	w := httptest.NewRecorder()
+	CreateUser(w, r, config)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestCreateUserRefusesTakenEmail(t *testing.T) {
+	db := newFakeDB()
+	db.errs["CreateUser"] = &pq.Error{Code: "23505"}
+	config := &types.ApiConfig{}
+	config.DB, config.Db = db.open()
+
+	r := httptest.NewRequest("POST", "/api/users", strings.NewReader(`{"email": "taken@example.com", "password": "correct horse battery staple"}`))
+	w := httptest.NewRecorder()
+	CreateUser(w, r, config)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected 409, got %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dabates/httpServer/internal/auth"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/mailer"
+	"github.com/dabates/httpServer/internal/types"
+	"net/http"
+	"net/mail"
+)
+
+// verifyEmailPurpose keeps verification tokens from being used as any other
+// kind of signed token.
+const verifyEmailPurpose = "verify-email"
+
+var (
+	errEmailUnverified = errors.New("Verify your email address first")
+	errAlreadyVerified = errors.New("Email address is already verified")
+	errInvalidToken    = errors.New("Invalid or expired token")
+	errInvalidEmail    = errors.New("Invalid email address")
+)
+
+// validateEmail accepts a bare address such as "a@example.com", without a
+// display name.
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return errInvalidEmail
+	}
+	return nil
+}
+
+// sendVerification emails user a token that verifies their current address.
+// It is valid for 24 hours, and only until the address changes.
+func sendVerification(ctx context.Context, config *types.ApiConfig, user database.User) error {
+	if config.Mailer == nil {
+		return nil
+	}
+
+	verification, err := config.Db.CreateEmailVerification(ctx, database.CreateEmailVerificationParams{
+		UserID: user.ID,
+		Email:  user.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	token := auth.MakeSignedToken(verifyEmailPurpose, verification.ID, user.Email, config.Secret)
+	return config.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf("Welcome to Chirpy!\n\n"+
+			"To verify your email address, send this token to POST /api/users/verify:\n\n"+
+			"%s\n\n"+
+			"It expires in 24 hours. If you did not sign up, ignore this email.\n", token),
+	})
+}
+
+// VerifyEmail marks the address a verification token was sent to as
+// verified. Each token works once.
+func VerifyEmail(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	type reqBody struct {
+		Token string `json:"token"`
+	}
+
+	enc, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
+	bodyData := reqBody{}
+	err := json.NewDecoder(r.Body).Decode(&bodyData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	user, err := verifyEmail(r.Context(), config, bodyData.Token)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errInvalidToken) {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	respond(w, enc, http.StatusOK, respBody{
+		Id:            user.ID.String(),
+		CreatedAt:     user.CreatedAt.String(),
+		UpdatedAt:     user.UpdatedAt.String(),
+		Email:         user.Email,
+		Handle:        user.Handle.String,
+		ChirpyRed:     user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+	})
+}
+
+func verifyEmail(ctx context.Context, config *types.ApiConfig, token string) (database.User, error) {
+	id, err := auth.SignedTokenID(token)
+	if err != nil {
+		return database.User{}, errInvalidToken
+	}
+
+	verification, err := config.Db.GetEmailVerification(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, errInvalidToken
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	if !auth.CheckSignedToken(token, verifyEmailPurpose, verification.Email, config.Secret) {
+		return database.User{}, errInvalidToken
+	}
+
+	var user database.User
+	err = withTx(ctx, config, func(db *database.Queries) error {
+		used, err := db.UseEmailVerification(ctx, verification.ID)
+		if err != nil {
+			return err
+		}
+		if used == 0 {
+			return errInvalidToken
+		}
+
+		// The address may have changed since the token was sent.
+		user, err = db.MarkEmailVerified(ctx, database.MarkEmailVerifiedParams{
+			ID:    verification.UserID,
+			Email: verification.Email,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errInvalidToken
+		}
+		return err
+	})
+	return user, err
+}
+
+// ResendVerification sends the caller a new verification token, which
+// replaces any sent before.
+func ResendVerification(w http.ResponseWriter, r *http.Request, config *types.ApiConfig) {
+	userID, err := authenticate(r, config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	user, err := config.Db.GetUser(r.Context(), userID)
+	if err == nil && user.EmailVerifiedAt.Valid {
+		err = errAlreadyVerified
+	}
+	if err == nil {
+		err = config.Db.RevokeEmailVerifications(r.Context(), userID)
+	}
+	if err == nil {
+		err = sendVerification(r.Context(), config, user)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errAlreadyVerified) {
+			status = http.StatusConflict
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -1,9 +1,10 @@
 package api
 
 import (
-	"context"
 	"encoding/json"
 	"github.com/dabates/httpServer/internal/auth"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/events"
 	"github.com/dabates/httpServer/internal/types"
 	"github.com/google/uuid"
 	"log"
@@ -50,12 +51,21 @@ func PolkaWebhook(w http.ResponseWriter, r *http.Request, config *types.ApiConfi
 			return
 		}
 
-		_, err = config.Db.UpdateUserRedStatus(context.Background(), userId)
+		var user database.User
+		err = withTx(r.Context(), config, func(db *database.Queries) error {
+			var err error
+			user, err = db.UpdateUserRedStatus(r.Context(), userId)
+			if err != nil {
+				return err
+			}
+			return enqueueWebhooks(r.Context(), config, db, events.Event{Type: events.UserUpgraded, User: user})
+		})
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
+		config.Events.Publish(events.Event{Type: events.UserUpgraded, User: user})
 
 		w.WriteHeader(http.StatusNoContent)
 	}
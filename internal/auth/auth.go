@@ -2,39 +2,165 @@ package auth
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/dabates/httpServer/internal/database"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
+// ErrEmptyPassword is returned by HashPassword for an empty password, which
+// would otherwise hash like any other.
+var ErrEmptyPassword = errors.New("password is empty")
+
+// Argon2Params are the argon2id cost parameters new hashes are made with.
+type Argon2Params struct {
+	// Time is the number of passes over the memory.
+	Time uint32
+	// Memory is in KiB.
+	Memory  uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultArgon2Params follows OWASP's minimum recommendation for argon2id:
+// 19 MiB of memory, two passes and one thread.
+func DefaultArgon2Params() Argon2Params {
+	return Argon2Params{
+		Time:    2,
+		Memory:  19 * 1024,
+		Threads: 1,
+		SaltLen: 16,
+		KeyLen:  32,
+	}
+}
+
+var (
+	argon2Mu     sync.RWMutex
+	argon2Params = DefaultArgon2Params()
+)
+
+// SetArgon2Params changes the parameters HashPassword uses. Hashes made with
+// other parameters keep verifying, and NeedsRehash reports them.
+func SetArgon2Params(p Argon2Params) {
+	argon2Mu.Lock()
+	defer argon2Mu.Unlock()
+	argon2Params = p
+}
+
+func currentArgon2Params() Argon2Params {
+	argon2Mu.RLock()
+	defer argon2Mu.RUnlock()
+	return argon2Params
+}
+
+// BenchmarkArgon2 returns how long hashing one password takes with p.
+func BenchmarkArgon2(p Argon2Params) time.Duration {
+	start := time.Now()
+	hashArgon2("benchmark password", p)
+	return time.Since(start)
+}
+
+// HashPassword hashes password with argon2id and the current parameters, in
+// the PHC string format: $argon2id$v=19$m=<KiB>,t=<passes>,p=<threads>$<salt>$<hash>,
+// with the salt and hash in unpadded base64.
 func HashPassword(password string) (string, error) {
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
+	if password == "" {
+		return "", ErrEmptyPassword
+	}
+
+	return hashArgon2(password, currentArgon2Params()), nil
+}
+
+func hashArgon2(password string, p Argon2Params) string {
+	salt := make([]byte, p.SaltLen)
+	rand.Read(salt)
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// parseArgon2 splits an argon2id PHC string into its parameters, salt and
+// key.
+func parseArgon2(hash string) (Argon2Params, []byte, []byte, bool) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" || parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return Argon2Params{}, nil, nil, false
 	}
 
-	return string(hashedBytes), nil
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return Argon2Params{}, nil, nil, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 || p.Time == 0 || p.Threads == 0 {
+		return Argon2Params{}, nil, nil, false
+	}
+	p.SaltLen = uint32(len(salt))
+	p.KeyLen = uint32(len(key))
+	return p, salt, key, true
 }
 
+// CheckPasswordHash reports whether password matches hash, which may be an
+// argon2id hash from HashPassword or a bcrypt hash from before it.
 func CheckPasswordHash(password, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, salt, key, ok := parseArgon2(hash)
+		if !ok {
+			return false
+		}
+		got := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+		return subtle.ConstantTimeCompare(got, key) == 1
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
 
+// NeedsRehash reports whether hash was made with anything other than argon2id
+// and the current parameters, so it should be replaced the next time the
+// password is at hand.
+func NeedsRehash(hash string) bool {
+	p, _, _, ok := parseArgon2(hash)
+	return !ok || p != currentArgon2Params()
+}
+
 func MakeJWT(userid uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return MakeScopedJWT(userid, tokenSecret, expiresIn, "")
+}
+
+// MakeScopedJWT makes a JWT that only ValidateScopedJWT with the same scope
+// accepts, for tokens that must not work as full access tokens. An empty
+// scope makes a full access token.
+func MakeScopedJWT(userid uuid.UUID, tokenSecret string, expiresIn time.Duration, scope string) (string, error) {
 	claims := jwt.RegisteredClaims{
 		Issuer:    "chirpy",
 		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
 		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
 		Subject:   userid.String(),
 	}
+	if scope != "" {
+		claims.Audience = jwt.ClaimStrings{scope}
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	signedToken, err := token.SignedString([]byte(tokenSecret))
@@ -45,7 +171,13 @@ func MakeJWT(userid uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 	return signedToken, nil
 }
 
+// ValidateJWT accepts full access tokens only, not scoped ones.
 func ValidateJWT(token string, tokenSecret string) (uuid.UUID, error) {
+	return ValidateScopedJWT(token, tokenSecret, "")
+}
+
+// ValidateScopedJWT accepts tokens MakeScopedJWT made with scope.
+func ValidateScopedJWT(token string, tokenSecret string, scope string) (uuid.UUID, error) {
 	t, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(tokenSecret), nil
 	})
@@ -53,6 +185,12 @@ func ValidateJWT(token string, tokenSecret string) (uuid.UUID, error) {
 		return uuid.Nil, err
 	}
 	claims := t.Claims.(*jwt.RegisteredClaims)
+	if scope == "" && len(claims.Audience) > 0 {
+		return uuid.Nil, errors.New("token is scoped to " + strings.Join(claims.Audience, ", "))
+	}
+	if scope != "" && (len(claims.Audience) != 1 || claims.Audience[0] != scope) {
+		return uuid.Nil, errors.New("token is not scoped to " + scope)
+	}
 	return uuid.Parse(claims.Subject)
 }
 
@@ -121,3 +259,53 @@ func GetApiKey(headers http.Header) (string, error) {
 
 	return auth, nil
 }
+
+// MakeSecretToken returns a random token to hand to a user, and the hash to
+// store in its place so a leaked table does not leak usable tokens.
+func MakeSecretToken() (token string, hash string) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	token = hex.EncodeToString(key)
+	return token, HashSecretToken(token)
+}
+
+// HashSecretToken returns the hash MakeSecretToken stored for token.
+func HashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MakeSignedToken returns a token of the form "<id>.<mac>", where the MAC is
+// an HMAC-SHA256 over purpose, id and value keyed with secret. The token
+// stops checking out as soon as value changes, so binding it to, say, an
+// email address invalidates it when the address changes.
+func MakeSignedToken(purpose string, id uuid.UUID, value string, secret string) string {
+	return id.String() + "." + signToken(purpose, id, value, secret)
+}
+
+func signToken(purpose string, id uuid.UUID, value string, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(purpose + "|" + id.String() + "|" + value))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SignedTokenID returns the id a token from MakeSignedToken was made for,
+// without checking its MAC.
+func SignedTokenID(token string) (uuid.UUID, error) {
+	id, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, errors.New("malformed token")
+	}
+	return uuid.Parse(id)
+}
+
+// CheckSignedToken reports whether token was made by MakeSignedToken with
+// the same purpose, value and secret.
+func CheckSignedToken(token string, purpose string, value string, secret string) bool {
+	id, err := SignedTokenID(token)
+	if err != nil {
+		return false
+	}
+	_, mac, _ := strings.Cut(token, ".")
+	return hmac.Equal([]byte(mac), []byte(signToken(purpose, id, value, secret)))
+}
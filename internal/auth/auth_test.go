@@ -1,9 +1,11 @@
 package auth
 
 import (
+	"errors"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 )
@@ -18,13 +20,28 @@ func TestHashPassword(t *testing.T) {
 	}
 
 	// Check if the hashed password is valid
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
-		t.Fatalf("Password hash validation failed: %v", err)
+	if !strings.HasPrefix(hashedPassword, "$argon2id$v=19$") {
+		t.Fatalf("Expected an argon2id hash, got %q", hashedPassword)
+	}
+	if !CheckPasswordHash(password, hashedPassword) {
+		t.Fatalf("Password hash validation failed")
+	}
+	if CheckPasswordHash("wrongpassword", hashedPassword) {
+		t.Fatalf("Expected wrong password not to match the hash, but it did")
+	}
+	if NeedsRehash(hashedPassword) {
+		t.Fatalf("Expected a fresh hash not to need rehashing")
 	}
 
 	t.Log("Password hashing and validation succeeded")
 }
 
+func TestHashPasswordRefusesEmpty(t *testing.T) {
+	if _, err := HashPassword(""); !errors.Is(err, ErrEmptyPassword) {
+		t.Fatalf("Expected ErrEmptyPassword, got %v", err)
+	}
+}
+
 func TestCheckPasswordHash(t *testing.T) {
 	password := "securepassword123"
 
@@ -47,6 +64,47 @@ func TestCheckPasswordHash(t *testing.T) {
 	}
 
 	t.Log("CheckPasswordHash passed for both correct and incorrect passwords")
+
+	if !NeedsRehash(string(hashedPassword)) {
+		t.Fatalf("Expected a bcrypt hash to need rehashing")
+	}
+}
+
+func TestNeedsRehashAfterParamsChange(t *testing.T) {
+	defer SetArgon2Params(DefaultArgon2Params())
+
+	hashedPassword, err := HashPassword("securepassword123")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stronger := DefaultArgon2Params()
+	stronger.Time++
+	SetArgon2Params(stronger)
+
+	if !NeedsRehash(hashedPassword) {
+		t.Fatalf("Expected a hash with old parameters to need rehashing")
+	}
+	if !CheckPasswordHash("securepassword123", hashedPassword) {
+		t.Fatalf("Expected a hash with old parameters to keep verifying")
+	}
+}
+
+func TestCheckPasswordHashRejectsMalformedHashes(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$",
+		"$argon2id$v=18$m=19456,t=2,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=19456,t=0,p=1$c2FsdA$a2V5",
+		"$argon2i$v=19$m=19456,t=2,p=1$c2FsdA$a2V5",
+	} {
+		if CheckPasswordHash("password", hash) {
+			t.Errorf("Expected %q not to match", hash)
+		}
+		if !NeedsRehash(hash) {
+			t.Errorf("Expected %q to need rehashing", hash)
+		}
+	}
 }
 
 func TestMakeJWTAndValidateJWT(t *testing.T) {
@@ -84,6 +142,30 @@ func TestMakeJWTAndValidateJWT(t *testing.T) {
 	t.Log("MakeJWT and ValidateJWT passed for valid and expired tokens")
 }
 
+func TestScopedJWTsAreNotAccessTokens(t *testing.T) {
+	tokenSecret := "supersecretkey"
+	userID := uuid.New()
+
+	scoped, err := MakeScopedJWT(userID, tokenSecret, time.Minute, "appeal")
+	if err != nil {
+		t.Fatalf("Failed to generate scoped JWT: %v", err)
+	}
+	if _, err := ValidateJWT(scoped, tokenSecret); err == nil {
+		t.Fatal("Expected a scoped token not to pass as an access token")
+	}
+	if _, err := ValidateScopedJWT(scoped, tokenSecret, "other"); err == nil {
+		t.Fatal("Expected a token not to pass for another scope")
+	}
+	if got, err := ValidateScopedJWT(scoped, tokenSecret, "appeal"); err != nil || got != userID {
+		t.Fatalf("Expected the scoped token to pass for its scope, got %v, %v", got, err)
+	}
+
+	full, _ := MakeJWT(userID, tokenSecret, time.Minute)
+	if _, err := ValidateScopedJWT(full, tokenSecret, "appeal"); err == nil {
+		t.Fatal("Expected an access token not to pass as a scoped one")
+	}
+}
+
 func TestGetBearerToken(t *testing.T) {
 	// Case 1: Valid Bearer token
 	headers := http.Header{}
@@ -130,3 +212,44 @@ func TestGetBearerToken(t *testing.T) {
 
 	t.Log("GetBearerToken passed all test cases")
 }
+
+func TestSignedToken(t *testing.T) {
+	id := uuid.New()
+	token := MakeSignedToken("verify-email", id, "a@example.com", "secret")
+
+	got, err := SignedTokenID(token)
+	if err != nil || got != id {
+		t.Fatalf("Expected id %v, got %v (%v)", id, got, err)
+	}
+
+	if !CheckSignedToken(token, "verify-email", "a@example.com", "secret") {
+		t.Fatal("Expected the token to check out")
+	}
+	if CheckSignedToken(token, "verify-email", "b@example.com", "secret") {
+		t.Fatal("Expected a token for another value to be refused")
+	}
+	if CheckSignedToken(token, "reset-password", "a@example.com", "secret") {
+		t.Fatal("Expected a token for another purpose to be refused")
+	}
+	if CheckSignedToken(token, "verify-email", "a@example.com", "other") {
+		t.Fatal("Expected a token signed with another secret to be refused")
+	}
+	if _, err := SignedTokenID("garbage"); err == nil {
+		t.Fatal("Expected a malformed token to be refused")
+	}
+}
+
+func TestSecretToken(t *testing.T) {
+	token, hash := MakeSecretToken()
+	if len(token) != 64 || hash == token {
+		t.Fatalf("Expected a 64 character token and a distinct hash, got %q and %q", token, hash)
+	}
+	if HashSecretToken(token) != hash {
+		t.Fatal("Expected the token to hash to the stored hash")
+	}
+
+	other, _ := MakeSecretToken()
+	if other == token {
+		t.Fatal("Expected tokens to be random")
+	}
+}
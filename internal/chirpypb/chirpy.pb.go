@@ -0,0 +1,650 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: chirpy/v1/chirpy.proto
+
+package chirpypb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ChirpEvent_Type int32
+
+const (
+	ChirpEvent_TYPE_UNSPECIFIED ChirpEvent_Type = 0
+	ChirpEvent_TYPE_CREATED     ChirpEvent_Type = 1
+	ChirpEvent_TYPE_DELETED     ChirpEvent_Type = 2
+)
+
+// Enum value maps for ChirpEvent_Type.
+var (
+	ChirpEvent_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "TYPE_CREATED",
+		2: "TYPE_DELETED",
+	}
+	ChirpEvent_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"TYPE_CREATED":     1,
+		"TYPE_DELETED":     2,
+	}
+)
+
+func (x ChirpEvent_Type) Enum() *ChirpEvent_Type {
+	p := new(ChirpEvent_Type)
+	*p = x
+	return p
+}
+
+func (x ChirpEvent_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ChirpEvent_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_chirpy_v1_chirpy_proto_enumTypes[0].Descriptor()
+}
+
+func (ChirpEvent_Type) Type() protoreflect.EnumType {
+	return &file_chirpy_v1_chirpy_proto_enumTypes[0]
+}
+
+func (x ChirpEvent_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ChirpEvent_Type.Descriptor instead.
+func (ChirpEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirpy_proto_rawDescGZIP(), []int{8, 0}
+}
+
+type Chirp struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Body          string                 `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`
+	UserId        string                 `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chirp) Reset() {
+	*x = Chirp{}
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chirp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chirp) ProtoMessage() {}
+
+func (x *Chirp) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chirp.ProtoReflect.Descriptor instead.
+func (*Chirp) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirpy_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Chirp) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Chirp) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *Chirp) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *Chirp) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Chirp) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type CreateChirpRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Body          string                 `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateChirpRequest) Reset() {
+	*x = CreateChirpRequest{}
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateChirpRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateChirpRequest) ProtoMessage() {}
+
+func (x *CreateChirpRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateChirpRequest.ProtoReflect.Descriptor instead.
+func (*CreateChirpRequest) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirpy_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateChirpRequest) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+type GetChirpRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetChirpRequest) Reset() {
+	*x = GetChirpRequest{}
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetChirpRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetChirpRequest) ProtoMessage() {}
+
+func (x *GetChirpRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetChirpRequest.ProtoReflect.Descriptor instead.
+func (*GetChirpRequest) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirpy_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetChirpRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ListChirpsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Defaults to 20, at most 100.
+	PageSize int32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_page_token from a previous response.
+	PageToken string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	// Only list chirps by this user when set.
+	AuthorId      string `protobuf:"bytes,3,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListChirpsRequest) Reset() {
+	*x = ListChirpsRequest{}
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListChirpsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListChirpsRequest) ProtoMessage() {}
+
+func (x *ListChirpsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListChirpsRequest.ProtoReflect.Descriptor instead.
+func (*ListChirpsRequest) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirpy_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListChirpsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListChirpsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListChirpsRequest) GetAuthorId() string {
+	if x != nil {
+		return x.AuthorId
+	}
+	return ""
+}
+
+type ListChirpsResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Chirps []*Chirp               `protobuf:"bytes,1,rep,name=chirps,proto3" json:"chirps,omitempty"`
+	// Empty on the last page.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListChirpsResponse) Reset() {
+	*x = ListChirpsResponse{}
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListChirpsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListChirpsResponse) ProtoMessage() {}
+
+func (x *ListChirpsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListChirpsResponse.ProtoReflect.Descriptor instead.
+func (*ListChirpsResponse) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirpy_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListChirpsResponse) GetChirps() []*Chirp {
+	if x != nil {
+		return x.Chirps
+	}
+	return nil
+}
+
+func (x *ListChirpsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type DeleteChirpRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteChirpRequest) Reset() {
+	*x = DeleteChirpRequest{}
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteChirpRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteChirpRequest) ProtoMessage() {}
+
+func (x *DeleteChirpRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteChirpRequest.ProtoReflect.Descriptor instead.
+func (*DeleteChirpRequest) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirpy_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteChirpRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DeleteChirpResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteChirpResponse) Reset() {
+	*x = DeleteChirpResponse{}
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteChirpResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteChirpResponse) ProtoMessage() {}
+
+func (x *DeleteChirpResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteChirpResponse.ProtoReflect.Descriptor instead.
+func (*DeleteChirpResponse) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirpy_proto_rawDescGZIP(), []int{6}
+}
+
+type WatchChirpsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only stream chirps by this user when set.
+	AuthorId      string `protobuf:"bytes,1,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchChirpsRequest) Reset() {
+	*x = WatchChirpsRequest{}
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchChirpsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchChirpsRequest) ProtoMessage() {}
+
+func (x *WatchChirpsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchChirpsRequest.ProtoReflect.Descriptor instead.
+func (*WatchChirpsRequest) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirpy_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WatchChirpsRequest) GetAuthorId() string {
+	if x != nil {
+		return x.AuthorId
+	}
+	return ""
+}
+
+type ChirpEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          ChirpEvent_Type        `protobuf:"varint,1,opt,name=type,proto3,enum=chirpy.v1.ChirpEvent_Type" json:"type,omitempty"`
+	Chirp         *Chirp                 `protobuf:"bytes,2,opt,name=chirp,proto3" json:"chirp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChirpEvent) Reset() {
+	*x = ChirpEvent{}
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChirpEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChirpEvent) ProtoMessage() {}
+
+func (x *ChirpEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirpy_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChirpEvent.ProtoReflect.Descriptor instead.
+func (*ChirpEvent) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirpy_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ChirpEvent) GetType() ChirpEvent_Type {
+	if x != nil {
+		return x.Type
+	}
+	return ChirpEvent_TYPE_UNSPECIFIED
+}
+
+func (x *ChirpEvent) GetChirp() *Chirp {
+	if x != nil {
+		return x.Chirp
+	}
+	return nil
+}
+
+var File_chirpy_v1_chirpy_proto protoreflect.FileDescriptor
+
+const file_chirpy_v1_chirpy_proto_rawDesc = "" +
+	"\n" +
+	"\x16chirpy/v1/chirpy.proto\x12\tchirpy.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xba\x01\n" +
+	"\x05Chirp\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04body\x18\x02 \x01(\tR\x04body\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\tR\x06userId\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"(\n" +
+	"\x12CreateChirpRequest\x12\x12\n" +
+	"\x04body\x18\x01 \x01(\tR\x04body\"!\n" +
+	"\x0fGetChirpRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"l\n" +
+	"\x11ListChirpsRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x12\x1b\n" +
+	"\tauthor_id\x18\x03 \x01(\tR\bauthorId\"f\n" +
+	"\x12ListChirpsResponse\x12(\n" +
+	"\x06chirps\x18\x01 \x03(\v2\x10.chirpy.v1.ChirpR\x06chirps\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"$\n" +
+	"\x12DeleteChirpRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x15\n" +
+	"\x13DeleteChirpResponse\"1\n" +
+	"\x12WatchChirpsRequest\x12\x1b\n" +
+	"\tauthor_id\x18\x01 \x01(\tR\bauthorId\"\xa6\x01\n" +
+	"\n" +
+	"ChirpEvent\x12.\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x1a.chirpy.v1.ChirpEvent.TypeR\x04type\x12&\n" +
+	"\x05chirp\x18\x02 \x01(\v2\x10.chirpy.v1.ChirpR\x05chirp\"@\n" +
+	"\x04Type\x12\x14\n" +
+	"\x10TYPE_UNSPECIFIED\x10\x00\x12\x10\n" +
+	"\fTYPE_CREATED\x10\x01\x12\x10\n" +
+	"\fTYPE_DELETED\x10\x022\xe8\x02\n" +
+	"\fChirpService\x12>\n" +
+	"\vCreateChirp\x12\x1d.chirpy.v1.CreateChirpRequest\x1a\x10.chirpy.v1.Chirp\x128\n" +
+	"\bGetChirp\x12\x1a.chirpy.v1.GetChirpRequest\x1a\x10.chirpy.v1.Chirp\x12I\n" +
+	"\n" +
+	"ListChirps\x12\x1c.chirpy.v1.ListChirpsRequest\x1a\x1d.chirpy.v1.ListChirpsResponse\x12L\n" +
+	"\vDeleteChirp\x12\x1d.chirpy.v1.DeleteChirpRequest\x1a\x1e.chirpy.v1.DeleteChirpResponse\x12E\n" +
+	"\vWatchChirps\x12\x1d.chirpy.v1.WatchChirpsRequest\x1a\x15.chirpy.v1.ChirpEvent0\x01B:Z8github.com/dabates/httpServer/internal/chirpypb;chirpypbb\x06proto3"
+
+var (
+	file_chirpy_v1_chirpy_proto_rawDescOnce sync.Once
+	file_chirpy_v1_chirpy_proto_rawDescData []byte
+)
+
+func file_chirpy_v1_chirpy_proto_rawDescGZIP() []byte {
+	file_chirpy_v1_chirpy_proto_rawDescOnce.Do(func() {
+		file_chirpy_v1_chirpy_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_chirpy_v1_chirpy_proto_rawDesc), len(file_chirpy_v1_chirpy_proto_rawDesc)))
+	})
+	return file_chirpy_v1_chirpy_proto_rawDescData
+}
+
+var file_chirpy_v1_chirpy_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_chirpy_v1_chirpy_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_chirpy_v1_chirpy_proto_goTypes = []any{
+	(ChirpEvent_Type)(0),          // 0: chirpy.v1.ChirpEvent.Type
+	(*Chirp)(nil),                 // 1: chirpy.v1.Chirp
+	(*CreateChirpRequest)(nil),    // 2: chirpy.v1.CreateChirpRequest
+	(*GetChirpRequest)(nil),       // 3: chirpy.v1.GetChirpRequest
+	(*ListChirpsRequest)(nil),     // 4: chirpy.v1.ListChirpsRequest
+	(*ListChirpsResponse)(nil),    // 5: chirpy.v1.ListChirpsResponse
+	(*DeleteChirpRequest)(nil),    // 6: chirpy.v1.DeleteChirpRequest
+	(*DeleteChirpResponse)(nil),   // 7: chirpy.v1.DeleteChirpResponse
+	(*WatchChirpsRequest)(nil),    // 8: chirpy.v1.WatchChirpsRequest
+	(*ChirpEvent)(nil),            // 9: chirpy.v1.ChirpEvent
+	(*timestamppb.Timestamp)(nil), // 10: google.protobuf.Timestamp
+}
+var file_chirpy_v1_chirpy_proto_depIdxs = []int32{
+	10, // 0: chirpy.v1.Chirp.created_at:type_name -> google.protobuf.Timestamp
+	10, // 1: chirpy.v1.Chirp.updated_at:type_name -> google.protobuf.Timestamp
+	1,  // 2: chirpy.v1.ListChirpsResponse.chirps:type_name -> chirpy.v1.Chirp
+	0,  // 3: chirpy.v1.ChirpEvent.type:type_name -> chirpy.v1.ChirpEvent.Type
+	1,  // 4: chirpy.v1.ChirpEvent.chirp:type_name -> chirpy.v1.Chirp
+	2,  // 5: chirpy.v1.ChirpService.CreateChirp:input_type -> chirpy.v1.CreateChirpRequest
+	3,  // 6: chirpy.v1.ChirpService.GetChirp:input_type -> chirpy.v1.GetChirpRequest
+	4,  // 7: chirpy.v1.ChirpService.ListChirps:input_type -> chirpy.v1.ListChirpsRequest
+	6,  // 8: chirpy.v1.ChirpService.DeleteChirp:input_type -> chirpy.v1.DeleteChirpRequest
+	8,  // 9: chirpy.v1.ChirpService.WatchChirps:input_type -> chirpy.v1.WatchChirpsRequest
+	1,  // 10: chirpy.v1.ChirpService.CreateChirp:output_type -> chirpy.v1.Chirp
+	1,  // 11: chirpy.v1.ChirpService.GetChirp:output_type -> chirpy.v1.Chirp
+	5,  // 12: chirpy.v1.ChirpService.ListChirps:output_type -> chirpy.v1.ListChirpsResponse
+	7,  // 13: chirpy.v1.ChirpService.DeleteChirp:output_type -> chirpy.v1.DeleteChirpResponse
+	9,  // 14: chirpy.v1.ChirpService.WatchChirps:output_type -> chirpy.v1.ChirpEvent
+	10, // [10:15] is the sub-list for method output_type
+	5,  // [5:10] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_chirpy_v1_chirpy_proto_init() }
+func file_chirpy_v1_chirpy_proto_init() {
+	if File_chirpy_v1_chirpy_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_chirpy_v1_chirpy_proto_rawDesc), len(file_chirpy_v1_chirpy_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_chirpy_v1_chirpy_proto_goTypes,
+		DependencyIndexes: file_chirpy_v1_chirpy_proto_depIdxs,
+		EnumInfos:         file_chirpy_v1_chirpy_proto_enumTypes,
+		MessageInfos:      file_chirpy_v1_chirpy_proto_msgTypes,
+	}.Build()
+	File_chirpy_v1_chirpy_proto = out.File
+	file_chirpy_v1_chirpy_proto_goTypes = nil
+	file_chirpy_v1_chirpy_proto_depIdxs = nil
+}
@@ -0,0 +1,111 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: blocks.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const blockUser = `-- name: BlockUser :exec
+insert into blocks (blocker_id, blocked_id, created_at)
+values ($1, $2, now())
+on conflict do nothing
+`
+
+type BlockUserParams struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+}
+
+func (q *Queries) BlockUser(ctx context.Context, arg BlockUserParams) error {
+	_, err := q.db.ExecContext(ctx, blockUser, arg.BlockerID, arg.BlockedID)
+	return err
+}
+
+const deleteFollowsBetween = `-- name: DeleteFollowsBetween :exec
+delete
+from follows
+where (follower_id = $1 and followee_id = $2)
+   or (follower_id = $2 and followee_id = $1)
+`
+
+type DeleteFollowsBetweenParams struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+}
+
+func (q *Queries) DeleteFollowsBetween(ctx context.Context, arg DeleteFollowsBetweenParams) error {
+	_, err := q.db.ExecContext(ctx, deleteFollowsBetween, arg.FollowerID, arg.FolloweeID)
+	return err
+}
+
+const getBlocks = `-- name: GetBlocks :many
+select blocker_id, blocked_id, created_at
+from blocks
+where blocker_id = $1
+order by created_at desc
+`
+
+func (q *Queries) GetBlocks(ctx context.Context, blockerID uuid.UUID) ([]Block, error) {
+	rows, err := q.db.QueryContext(ctx, getBlocks, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Block
+	for rows.Next() {
+		var i Block
+		if err := rows.Scan(&i.BlockerID, &i.BlockedID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isBlocked = `-- name: IsBlocked :one
+select exists(select 1
+              from blocks
+              where blocker_id = $1
+                and blocked_id = $2)
+`
+
+type IsBlockedParams struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+}
+
+func (q *Queries) IsBlocked(ctx context.Context, arg IsBlockedParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isBlocked, arg.BlockerID, arg.BlockedID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const unblockUser = `-- name: UnblockUser :exec
+delete
+from blocks
+where blocker_id = $1
+  and blocked_id = $2
+`
+
+type UnblockUserParams struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+}
+
+func (q *Queries) UnblockUser(ctx context.Context, arg UnblockUserParams) error {
+	_, err := q.db.ExecContext(ctx, unblockUser, arg.BlockerID, arg.BlockedID)
+	return err
+}
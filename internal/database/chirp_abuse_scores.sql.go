@@ -0,0 +1,89 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: chirp_abuse_scores.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createChirpAbuseScore = `-- name: CreateChirpAbuseScore :exec
+insert into chirp_abuse_scores (chirp_id, score, reasons, flagged, created_at)
+values ($1, $2, $3, $4, now())
+`
+
+type CreateChirpAbuseScoreParams struct {
+	ChirpID uuid.UUID
+	Score   float64
+	Reasons []string
+	Flagged bool
+}
+
+func (q *Queries) CreateChirpAbuseScore(ctx context.Context, arg CreateChirpAbuseScoreParams) error {
+	_, err := q.db.ExecContext(ctx, createChirpAbuseScore,
+		arg.ChirpID,
+		arg.Score,
+		pq.Array(arg.Reasons),
+		arg.Flagged,
+	)
+	return err
+}
+
+const getFlaggedChirps = `-- name: GetFlaggedChirps :many
+select chirps.id, chirps.body, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.hidden_at, chirp_abuse_scores.score, chirp_abuse_scores.reasons
+from chirp_abuse_scores
+         join chirps on chirps.id = chirp_abuse_scores.chirp_id
+where chirp_abuse_scores.flagged
+order by chirp_abuse_scores.created_at desc
+limit $1
+`
+
+type GetFlaggedChirpsRow struct {
+	ID        uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	HiddenAt  sql.NullTime
+	Score     float64
+	Reasons   []string
+}
+
+func (q *Queries) GetFlaggedChirps(ctx context.Context, pageSize int32) ([]GetFlaggedChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFlaggedChirps, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFlaggedChirpsRow
+	for rows.Next() {
+		var i GetFlaggedChirpsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.Score,
+			pq.Array(&i.Reasons),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -20,7 +20,7 @@ insert into chirps (id, body,user_id,created_at,updated_at)
     now(),
     now()
 )
-    returning id, body, user_id, created_at, updated_at
+    returning id, body, user_id, created_at, updated_at, hidden_at
 `
 
 type CreateChirpParams struct {
@@ -37,6 +37,7 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UserID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.HiddenAt,
 	)
 	return i, err
 }
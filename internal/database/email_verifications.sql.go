@@ -0,0 +1,123 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: email_verifications.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createEmailVerification = `-- name: CreateEmailVerification :one
+insert into email_verifications (user_id, email, expires_at, created_at)
+values ($1, $2, now() + interval '24 hours', now())
+returning id, user_id, email, expires_at, used_at, created_at
+`
+
+type CreateEmailVerificationParams struct {
+	UserID uuid.UUID
+	Email  string
+}
+
+func (q *Queries) CreateEmailVerification(ctx context.Context, arg CreateEmailVerificationParams) (EmailVerification, error) {
+	row := q.db.QueryRowContext(ctx, createEmailVerification, arg.UserID, arg.Email)
+	var i EmailVerification
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Email,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getEmailVerification = `-- name: GetEmailVerification :one
+select id, user_id, email, expires_at, used_at, created_at
+from email_verifications
+where id = $1
+  and used_at is null
+  and expires_at > now()
+`
+
+func (q *Queries) GetEmailVerification(ctx context.Context, id uuid.UUID) (EmailVerification, error) {
+	row := q.db.QueryRowContext(ctx, getEmailVerification, id)
+	var i EmailVerification
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Email,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const markEmailVerified = `-- name: MarkEmailVerified :one
+update users
+set email_verified_at = now(),
+    updated_at        = now()
+where id = $1
+  and email = $2
+returning id, email, created_at, updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner
+`
+
+type MarkEmailVerifiedParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) MarkEmailVerified(ctx context.Context, arg MarkEmailVerifiedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, markEmailVerified, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		pq.Array(&i.Avatar),
+		pq.Array(&i.Banner),
+	)
+	return i, err
+}
+
+const revokeEmailVerifications = `-- name: RevokeEmailVerifications :exec
+update email_verifications
+set used_at = now()
+where user_id = $1
+  and used_at is null
+`
+
+func (q *Queries) RevokeEmailVerifications(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeEmailVerifications, userID)
+	return err
+}
+
+const useEmailVerification = `-- name: UseEmailVerification :execrows
+update email_verifications
+set used_at = now()
+where id = $1
+  and used_at is null
+`
+
+func (q *Queries) UseEmailVerification(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useEmailVerification, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
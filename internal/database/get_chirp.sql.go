@@ -12,7 +12,7 @@ import (
 )
 
 const getChirp = `-- name: GetChirp :one
-select id, body, user_id, created_at, updated_at from chirps where id = $1
+select id, body, user_id, created_at, updated_at, hidden_at from chirps where id = $1
 `
 
 func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -24,6 +24,7 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UserID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.HiddenAt,
 	)
 	return i, err
 }
@@ -10,7 +10,7 @@ import (
 )
 
 const getChirps = `-- name: GetChirps :many
-select id, body, user_id, created_at, updated_at from chirps order by created_at ASC
+select id, body, user_id, created_at, updated_at, hidden_at from chirps where hidden_at is null order by created_at ASC
 `
 
 func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
@@ -28,6 +28,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UserID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
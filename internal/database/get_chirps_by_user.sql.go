@@ -12,9 +12,10 @@ import (
 )
 
 const getChirpsByUser = `-- name: GetChirpsByUser :many
-select id, body, user_id, created_at, updated_at
+select id, body, user_id, created_at, updated_at, hidden_at
 from chirps
 where user_id = $1
+  and hidden_at is null
 order by created_at
 `
 
@@ -33,6 +34,7 @@ func (q *Queries) GetChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chir
 			&i.UserID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,66 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: get_chirps_page.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const getChirpsPage = `-- name: GetChirpsPage :many
+select id, body, user_id, created_at, updated_at, hidden_at
+from chirps
+where hidden_at is null
+  and ($1::uuid is null or user_id = $1::uuid)
+  and ($2::timestamp is null
+    or (created_at, id) > ($2::timestamp, $3::uuid))
+order by created_at, id
+limit $4
+`
+
+type GetChirpsPageParams struct {
+	AuthorID       uuid.NullUUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	PageSize       int32
+}
+
+func (q *Queries) GetChirpsPage(ctx context.Context, arg GetChirpsPageParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPage,
+		arg.AuthorID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,55 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: get_recent_chirps_by_user.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const getRecentChirpsByUser = `-- name: GetRecentChirpsByUser :many
+select id, body, user_id, created_at, updated_at, hidden_at
+from chirps
+where user_id = $1
+  and created_at > now() - $2::float8 * interval '1 second'
+order by created_at desc
+`
+
+type GetRecentChirpsByUserParams struct {
+	UserID        uuid.UUID
+	WindowSeconds float64
+}
+
+func (q *Queries) GetRecentChirpsByUser(ctx context.Context, arg GetRecentChirpsByUserParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentChirpsByUser, arg.UserID, arg.WindowSeconds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
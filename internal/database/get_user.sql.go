@@ -0,0 +1,41 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: get_user.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const getUser = `-- name: GetUser :one
+select id, email, created_at, updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner
+from users
+where id = $1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		pq.Array(&i.Avatar),
+		pq.Array(&i.Banner),
+	)
+	return i, err
+}
@@ -11,27 +11,36 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-select token, user_id, expires_at, revoked_at, refresh_tokens.created_at, refresh_tokens.updated_at, id, email, u.created_at, u.updated_at, hashed_password, is_chirpy_red from refresh_tokens
+select token, user_id, expires_at, revoked_at, refresh_tokens.created_at, refresh_tokens.updated_at, id, email, u.created_at, u.updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner from refresh_tokens
 left join users u on u.id = refresh_tokens.user_id
 where refresh_tokens.token = $1
 `
 
 type GetUserFromRefreshTokenRow struct {
-	Token          string
-	UserID         uuid.UUID
-	ExpiresAt      sql.NullTime
-	RevokedAt      sql.NullTime
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	ID             uuid.NullUUID
-	Email          sql.NullString
-	CreatedAt_2    sql.NullTime
-	UpdatedAt_2    sql.NullTime
-	HashedPassword sql.NullString
-	IsChirpyRed    sql.NullBool
+	Token           string
+	UserID          uuid.UUID
+	ExpiresAt       sql.NullTime
+	RevokedAt       sql.NullTime
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	ID              uuid.NullUUID
+	Email           sql.NullString
+	CreatedAt_2     sql.NullTime
+	UpdatedAt_2     sql.NullTime
+	HashedPassword  sql.NullString
+	IsChirpyRed     sql.NullBool
+	SuspendedAt     sql.NullTime
+	EmailVerifiedAt sql.NullTime
+	Handle          sql.NullString
+	DisplayName     sql.NullString
+	Bio             sql.NullString
+	Website         sql.NullString
+	Avatar          []string
+	Banner          []string
 }
 
 func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (GetUserFromRefreshTokenRow, error) {
@@ -50,6 +59,14 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Ge
 		&i.UpdatedAt_2,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		pq.Array(&i.Avatar),
+		pq.Array(&i.Banner),
 	)
 	return i, err
 }
@@ -0,0 +1,57 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: get_users_by_ids.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const getUsersByIDs = `-- name: GetUsersByIDs :many
+select id, email, created_at, updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner
+from users
+where id = any ($1::uuid[])
+`
+
+func (q *Queries) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.SuspendedAt,
+			&i.EmailVerifiedAt,
+			&i.Handle,
+			&i.DisplayName,
+			&i.Bio,
+			&i.Website,
+			pq.Array(&i.Avatar),
+			pq.Array(&i.Banner),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
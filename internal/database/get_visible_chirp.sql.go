@@ -0,0 +1,33 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: get_visible_chirp.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const getVisibleChirp = `-- name: GetVisibleChirp :one
+select id, body, user_id, created_at, updated_at, hidden_at
+from chirps
+where id = $1
+  and hidden_at is null
+`
+
+func (q *Queries) GetVisibleChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.Body,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
@@ -0,0 +1,138 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: idempotency_keys.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+const claimIdempotencyKey = `-- name: ClaimIdempotencyKey :one
+insert into idempotency_keys (scope, key, fingerprint, created_at)
+values ($1, $2, $3, now())
+on conflict (scope, key) do update
+    set fingerprint  = excluded.fingerprint,
+        status       = null,
+        content_type = null,
+        body         = null,
+        created_at   = excluded.created_at
+where idempotency_keys.created_at < now() - interval '24 hours'
+   or (idempotency_keys.status is null and
+       idempotency_keys.created_at < now() - make_interval(secs => $4::integer))
+returning scope, key, fingerprint, status, content_type, body, created_at
+`
+
+type ClaimIdempotencyKeyParams struct {
+	Scope        string
+	Key          string
+	Fingerprint  string
+	LeaseSeconds int32
+}
+
+// A key is claimed afresh once it expires, or once a claim with no response
+// recorded is older than lease_seconds, as its request was abandoned.
+func (q *Queries) ClaimIdempotencyKey(ctx context.Context, arg ClaimIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRowContext(ctx, claimIdempotencyKey,
+		arg.Scope,
+		arg.Key,
+		arg.Fingerprint,
+		arg.LeaseSeconds,
+	)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Scope,
+		&i.Key,
+		&i.Fingerprint,
+		&i.Status,
+		&i.ContentType,
+		&i.Body,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteExpiredIdempotencyKeys = `-- name: DeleteExpiredIdempotencyKeys :exec
+delete
+from idempotency_keys
+where created_at < now() - interval '24 hours'
+`
+
+func (q *Queries) DeleteExpiredIdempotencyKeys(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredIdempotencyKeys)
+	return err
+}
+
+const deleteIdempotencyKey = `-- name: DeleteIdempotencyKey :exec
+delete
+from idempotency_keys
+where scope = $1
+  and key = $2
+`
+
+type DeleteIdempotencyKeyParams struct {
+	Scope string
+	Key   string
+}
+
+func (q *Queries) DeleteIdempotencyKey(ctx context.Context, arg DeleteIdempotencyKeyParams) error {
+	_, err := q.db.ExecContext(ctx, deleteIdempotencyKey, arg.Scope, arg.Key)
+	return err
+}
+
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+select scope, key, fingerprint, status, content_type, body, created_at
+from idempotency_keys
+where scope = $1
+  and key = $2
+`
+
+type GetIdempotencyKeyParams struct {
+	Scope string
+	Key   string
+}
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRowContext(ctx, getIdempotencyKey, arg.Scope, arg.Key)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Scope,
+		&i.Key,
+		&i.Fingerprint,
+		&i.Status,
+		&i.ContentType,
+		&i.Body,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const saveIdempotentResponse = `-- name: SaveIdempotentResponse :exec
+update idempotency_keys
+set status       = $3,
+    content_type = $4,
+    body         = $5
+where scope = $1
+  and key = $2
+`
+
+type SaveIdempotentResponseParams struct {
+	Scope       string
+	Key         string
+	Status      sql.NullInt32
+	ContentType sql.NullString
+	Body        []byte
+}
+
+func (q *Queries) SaveIdempotentResponse(ctx context.Context, arg SaveIdempotentResponseParams) error {
+	_, err := q.db.ExecContext(ctx, saveIdempotentResponse,
+		arg.Scope,
+		arg.Key,
+		arg.Status,
+		arg.ContentType,
+		arg.Body,
+	)
+	return err
+}
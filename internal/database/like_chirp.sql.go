@@ -0,0 +1,31 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: like_chirp.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const likeChirp = `-- name: LikeChirp :execrows
+insert into chirp_likes (chirp_id, user_id, created_at)
+values ($1, $2, now())
+on conflict do nothing
+`
+
+type LikeChirpParams struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+}
+
+func (q *Queries) LikeChirp(ctx context.Context, arg LikeChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, likeChirp, arg.ChirpID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,99 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: login_throttles.sql
+
+package database
+
+import (
+	"context"
+)
+
+const clearLoginThrottle = `-- name: ClearLoginThrottle :execrows
+delete
+from login_throttles
+where key = $1
+`
+
+func (q *Queries) ClearLoginThrottle(ctx context.Context, key string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, clearLoginThrottle, key)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const ensureLoginThrottle = `-- name: EnsureLoginThrottle :exec
+insert into login_throttles (key, failures, last_failed_at)
+values ($1, 0, now())
+on conflict (key) do nothing
+`
+
+func (q *Queries) EnsureLoginThrottle(ctx context.Context, key string) error {
+	_, err := q.db.ExecContext(ctx, ensureLoginThrottle, key)
+	return err
+}
+
+const forgiveLoginAttempt = `-- name: ForgiveLoginAttempt :exec
+update login_throttles
+set failures = greatest(failures - 1, 0)
+where key = $1
+`
+
+// Takes back an attempt that turned out to be a success.
+func (q *Queries) ForgiveLoginAttempt(ctx context.Context, key string) error {
+	_, err := q.db.ExecContext(ctx, forgiveLoginAttempt, key)
+	return err
+}
+
+const lockLoginThrottle = `-- name: LockLoginThrottle :one
+select failures,
+       coalesce(extract(epoch from blocked_until - now()), 0)::float8 as wait_seconds,
+       extract(epoch from now() - last_failed_at)::float8               as idle_seconds
+from login_throttles
+where key = $1
+    for update
+`
+
+type LockLoginThrottleRow struct {
+	Failures    int32
+	WaitSeconds float64
+	IdleSeconds float64
+}
+
+// Locks the key's row until the transaction ends, so concurrent attempts on
+// the same key are counted one after another.
+func (q *Queries) LockLoginThrottle(ctx context.Context, key string) (LockLoginThrottleRow, error) {
+	row := q.db.QueryRowContext(ctx, lockLoginThrottle, key)
+	var i LockLoginThrottleRow
+	err := row.Scan(&i.Failures, &i.WaitSeconds, &i.IdleSeconds)
+	return i, err
+}
+
+const setLoginThrottle = `-- name: SetLoginThrottle :exec
+update login_throttles
+set failures       = $1,
+    last_failed_at = now(),
+    blocked_until  = case
+                         when $2::float8 > 0
+                             then now() + make_interval(secs => $2::float8) end,
+    locked         = $3
+where key = $4
+`
+
+type SetLoginThrottleParams struct {
+	Failures    int32
+	WaitSeconds float64
+	Locked      bool
+	Key         string
+}
+
+func (q *Queries) SetLoginThrottle(ctx context.Context, arg SetLoginThrottleParams) error {
+	_, err := q.db.ExecContext(ctx, setLoginThrottle,
+		arg.Failures,
+		arg.WaitSeconds,
+		arg.Locked,
+		arg.Key,
+	)
+	return err
+}
@@ -0,0 +1,375 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: messages.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const addConversationMember = `-- name: AddConversationMember :exec
+insert into conversation_members (conversation_id, user_id, joined_at)
+values ($1, $2, now())
+on conflict do nothing
+`
+
+type AddConversationMemberParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) AddConversationMember(ctx context.Context, arg AddConversationMemberParams) error {
+	_, err := q.db.ExecContext(ctx, addConversationMember, arg.ConversationID, arg.UserID)
+	return err
+}
+
+const createConversation = `-- name: CreateConversation :one
+insert into conversations (created_by, created_at, updated_at)
+values ($1, now(), now())
+returning id, created_by, created_at, updated_at
+`
+
+func (q *Queries) CreateConversation(ctx context.Context, createdBy uuid.NullUUID) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, createConversation, createdBy)
+	var i Conversation
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createMessage = `-- name: CreateMessage :one
+insert into messages (conversation_id, sender_id, body, created_at)
+values ($1, $2, $3, now())
+returning id, conversation_id, sender_id, body, created_at
+`
+
+type CreateMessageParams struct {
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+}
+
+func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
+	row := q.db.QueryRowContext(ctx, createMessage, arg.ConversationID, arg.SenderID, arg.Body)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.ConversationID,
+		&i.SenderID,
+		&i.Body,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getConversation = `-- name: GetConversation :one
+select id, created_by, created_at, updated_at
+from conversations
+where id = $1
+`
+
+func (q *Queries) GetConversation(ctx context.Context, id uuid.UUID) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, getConversation, id)
+	var i Conversation
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getConversationMembers = `-- name: GetConversationMembers :many
+select conversation_id, user_id, joined_at, last_read_at
+from conversation_members
+where conversation_id = any ($1::uuid[])
+order by joined_at, user_id
+`
+
+func (q *Queries) GetConversationMembers(ctx context.Context, conversationIds []uuid.UUID) ([]ConversationMember, error) {
+	rows, err := q.db.QueryContext(ctx, getConversationMembers, pq.Array(conversationIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ConversationMember
+	for rows.Next() {
+		var i ConversationMember
+		if err := rows.Scan(
+			&i.ConversationID,
+			&i.UserID,
+			&i.JoinedAt,
+			&i.LastReadAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getConversationUnreadCount = `-- name: GetConversationUnreadCount :one
+select count(msg.id)::bigint
+from conversation_members cm
+         join messages msg on msg.conversation_id = cm.conversation_id
+where cm.conversation_id = $1
+  and cm.user_id = $2
+  and msg.sender_id <> cm.user_id
+  and (cm.last_read_at is null or msg.created_at > cm.last_read_at)
+`
+
+type GetConversationUnreadCountParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) GetConversationUnreadCount(ctx context.Context, arg GetConversationUnreadCountParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getConversationUnreadCount, arg.ConversationID, arg.UserID)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const getConversationsByUser = `-- name: GetConversationsByUser :many
+select c.id,
+       c.created_by,
+       c.created_at,
+       c.updated_at,
+       (select count(*)
+        from messages msg
+        where msg.conversation_id = c.id
+          and msg.sender_id <> cm.user_id
+          and (cm.last_read_at is null or msg.created_at > cm.last_read_at))::bigint as unread_count
+from conversations c
+         join conversation_members cm on cm.conversation_id = c.id
+where cm.user_id = $1
+order by c.updated_at desc
+limit $2
+`
+
+type GetConversationsByUserParams struct {
+	UserID   uuid.UUID
+	PageSize int32
+}
+
+type GetConversationsByUserRow struct {
+	ID          uuid.UUID
+	CreatedBy   uuid.NullUUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UnreadCount int64
+}
+
+func (q *Queries) GetConversationsByUser(ctx context.Context, arg GetConversationsByUserParams) ([]GetConversationsByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getConversationsByUser, arg.UserID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetConversationsByUserRow
+	for rows.Next() {
+		var i GetConversationsByUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedBy,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UnreadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDirectConversation = `-- name: GetDirectConversation :one
+select c.id, c.created_by, c.created_at, c.updated_at
+from conversations c
+         join conversation_members a on a.conversation_id = c.id and a.user_id = $1
+         join conversation_members b on b.conversation_id = c.id and b.user_id = $2
+where (select count(*) from conversation_members m where m.conversation_id = c.id) = 2
+limit 1
+`
+
+type GetDirectConversationParams struct {
+	UserID  uuid.UUID
+	OtherID uuid.UUID
+}
+
+func (q *Queries) GetDirectConversation(ctx context.Context, arg GetDirectConversationParams) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, getDirectConversation, arg.UserID, arg.OtherID)
+	var i Conversation
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getMessage = `-- name: GetMessage :one
+select id, conversation_id, sender_id, body, created_at
+from messages
+where id = $1
+`
+
+func (q *Queries) GetMessage(ctx context.Context, id uuid.UUID) (Message, error) {
+	row := q.db.QueryRowContext(ctx, getMessage, id)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.ConversationID,
+		&i.SenderID,
+		&i.Body,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getMessagesPage = `-- name: GetMessagesPage :many
+select id, conversation_id, sender_id, body, created_at
+from messages
+where conversation_id = $1
+  and ($2::timestamp is null
+    or (created_at, id) < ($2::timestamp, $3::uuid))
+order by created_at desc, id desc
+limit $4
+`
+
+type GetMessagesPageParams struct {
+	ConversationID  uuid.UUID
+	BeforeCreatedAt sql.NullTime
+	BeforeID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) GetMessagesPage(ctx context.Context, arg GetMessagesPageParams) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, getMessagesPage,
+		arg.ConversationID,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationID,
+			&i.SenderID,
+			&i.Body,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnreadCounts = `-- name: GetUnreadCounts :one
+select count(distinct cm.conversation_id)::bigint as conversations,
+       count(msg.id)::bigint                       as messages
+from conversation_members cm
+         join messages msg on msg.conversation_id = cm.conversation_id
+where cm.user_id = $1
+  and msg.sender_id <> cm.user_id
+  and (cm.last_read_at is null or msg.created_at > cm.last_read_at)
+`
+
+type GetUnreadCountsRow struct {
+	Conversations int64
+	Messages      int64
+}
+
+func (q *Queries) GetUnreadCounts(ctx context.Context, userID uuid.UUID) (GetUnreadCountsRow, error) {
+	row := q.db.QueryRowContext(ctx, getUnreadCounts, userID)
+	var i GetUnreadCountsRow
+	err := row.Scan(&i.Conversations, &i.Messages)
+	return i, err
+}
+
+const isConversationMember = `-- name: IsConversationMember :one
+select exists(select 1
+              from conversation_members
+              where conversation_id = $1
+                and user_id = $2)
+`
+
+type IsConversationMemberParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) IsConversationMember(ctx context.Context, arg IsConversationMemberParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isConversationMember, arg.ConversationID, arg.UserID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const markConversationRead = `-- name: MarkConversationRead :exec
+update conversation_members
+set last_read_at = greatest(last_read_at, coalesce($3::timestamp, now()))
+where conversation_id = $1
+  and user_id = $2
+`
+
+type MarkConversationReadParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	ReadAt         sql.NullTime
+}
+
+// Read markers only move forward; without read_at everything up to now is read.
+func (q *Queries) MarkConversationRead(ctx context.Context, arg MarkConversationReadParams) error {
+	_, err := q.db.ExecContext(ctx, markConversationRead, arg.ConversationID, arg.UserID, arg.ReadAt)
+	return err
+}
+
+const touchConversation = `-- name: TouchConversation :exec
+update conversations
+set updated_at = now()
+where id = $1
+`
+
+func (q *Queries) TouchConversation(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, touchConversation, id)
+	return err
+}
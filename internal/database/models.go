@@ -11,12 +11,163 @@ import (
 	"github.com/google/uuid"
 )
 
+type Appeal struct {
+	ID         uuid.UUID
+	ActionID   uuid.UUID
+	UserID     uuid.UUID
+	Message    string
+	Status     string
+	CreatedAt  time.Time
+	ResolvedAt sql.NullTime
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	Body      string
 	UserID    uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	HiddenAt  sql.NullTime
+}
+
+type ChirpAbuseScore struct {
+	ChirpID   uuid.UUID
+	Score     float64
+	Reasons   []string
+	Flagged   bool
+	CreatedAt time.Time
+}
+
+type ChirpLike struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedBy uuid.NullUUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type EmailVerification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type IdempotencyKey struct {
+	Scope       string
+	Key         string
+	Fingerprint string
+	Status      sql.NullInt32
+	ContentType sql.NullString
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+type LoginChallenge struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type LoginThrottle struct {
+	Key          string
+	Failures     int32
+	LastFailedAt time.Time
+	BlockedUntil sql.NullTime
+	Locked       bool
+}
+
+type Message struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	CreatedAt      time.Time
+}
+
+type ModerationAction struct {
+	ID        uuid.UUID
+	ReportID  uuid.NullUUID
+	Action    string
+	ChirpID   uuid.NullUUID
+	UserID    uuid.UUID
+	Note      string
+	CreatedAt time.Time
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ActorID   uuid.UUID
+	Type      string
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type NotificationPreference struct {
+	UserID  uuid.UUID
+	Type    string
+	Enabled bool
+}
+
+type PasswordReset struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type RateLimit struct {
+	Key       string
+	Tokens    float64
+	Allowed   bool
+	UpdatedAt time.Time
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,11 +179,76 @@ type RefreshToken struct {
 	UpdatedAt time.Time
 }
 
+type Report struct {
+	ID         uuid.UUID
+	ReporterID uuid.UUID
+	ChirpID    uuid.NullUUID
+	UserID     uuid.UUID
+	Reason     string
+	Details    string
+	Status     string
+	CreatedAt  time.Time
+	ResolvedAt sql.NullTime
+}
+
+type TotpSecret struct {
+	UserID      uuid.UUID
+	Secret      []byte
+	LastStep    int64
+	ConfirmedAt sql.NullTime
+	CreatedAt   time.Time
+}
+
 type User struct {
-	ID             uuid.UUID
-	Email          string
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	HashedPassword string
-	IsChirpyRed    bool
+	ID              uuid.UUID
+	Email           string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	HashedPassword  string
+	IsChirpyRed     bool
+	SuspendedAt     sql.NullTime
+	EmailVerifiedAt sql.NullTime
+	Handle          sql.NullString
+	DisplayName     string
+	Bio             string
+	Website         string
+	Avatar          []string
+	Banner          []string
+}
+
+type UserFilter struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Kind      string
+	Value     string
+	Action    string
+	ExpiresAt sql.NullTime
+	CreatedAt time.Time
+}
+
+type Webhook struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	Url          string
+	Secret       string
+	Events       []string
+	FailureCount int32
+	DisabledAt   sql.NullTime
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	WebhookID     uuid.UUID
+	EventID       uuid.UUID
+	EventType     string
+	Payload       string
+	Status        string
+	Attempts      int32
+	ResponseCode  sql.NullInt32
+	Error         sql.NullString
+	NextAttemptAt sql.NullTime
+	DeliveredAt   sql.NullTime
+	CreatedAt     time.Time
 }
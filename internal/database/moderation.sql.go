@@ -0,0 +1,468 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: moderation.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const createAppeal = `-- name: CreateAppeal :one
+insert into appeals (action_id, user_id, message, created_at)
+values ($1, $2, $3, now())
+on conflict do nothing
+returning id, action_id, user_id, message, status, created_at, resolved_at
+`
+
+type CreateAppealParams struct {
+	ActionID uuid.UUID
+	UserID   uuid.UUID
+	Message  string
+}
+
+func (q *Queries) CreateAppeal(ctx context.Context, arg CreateAppealParams) (Appeal, error) {
+	row := q.db.QueryRowContext(ctx, createAppeal, arg.ActionID, arg.UserID, arg.Message)
+	var i Appeal
+	err := row.Scan(
+		&i.ID,
+		&i.ActionID,
+		&i.UserID,
+		&i.Message,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const createModerationAction = `-- name: CreateModerationAction :one
+insert into moderation_actions (report_id, action, chirp_id, user_id, note, created_at)
+values ($1, $2, $3, $4, $5, now())
+returning id, report_id, action, chirp_id, user_id, note, created_at
+`
+
+type CreateModerationActionParams struct {
+	ReportID uuid.NullUUID
+	Action   string
+	ChirpID  uuid.NullUUID
+	UserID   uuid.UUID
+	Note     string
+}
+
+func (q *Queries) CreateModerationAction(ctx context.Context, arg CreateModerationActionParams) (ModerationAction, error) {
+	row := q.db.QueryRowContext(ctx, createModerationAction,
+		arg.ReportID,
+		arg.Action,
+		arg.ChirpID,
+		arg.UserID,
+		arg.Note,
+	)
+	var i ModerationAction
+	err := row.Scan(
+		&i.ID,
+		&i.ReportID,
+		&i.Action,
+		&i.ChirpID,
+		&i.UserID,
+		&i.Note,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createReport = `-- name: CreateReport :one
+insert into reports (reporter_id, chirp_id, user_id, reason, details, created_at)
+values ($1, $2, $3, $4, $5, now())
+on conflict do nothing
+returning id, reporter_id, chirp_id, user_id, reason, details, status, created_at, resolved_at
+`
+
+type CreateReportParams struct {
+	ReporterID uuid.UUID
+	ChirpID    uuid.NullUUID
+	UserID     uuid.UUID
+	Reason     string
+	Details    string
+}
+
+func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, createReport,
+		arg.ReporterID,
+		arg.ChirpID,
+		arg.UserID,
+		arg.Reason,
+		arg.Details,
+	)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.ReporterID,
+		&i.ChirpID,
+		&i.UserID,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const getAppeal = `-- name: GetAppeal :one
+select id, action_id, user_id, message, status, created_at, resolved_at
+from appeals
+where id = $1
+`
+
+func (q *Queries) GetAppeal(ctx context.Context, id uuid.UUID) (Appeal, error) {
+	row := q.db.QueryRowContext(ctx, getAppeal, id)
+	var i Appeal
+	err := row.Scan(
+		&i.ID,
+		&i.ActionID,
+		&i.UserID,
+		&i.Message,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const getAppealsByStatus = `-- name: GetAppealsByStatus :many
+select id, action_id, user_id, message, status, created_at, resolved_at
+from appeals
+where status = $1
+order by created_at
+limit $2
+`
+
+type GetAppealsByStatusParams struct {
+	Status   string
+	PageSize int32
+}
+
+func (q *Queries) GetAppealsByStatus(ctx context.Context, arg GetAppealsByStatusParams) ([]Appeal, error) {
+	rows, err := q.db.QueryContext(ctx, getAppealsByStatus, arg.Status, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Appeal
+	for rows.Next() {
+		var i Appeal
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActionID,
+			&i.UserID,
+			&i.Message,
+			&i.Status,
+			&i.CreatedAt,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getModerationAction = `-- name: GetModerationAction :one
+select id, report_id, action, chirp_id, user_id, note, created_at
+from moderation_actions
+where id = $1
+`
+
+func (q *Queries) GetModerationAction(ctx context.Context, id uuid.UUID) (ModerationAction, error) {
+	row := q.db.QueryRowContext(ctx, getModerationAction, id)
+	var i ModerationAction
+	err := row.Scan(
+		&i.ID,
+		&i.ReportID,
+		&i.Action,
+		&i.ChirpID,
+		&i.UserID,
+		&i.Note,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getModerationActions = `-- name: GetModerationActions :many
+select id, report_id, action, chirp_id, user_id, note, created_at
+from moderation_actions
+order by created_at desc
+limit $1
+`
+
+func (q *Queries) GetModerationActions(ctx context.Context, pageSize int32) ([]ModerationAction, error) {
+	rows, err := q.db.QueryContext(ctx, getModerationActions, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ModerationAction
+	for rows.Next() {
+		var i ModerationAction
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReportID,
+			&i.Action,
+			&i.ChirpID,
+			&i.UserID,
+			&i.Note,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getModerationActionsByUser = `-- name: GetModerationActionsByUser :many
+select id, report_id, action, chirp_id, user_id, note, created_at
+from moderation_actions
+where user_id = $1
+order by created_at desc
+`
+
+func (q *Queries) GetModerationActionsByUser(ctx context.Context, userID uuid.UUID) ([]ModerationAction, error) {
+	rows, err := q.db.QueryContext(ctx, getModerationActionsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ModerationAction
+	for rows.Next() {
+		var i ModerationAction
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReportID,
+			&i.Action,
+			&i.ChirpID,
+			&i.UserID,
+			&i.Note,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReport = `-- name: GetReport :one
+select id, reporter_id, chirp_id, user_id, reason, details, status, created_at, resolved_at
+from reports
+where id = $1
+`
+
+func (q *Queries) GetReport(ctx context.Context, id uuid.UUID) (Report, error) {
+	row := q.db.QueryRowContext(ctx, getReport, id)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.ReporterID,
+		&i.ChirpID,
+		&i.UserID,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const getReportsByStatus = `-- name: GetReportsByStatus :many
+select id, reporter_id, chirp_id, user_id, reason, details, status, created_at, resolved_at
+from reports
+where status = $1
+order by created_at
+limit $2
+`
+
+type GetReportsByStatusParams struct {
+	Status   string
+	PageSize int32
+}
+
+func (q *Queries) GetReportsByStatus(ctx context.Context, arg GetReportsByStatusParams) ([]Report, error) {
+	rows, err := q.db.QueryContext(ctx, getReportsByStatus, arg.Status, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Report
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReporterID,
+			&i.ChirpID,
+			&i.UserID,
+			&i.Reason,
+			&i.Details,
+			&i.Status,
+			&i.CreatedAt,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hideChirp = `-- name: HideChirp :exec
+update chirps
+set hidden_at = now()
+where id = $1
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
+
+const lockAppeal = `-- name: LockAppeal :one
+select id, action_id, user_id, message, status, created_at, resolved_at
+from appeals
+where id = $1
+    for update
+`
+
+func (q *Queries) LockAppeal(ctx context.Context, id uuid.UUID) (Appeal, error) {
+	row := q.db.QueryRowContext(ctx, lockAppeal, id)
+	var i Appeal
+	err := row.Scan(
+		&i.ID,
+		&i.ActionID,
+		&i.UserID,
+		&i.Message,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const lockReport = `-- name: LockReport :one
+select id, reporter_id, chirp_id, user_id, reason, details, status, created_at, resolved_at
+from reports
+where id = $1
+    for update
+`
+
+func (q *Queries) LockReport(ctx context.Context, id uuid.UUID) (Report, error) {
+	row := q.db.QueryRowContext(ctx, lockReport, id)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.ReporterID,
+		&i.ChirpID,
+		&i.UserID,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const resolveAppeal = `-- name: ResolveAppeal :exec
+update appeals
+set status      = $2,
+    resolved_at = now()
+where id = $1
+`
+
+type ResolveAppealParams struct {
+	ID     uuid.UUID
+	Status string
+}
+
+func (q *Queries) ResolveAppeal(ctx context.Context, arg ResolveAppealParams) error {
+	_, err := q.db.ExecContext(ctx, resolveAppeal, arg.ID, arg.Status)
+	return err
+}
+
+const resolveReport = `-- name: ResolveReport :exec
+update reports
+set status      = $2,
+    resolved_at = now()
+where id = $1
+`
+
+type ResolveReportParams struct {
+	ID     uuid.UUID
+	Status string
+}
+
+func (q *Queries) ResolveReport(ctx context.Context, arg ResolveReportParams) error {
+	_, err := q.db.ExecContext(ctx, resolveReport, arg.ID, arg.Status)
+	return err
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+update users
+set suspended_at = now()
+where id = $1
+`
+
+func (q *Queries) SuspendUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, id)
+	return err
+}
+
+const unhideChirp = `-- name: UnhideChirp :exec
+update chirps
+set hidden_at = null
+where id = $1
+`
+
+func (q *Queries) UnhideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, unhideChirp, id)
+	return err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :exec
+update users
+set suspended_at = null
+where id = $1
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, unsuspendUser, id)
+	return err
+}
@@ -0,0 +1,108 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: mutes.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const getHiddenAuthorIDs = `-- name: GetHiddenAuthorIDs :many
+select muted_id
+from mutes
+where muter_id = $1
+union
+select blocked_id
+from blocks
+where blocker_id = $1
+`
+
+func (q *Queries) GetHiddenAuthorIDs(ctx context.Context, muterID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getHiddenAuthorIDs, muterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var muted_id uuid.UUID
+		if err := rows.Scan(&muted_id); err != nil {
+			return nil, err
+		}
+		items = append(items, muted_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMutes = `-- name: GetMutes :many
+select muter_id, muted_id, created_at
+from mutes
+where muter_id = $1
+order by created_at desc
+`
+
+func (q *Queries) GetMutes(ctx context.Context, muterID uuid.UUID) ([]Mute, error) {
+	rows, err := q.db.QueryContext(ctx, getMutes, muterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Mute
+	for rows.Next() {
+		var i Mute
+		if err := rows.Scan(&i.MuterID, &i.MutedID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const muteUser = `-- name: MuteUser :exec
+insert into mutes (muter_id, muted_id, created_at)
+values ($1, $2, now())
+on conflict do nothing
+`
+
+type MuteUserParams struct {
+	MuterID uuid.UUID
+	MutedID uuid.UUID
+}
+
+func (q *Queries) MuteUser(ctx context.Context, arg MuteUserParams) error {
+	_, err := q.db.ExecContext(ctx, muteUser, arg.MuterID, arg.MutedID)
+	return err
+}
+
+const unmuteUser = `-- name: UnmuteUser :exec
+delete
+from mutes
+where muter_id = $1
+  and muted_id = $2
+`
+
+type UnmuteUserParams struct {
+	MuterID uuid.UUID
+	MutedID uuid.UUID
+}
+
+func (q *Queries) UnmuteUser(ctx context.Context, arg UnmuteUserParams) error {
+	_, err := q.db.ExecContext(ctx, unmuteUser, arg.MuterID, arg.MutedID)
+	return err
+}
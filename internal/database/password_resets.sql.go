@@ -0,0 +1,142 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: password_resets.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const countRecentPasswordResets = `-- name: CountRecentPasswordResets :one
+select count(*)
+from password_resets
+where user_id = $1
+  and created_at > now() - interval '1 minute'
+`
+
+func (q *Queries) CountRecentPasswordResets(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countRecentPasswordResets, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createPasswordReset = `-- name: CreatePasswordReset :one
+insert into password_resets (user_id, email, token_hash, expires_at, created_at)
+values ($1, $2, $3, now() + interval '1 hour', now())
+returning id, user_id, email, token_hash, expires_at, used_at, created_at
+`
+
+type CreatePasswordResetParams struct {
+	UserID    uuid.UUID
+	Email     string
+	TokenHash string
+}
+
+func (q *Queries) CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) (PasswordReset, error) {
+	row := q.db.QueryRowContext(ctx, createPasswordReset, arg.UserID, arg.Email, arg.TokenHash)
+	var i PasswordReset
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Email,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getPasswordResetByHash = `-- name: GetPasswordResetByHash :one
+select id, user_id, email, token_hash, expires_at, used_at, created_at
+from password_resets
+where token_hash = $1
+  and used_at is null
+  and expires_at > now()
+`
+
+func (q *Queries) GetPasswordResetByHash(ctx context.Context, tokenHash string) (PasswordReset, error) {
+	row := q.db.QueryRowContext(ctx, getPasswordResetByHash, tokenHash)
+	var i PasswordReset
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Email,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const resetUserPassword = `-- name: ResetUserPassword :one
+update users
+set hashed_password = $3,
+    updated_at      = now()
+where id = $1
+  and email = $2
+returning id, email, created_at, updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner
+`
+
+type ResetUserPasswordParams struct {
+	ID             uuid.UUID
+	Email          string
+	HashedPassword string
+}
+
+// Only while the user still has the address the reset was sent to.
+func (q *Queries) ResetUserPassword(ctx context.Context, arg ResetUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, resetUserPassword, arg.ID, arg.Email, arg.HashedPassword)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		pq.Array(&i.Avatar),
+		pq.Array(&i.Banner),
+	)
+	return i, err
+}
+
+const revokePasswordResets = `-- name: RevokePasswordResets :exec
+update password_resets
+set used_at = now()
+where user_id = $1
+  and used_at is null
+`
+
+func (q *Queries) RevokePasswordResets(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokePasswordResets, userID)
+	return err
+}
+
+const usePasswordReset = `-- name: UsePasswordReset :execrows
+update password_resets
+set used_at = now()
+where id = $1
+  and used_at is null
+`
+
+func (q *Queries) UsePasswordReset(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, usePasswordReset, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
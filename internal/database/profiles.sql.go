@@ -0,0 +1,252 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: profiles.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const getProfileCounts = `-- name: GetProfileCounts :one
+select (select count(*) from chirps c where c.user_id = $1 and c.hidden_at is null)::bigint as chirp_count,
+       (select count(*) from follows f where f.followee_id = $1)::bigint                  as follower_count,
+       (select count(*) from follows f where f.follower_id = $1)::bigint                  as following_count
+`
+
+type GetProfileCountsRow struct {
+	ChirpCount     int64
+	FollowerCount  int64
+	FollowingCount int64
+}
+
+func (q *Queries) GetProfileCounts(ctx context.Context, userID uuid.UUID) (GetProfileCountsRow, error) {
+	row := q.db.QueryRowContext(ctx, getProfileCounts, userID)
+	var i GetProfileCountsRow
+	err := row.Scan(&i.ChirpCount, &i.FollowerCount, &i.FollowingCount)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+select id, email, created_at, updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner
+from users
+where lower(handle) = lower($1)
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, lower string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, lower)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		pq.Array(&i.Avatar),
+		pq.Array(&i.Banner),
+	)
+	return i, err
+}
+
+const getUserByLogin = `-- name: GetUserByLogin :one
+select id, email, created_at, updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner
+from users
+where email = $1
+   or lower(handle) = lower($1)
+`
+
+// Handles cannot contain @, so a login is never both an email and a handle.
+func (q *Queries) GetUserByLogin(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByLogin, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		pq.Array(&i.Avatar),
+		pq.Array(&i.Banner),
+	)
+	return i, err
+}
+
+const getUsersByHandles = `-- name: GetUsersByHandles :many
+select id, email, created_at, updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner
+from users
+where lower(handle) = any ($1::text[])
+`
+
+func (q *Queries) GetUsersByHandles(ctx context.Context, handles []string) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByHandles, pq.Array(handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.SuspendedAt,
+			&i.EmailVerifiedAt,
+			&i.Handle,
+			&i.DisplayName,
+			&i.Bio,
+			&i.Website,
+			pq.Array(&i.Avatar),
+			pq.Array(&i.Banner),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateAvatar = `-- name: UpdateAvatar :one
+update users
+set avatar     = $1::text[],
+    updated_at = now()
+where id = $2
+returning id, email, created_at, updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner
+`
+
+type UpdateAvatarParams struct {
+	Avatar []string
+	ID     uuid.UUID
+}
+
+func (q *Queries) UpdateAvatar(ctx context.Context, arg UpdateAvatarParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateAvatar, pq.Array(arg.Avatar), arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		pq.Array(&i.Avatar),
+		pq.Array(&i.Banner),
+	)
+	return i, err
+}
+
+const updateBanner = `-- name: UpdateBanner :one
+update users
+set banner     = $1::text[],
+    updated_at = now()
+where id = $2
+returning id, email, created_at, updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner
+`
+
+type UpdateBannerParams struct {
+	Banner []string
+	ID     uuid.UUID
+}
+
+func (q *Queries) UpdateBanner(ctx context.Context, arg UpdateBannerParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateBanner, pq.Array(arg.Banner), arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		pq.Array(&i.Avatar),
+		pq.Array(&i.Banner),
+	)
+	return i, err
+}
+
+const updateProfile = `-- name: UpdateProfile :one
+update users
+set handle       = $2,
+    display_name = $3,
+    bio          = $4,
+    website      = $5,
+    updated_at   = now()
+where id = $1
+returning id, email, created_at, updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner
+`
+
+type UpdateProfileParams struct {
+	ID          uuid.UUID
+	Handle      sql.NullString
+	DisplayName string
+	Bio         string
+	Website     string
+}
+
+func (q *Queries) UpdateProfile(ctx context.Context, arg UpdateProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateProfile,
+		arg.ID,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.Website,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		pq.Array(&i.Avatar),
+		pq.Array(&i.Banner),
+	)
+	return i, err
+}
@@ -0,0 +1,57 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: rate_limits.sql
+
+package database
+
+import (
+	"context"
+)
+
+const deleteIdleRateLimits = `-- name: DeleteIdleRateLimits :exec
+delete
+from rate_limits
+where updated_at < now() - interval '1 hour'
+`
+
+func (q *Queries) DeleteIdleRateLimits(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteIdleRateLimits)
+	return err
+}
+
+const takeRateLimitToken = `-- name: TakeRateLimitToken :one
+insert into rate_limits (key, tokens, allowed, updated_at)
+values ($1, $2::float8 - 1, true, now())
+on conflict (key) do update
+    set tokens     = case
+                         when least($2::float8, rate_limits.tokens +
+                             extract(epoch from now() - rate_limits.updated_at)::float8 * $3::float8) >= 1
+                             then least($2::float8, rate_limits.tokens +
+                                 extract(epoch from now() - rate_limits.updated_at)::float8 * $3::float8) - 1
+                         else least($2::float8, rate_limits.tokens +
+                             extract(epoch from now() - rate_limits.updated_at)::float8 * $3::float8)
+        end,
+        allowed    = least($2::float8, rate_limits.tokens +
+                         extract(epoch from now() - rate_limits.updated_at)::float8 * $3::float8) >= 1,
+        updated_at = now()
+returning tokens, allowed
+`
+
+type TakeRateLimitTokenParams struct {
+	Key   string
+	Burst float64
+	Rate  float64
+}
+
+type TakeRateLimitTokenRow struct {
+	Tokens  float64
+	Allowed bool
+}
+
+func (q *Queries) TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenParams) (TakeRateLimitTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, takeRateLimitToken, arg.Key, arg.Burst, arg.Rate)
+	var i TakeRateLimitTokenRow
+	err := row.Scan(&i.Tokens, &i.Allowed)
+	return i, err
+}
@@ -0,0 +1,218 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: two_factor.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const confirmTotpSecret = `-- name: ConfirmTotpSecret :execrows
+update totp_secrets
+set confirmed_at = now(),
+    last_step    = $2
+where user_id = $1
+  and confirmed_at is null
+`
+
+type ConfirmTotpSecretParams struct {
+	UserID   uuid.UUID
+	LastStep int64
+}
+
+func (q *Queries) ConfirmTotpSecret(ctx context.Context, arg ConfirmTotpSecretParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, confirmTotpSecret, arg.UserID, arg.LastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createLoginChallenge = `-- name: CreateLoginChallenge :one
+insert into login_challenges (user_id, token_hash, expires_at, created_at)
+values ($1, $2, now() + interval '5 minutes', now())
+returning id, user_id, token_hash, expires_at, created_at
+`
+
+type CreateLoginChallengeParams struct {
+	UserID    uuid.UUID
+	TokenHash string
+}
+
+func (q *Queries) CreateLoginChallenge(ctx context.Context, arg CreateLoginChallengeParams) (LoginChallenge, error) {
+	row := q.db.QueryRowContext(ctx, createLoginChallenge, arg.UserID, arg.TokenHash)
+	var i LoginChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createRecoveryCodes = `-- name: CreateRecoveryCodes :exec
+insert into recovery_codes (user_id, code_hash, created_at)
+select $1, unnest($2::text[]), now()
+`
+
+type CreateRecoveryCodesParams struct {
+	UserID     uuid.UUID
+	CodeHashes []string
+}
+
+func (q *Queries) CreateRecoveryCodes(ctx context.Context, arg CreateRecoveryCodesParams) error {
+	_, err := q.db.ExecContext(ctx, createRecoveryCodes, arg.UserID, pq.Array(arg.CodeHashes))
+	return err
+}
+
+const deleteLoginChallenge = `-- name: DeleteLoginChallenge :execrows
+delete
+from login_challenges
+where id = $1
+`
+
+func (q *Queries) DeleteLoginChallenge(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteLoginChallenge, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteRecoveryCodes = `-- name: DeleteRecoveryCodes :exec
+delete
+from recovery_codes
+where user_id = $1
+`
+
+func (q *Queries) DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteRecoveryCodes, userID)
+	return err
+}
+
+const deleteTotpSecret = `-- name: DeleteTotpSecret :execrows
+delete
+from totp_secrets
+where user_id = $1
+`
+
+func (q *Queries) DeleteTotpSecret(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteTotpSecret, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getLoginChallengeByHash = `-- name: GetLoginChallengeByHash :one
+select id, user_id, token_hash, expires_at, created_at
+from login_challenges
+where token_hash = $1
+  and expires_at > now()
+`
+
+func (q *Queries) GetLoginChallengeByHash(ctx context.Context, tokenHash string) (LoginChallenge, error) {
+	row := q.db.QueryRowContext(ctx, getLoginChallengeByHash, tokenHash)
+	var i LoginChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getTotpSecret = `-- name: GetTotpSecret :one
+select user_id, secret, last_step, confirmed_at, created_at
+from totp_secrets
+where user_id = $1
+`
+
+func (q *Queries) GetTotpSecret(ctx context.Context, userID uuid.UUID) (TotpSecret, error) {
+	row := q.db.QueryRowContext(ctx, getTotpSecret, userID)
+	var i TotpSecret
+	err := row.Scan(
+		&i.UserID,
+		&i.Secret,
+		&i.LastStep,
+		&i.ConfirmedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const setPendingTotpSecret = `-- name: SetPendingTotpSecret :execrows
+insert into totp_secrets (user_id, secret, created_at)
+values ($1, $2, now())
+on conflict (user_id) do update
+    set secret     = excluded.secret,
+        last_step  = 0,
+        created_at = now()
+where totp_secrets.confirmed_at is null
+`
+
+type SetPendingTotpSecretParams struct {
+	UserID uuid.UUID
+	Secret []byte
+}
+
+// Replaces a pending secret, but never a confirmed one.
+func (q *Queries) SetPendingTotpSecret(ctx context.Context, arg SetPendingTotpSecretParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setPendingTotpSecret, arg.UserID, arg.Secret)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :execrows
+update recovery_codes
+set used_at = now()
+where user_id = $1
+  and code_hash = $2
+  and used_at is null
+`
+
+type UseRecoveryCodeParams struct {
+	UserID   uuid.UUID
+	CodeHash string
+}
+
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useRecoveryCode, arg.UserID, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useTotpStep = `-- name: UseTotpStep :execrows
+update totp_secrets
+set last_step = $2
+where user_id = $1
+  and confirmed_at is not null
+  and last_step < $2
+`
+
+type UseTotpStepParams struct {
+	UserID   uuid.UUID
+	LastStep int64
+}
+
+// Fails for codes at or before the last one used, so each works once.
+func (q *Queries) UseTotpStep(ctx context.Context, arg UseTotpStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTotpStep, arg.UserID, arg.LastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
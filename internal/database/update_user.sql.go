@@ -9,15 +9,17 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const updateUser = `-- name: UpdateUser :one
 update users
 set email    = $2,
     hashed_password = $3,
+    email_verified_at = case when email = $2 then email_verified_at end,
     updated_at = now()
 where id = $1
-returning id, email, created_at, updated_at, hashed_password, is_chirpy_red
+returning id, email, created_at, updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner
 `
 
 type UpdateUserParams struct {
@@ -36,6 +38,14 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		pq.Array(&i.Avatar),
+		pq.Array(&i.Banner),
 	)
 	return i, err
 }
@@ -9,13 +9,14 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const updateUserRedStatus = `-- name: UpdateUserRedStatus :one
 update users
 set is_chirpy_red= true,
     updated_at   = now()
-where id = $1 returning id, email, created_at, updated_at, hashed_password, is_chirpy_red
+where id = $1 returning id, email, created_at, updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner
 `
 
 func (q *Queries) UpdateUserRedStatus(ctx context.Context, id uuid.UUID) (User, error) {
@@ -28,6 +29,14 @@ func (q *Queries) UpdateUserRedStatus(ctx context.Context, id uuid.UUID) (User,
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		pq.Array(&i.Avatar),
+		pq.Array(&i.Banner),
 	)
 	return i, err
 }
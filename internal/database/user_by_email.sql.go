@@ -7,10 +7,12 @@ package database
 
 import (
 	"context"
+
+	"github.com/lib/pq"
 )
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-select id, email, created_at, updated_at, hashed_password, is_chirpy_red from users where email = $1
+select id, email, created_at, updated_at, hashed_password, is_chirpy_red, suspended_at, email_verified_at, handle, display_name, bio, website, avatar, banner from users where email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -23,6 +25,14 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		pq.Array(&i.Avatar),
+		pq.Array(&i.Banner),
 	)
 	return i, err
 }
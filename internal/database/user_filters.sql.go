@@ -0,0 +1,119 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: user_filters.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const countUserFilters = `-- name: CountUserFilters :one
+select count(*)
+from user_filters
+where user_id = $1
+`
+
+func (q *Queries) CountUserFilters(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUserFilters, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createUserFilter = `-- name: CreateUserFilter :one
+insert into user_filters (user_id, kind, value, action, expires_at, created_at)
+values ($1, $2, $3, $4, $5, now())
+returning id, user_id, kind, value, action, expires_at, created_at
+`
+
+type CreateUserFilterParams struct {
+	UserID    uuid.UUID
+	Kind      string
+	Value     string
+	Action    string
+	ExpiresAt sql.NullTime
+}
+
+func (q *Queries) CreateUserFilter(ctx context.Context, arg CreateUserFilterParams) (UserFilter, error) {
+	row := q.db.QueryRowContext(ctx, createUserFilter,
+		arg.UserID,
+		arg.Kind,
+		arg.Value,
+		arg.Action,
+		arg.ExpiresAt,
+	)
+	var i UserFilter
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Kind,
+		&i.Value,
+		&i.Action,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteUserFilter = `-- name: DeleteUserFilter :execrows
+delete
+from user_filters
+where id = $1
+  and user_id = $2
+`
+
+type DeleteUserFilterParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteUserFilter(ctx context.Context, arg DeleteUserFilterParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUserFilter, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getUserFilters = `-- name: GetUserFilters :many
+select id, user_id, kind, value, action, expires_at, created_at
+from user_filters
+where user_id = $1
+order by created_at
+`
+
+func (q *Queries) GetUserFilters(ctx context.Context, userID uuid.UUID) ([]UserFilter, error) {
+	rows, err := q.db.QueryContext(ctx, getUserFilters, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserFilter
+	for rows.Next() {
+		var i UserFilter
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Kind,
+			&i.Value,
+			&i.Action,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,486 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+update webhook_deliveries
+set next_attempt_at = now() + make_interval(secs => $1::integer)
+where id in (select d.id
+             from webhook_deliveries d
+                      join webhooks w on w.id = d.webhook_id
+             where d.status = 'pending'
+               and d.next_attempt_at <= now()
+               and w.disabled_at is null
+             order by d.next_attempt_at
+             limit $2 for update of d skip locked)
+returning id, webhook_id, event_id, event_type, payload, status, attempts, response_code, error, next_attempt_at, delivered_at, created_at
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseSeconds int32
+	BatchSize    int32
+}
+
+// Claimed deliveries are leased for lease_seconds, so another instance only
+// picks them up if this one dies mid-attempt.
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimWebhookDeliveries, arg.LeaseSeconds, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.ResponseCode,
+			&i.Error,
+			&i.NextAttemptAt,
+			&i.DeliveredAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countWebhooksByUser = `-- name: CountWebhooksByUser :one
+select count(*)
+from webhooks
+where user_id = $1
+`
+
+func (q *Queries) CountWebhooksByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countWebhooksByUser, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+insert into webhooks (user_id, url, secret, events, created_at, updated_at)
+values ($1, $2, $3, $4, now(), now())
+returning id, user_id, url, secret, events, failure_count, disabled_at, created_at, updated_at
+`
+
+type CreateWebhookParams struct {
+	UserID uuid.UUID
+	Url    string
+	Secret string
+	Events []string
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, createWebhook,
+		arg.UserID,
+		arg.Url,
+		arg.Secret,
+		pq.Array(arg.Events),
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+		&i.FailureCount,
+		&i.DisabledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :one
+insert into webhook_deliveries (webhook_id, event_id, event_type, payload, next_attempt_at, created_at)
+values ($1, $2, $3, $4, now(), now())
+returning id, webhook_id, event_id, event_type, payload, status, attempts, response_code, error, next_attempt_at, delivered_at, created_at
+`
+
+type CreateWebhookDeliveryParams struct {
+	WebhookID uuid.UUID
+	EventID   uuid.UUID
+	EventType string
+	Payload   string
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookDelivery,
+		arg.WebhookID,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.WebhookID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.ResponseCode,
+		&i.Error,
+		&i.NextAttemptAt,
+		&i.DeliveredAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :exec
+delete
+from webhooks
+where id = $1
+`
+
+func (q *Queries) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteWebhook, id)
+	return err
+}
+
+const getWebhook = `-- name: GetWebhook :one
+select id, user_id, url, secret, events, failure_count, disabled_at, created_at, updated_at
+from webhooks
+where id = $1
+`
+
+func (q *Queries) GetWebhook(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, getWebhook, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+		&i.FailureCount,
+		&i.DisabledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getWebhookDeliveries = `-- name: GetWebhookDeliveries :many
+select id, webhook_id, event_id, event_type, payload, status, attempts, response_code, error, next_attempt_at, delivered_at, created_at
+from webhook_deliveries
+where webhook_id = $1
+order by created_at desc
+limit $2
+`
+
+type GetWebhookDeliveriesParams struct {
+	WebhookID uuid.UUID
+	PageSize  int32
+}
+
+func (q *Queries) GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookDeliveries, arg.WebhookID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.ResponseCode,
+			&i.Error,
+			&i.NextAttemptAt,
+			&i.DeliveredAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+select id, webhook_id, event_id, event_type, payload, status, attempts, response_code, error, next_attempt_at, delivered_at, created_at
+from webhook_deliveries
+where id = $1
+`
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.WebhookID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.ResponseCode,
+		&i.Error,
+		&i.NextAttemptAt,
+		&i.DeliveredAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getWebhooksByUser = `-- name: GetWebhooksByUser :many
+select id, user_id, url, secret, events, failure_count, disabled_at, created_at, updated_at
+from webhooks
+where user_id = $1
+order by created_at
+`
+
+func (q *Queries) GetWebhooksByUser(ctx context.Context, userID uuid.UUID) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhooksByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.Events),
+			&i.FailureCount,
+			&i.DisabledAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhooksForEvent = `-- name: GetWebhooksForEvent :many
+select id, user_id, url, secret, events, failure_count, disabled_at, created_at, updated_at
+from webhooks
+where user_id = $1
+  and disabled_at is null
+  and $2::text = any (events)
+`
+
+type GetWebhooksForEventParams struct {
+	UserID    uuid.UUID
+	EventType string
+}
+
+func (q *Queries) GetWebhooksForEvent(ctx context.Context, arg GetWebhooksForEventParams) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhooksForEvent, arg.UserID, arg.EventType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.Events),
+			&i.FailureCount,
+			&i.DisabledAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordWebhookDeliveryFailure = `-- name: RecordWebhookDeliveryFailure :exec
+update webhook_deliveries
+set status          = case when $4::integer is null then 'failed' else 'pending' end,
+    attempts        = attempts + 1,
+    response_code   = $2,
+    error           = $3,
+    next_attempt_at = now() + make_interval(secs => $4::integer)
+where id = $1
+`
+
+type RecordWebhookDeliveryFailureParams struct {
+	ID             uuid.UUID
+	ResponseCode   sql.NullInt32
+	Error          sql.NullString
+	RetryInSeconds sql.NullInt32
+}
+
+// Without retry_in_seconds the delivery has failed for good.
+func (q *Queries) RecordWebhookDeliveryFailure(ctx context.Context, arg RecordWebhookDeliveryFailureParams) error {
+	_, err := q.db.ExecContext(ctx, recordWebhookDeliveryFailure,
+		arg.ID,
+		arg.ResponseCode,
+		arg.Error,
+		arg.RetryInSeconds,
+	)
+	return err
+}
+
+const recordWebhookDeliverySuccess = `-- name: RecordWebhookDeliverySuccess :exec
+update webhook_deliveries
+set status          = 'succeeded',
+    attempts        = attempts + 1,
+    response_code   = $2,
+    error           = null,
+    next_attempt_at = null,
+    delivered_at    = now()
+where id = $1
+`
+
+type RecordWebhookDeliverySuccessParams struct {
+	ID           uuid.UUID
+	ResponseCode sql.NullInt32
+}
+
+func (q *Queries) RecordWebhookDeliverySuccess(ctx context.Context, arg RecordWebhookDeliverySuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordWebhookDeliverySuccess, arg.ID, arg.ResponseCode)
+	return err
+}
+
+const recordWebhookFailure = `-- name: RecordWebhookFailure :one
+update webhooks
+set failure_count = failure_count + 1,
+    disabled_at   = case
+                        when disabled_at is null and failure_count + 1 >= $2::integer then now()
+                        else disabled_at end
+where id = $1
+returning id, user_id, url, secret, events, failure_count, disabled_at, created_at, updated_at
+`
+
+type RecordWebhookFailureParams struct {
+	ID           uuid.UUID
+	DisableAfter int32
+}
+
+// The webhook is disabled once disable_after attempts in a row have failed.
+func (q *Queries) RecordWebhookFailure(ctx context.Context, arg RecordWebhookFailureParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, recordWebhookFailure, arg.ID, arg.DisableAfter)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+		&i.FailureCount,
+		&i.DisabledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const recordWebhookSuccess = `-- name: RecordWebhookSuccess :exec
+update webhooks
+set failure_count = 0
+where id = $1
+`
+
+func (q *Queries) RecordWebhookSuccess(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, recordWebhookSuccess, id)
+	return err
+}
+
+const updateWebhook = `-- name: UpdateWebhook :one
+update webhooks
+set url           = $2,
+    events        = $3,
+    disabled_at   = case when $4::boolean then null else coalesce(disabled_at, now()) end,
+    failure_count = case when $4::boolean and disabled_at is not null then 0 else failure_count end,
+    updated_at    = now()
+where id = $1
+returning id, user_id, url, secret, events, failure_count, disabled_at, created_at, updated_at
+`
+
+type UpdateWebhookParams struct {
+	ID      uuid.UUID
+	Url     string
+	Events  []string
+	Enabled bool
+}
+
+// Re-enabling a webhook forgets its past failures.
+func (q *Queries) UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, updateWebhook,
+		arg.ID,
+		arg.Url,
+		pq.Array(arg.Events),
+		arg.Enabled,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+		&i.FailureCount,
+		&i.DisabledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
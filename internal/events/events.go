@@ -0,0 +1,74 @@
+package events
+
+import (
+	"github.com/dabates/httpServer/internal/database"
+	"sync"
+)
+
+// Event types published on the Bus.
+const (
+	ChirpCreated = "chirp.created"
+	ChirpDeleted = "chirp.deleted"
+	UserUpgraded = "user.upgraded"
+)
+
+// Event is a change to a chirp or, for UserUpgraded, to a user.
+type Event struct {
+	Type  string
+	Chirp database.Chirp
+	User  database.User
+}
+
+// Bus fans events out to in-process subscribers. Publishing never blocks: a
+// subscriber that falls behind by more than its buffer misses events, as do
+// other instances and anything published before a restart. It suits live
+// streams; webhook deliveries are queued in the database with the change
+// instead.
+type Bus struct {
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]chan Event
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: map[int]chan Event{}}
+}
+
+// Subscribe returns a channel of future events and a function that stops the
+// subscription and closes the channel.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	ch := make(chan Event, buffer)
+	b.subs[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs, id)
+			close(ch)
+		})
+	}
+}
+
+// Publish delivers e to every subscriber. Publishing on a nil Bus is a no-op.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Package lockout decides what failed logins cost: the first few are free,
+// each one after waits twice as long as the last before another attempt is
+// allowed, and enough in a row lock the account or address out for a while.
+package lockout
+
+import "time"
+
+// Policy sets the cost of failed logins for one kind of key, such as an
+// account or an IP address.
+type Policy struct {
+	// FreeAttempts is how many failures in a row cost nothing.
+	FreeAttempts int
+	// Delay is the wait after the first failure beyond FreeAttempts; it
+	// doubles after every further one, up to MaxDelay.
+	Delay    time.Duration
+	MaxDelay time.Duration
+	// LockAfter failures in a row lock the key out for LockFor.
+	LockAfter int
+	LockFor   time.Duration
+	// Window is how long after a failure the count starts over.
+	Window time.Duration
+}
+
+// AccountPolicy protects a single account from password guessing.
+func AccountPolicy() Policy {
+	return Policy{
+		FreeAttempts: 3,
+		Delay:        time.Second,
+		MaxDelay:     time.Minute,
+		LockAfter:    10,
+		LockFor:      15 * time.Minute,
+		Window:       time.Hour,
+	}
+}
+
+// AddressPolicy is looser, since many users may share an address, and
+// catches one address guessing across many accounts.
+func AddressPolicy() Policy {
+	return Policy{
+		FreeAttempts: 20,
+		Delay:        time.Second,
+		MaxDelay:     time.Minute,
+		LockAfter:    100,
+		LockFor:      time.Hour,
+		Window:       time.Hour,
+	}
+}
+
+// Penalty is what a failed attempt costs.
+type Penalty struct {
+	// Wait is how long until the next attempt is allowed; zero allows it
+	// straight away.
+	Wait time.Duration
+	// Locked is set when the failure locked the key out.
+	Locked bool
+}
+
+// After returns the penalty for the failures-th failure in a row.
+func (p Policy) After(failures int) Penalty {
+	if p.LockAfter > 0 && failures >= p.LockAfter {
+		return Penalty{Wait: p.LockFor, Locked: true}
+	}
+	if failures <= p.FreeAttempts {
+		return Penalty{}
+	}
+
+	wait := p.Delay
+	for i := p.FreeAttempts + 1; i < failures && wait < p.MaxDelay; i++ {
+		wait *= 2
+	}
+	return Penalty{Wait: min(wait, p.MaxDelay)}
+}
@@ -0,0 +1,51 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPenaltiesGrowThenLock(t *testing.T) {
+	p := Policy{
+		FreeAttempts: 3,
+		Delay:        time.Second,
+		MaxDelay:     5 * time.Second,
+		LockAfter:    8,
+		LockFor:      15 * time.Minute,
+	}
+
+	want := []Penalty{
+		{},
+		{},
+		{},
+		{Wait: time.Second},
+		{Wait: 2 * time.Second},
+		{Wait: 4 * time.Second},
+		{Wait: 5 * time.Second},
+		{Wait: 15 * time.Minute, Locked: true},
+	}
+	for i, w := range want {
+		if got := p.After(i + 1); got != w {
+			t.Errorf("After(%d) = %+v, want %+v", i+1, got, w)
+		}
+	}
+}
+
+func TestPolicyWithoutLock(t *testing.T) {
+	p := Policy{FreeAttempts: 1, Delay: time.Second, MaxDelay: time.Minute}
+
+	if got := p.After(1000); got.Locked || got.Wait != time.Minute {
+		t.Fatalf("Expected the delay to stop at MaxDelay without locking, got %+v", got)
+	}
+}
+
+func TestDefaultPoliciesLockEventually(t *testing.T) {
+	for name, p := range map[string]Policy{"account": AccountPolicy(), "address": AddressPolicy()} {
+		if p.After(p.FreeAttempts).Wait != 0 {
+			t.Errorf("%s: expected the free attempts to cost nothing", name)
+		}
+		if !p.After(p.LockAfter).Locked {
+			t.Errorf("%s: expected %d failures to lock", name, p.LockAfter)
+		}
+	}
+}
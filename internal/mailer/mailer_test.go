@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	m := &MemoryMailer{}
+	msg := Message{To: "a@example.com", Subject: "Hi", Body: "Hello"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	got := m.Messages()
+	if len(got) != 1 || got[0] != msg {
+		t.Fatalf("Expected the message to be kept, got %+v", got)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := &FileMailer{Dir: dir, From: "chirpy@example.com"}
+	err := m.Send(context.Background(), Message{To: "a@example.com", Subject: "Verify", Body: "line one\nline two"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one file, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(dir + "/" + files[0].Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"From: chirpy@example.com\r\n", "To: a@example.com\r\n", "Subject: Verify\r\n", "\r\n\r\nline one\r\nline two"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("Expected %q in %q", want, data)
+		}
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	m := &MemoryMailer{}
+	err := m.Send(context.Background(), Message{To: "a@example.com\r\nBcc: b@example.com", Subject: "Hi"})
+	if err == nil {
+		t.Fatalf("Expected a line break in To to be refused")
+	}
+}
@@ -0,0 +1,312 @@
+// Package media turns uploaded images into the resized copies Chirpy serves
+// and stores them on disk under the hash of their contents.
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// MaxPixels caps the dimensions of an upload, so a small file cannot
+// decode into an image that exhausts memory.
+const MaxPixels = 40_000_000
+
+// jpegQuality is used for every JPEG rendition.
+const jpegQuality = 85
+
+var (
+	ErrUnsupported = errors.New("media: images must be JPEG, PNG or WebP")
+	ErrTooLarge    = errors.New("media: image dimensions are too large")
+)
+
+// Size is the width and height of a rendition in pixels.
+type Size struct {
+	Width  int
+	Height int
+}
+
+func (s Size) String() string {
+	return fmt.Sprintf("%dx%d", s.Width, s.Height)
+}
+
+// Spec lists the renditions made of one kind of upload, largest first. All
+// sizes share an aspect ratio; uploads are cropped to it around the centre.
+type Spec struct {
+	Sizes []Size
+}
+
+var (
+	Avatar = Spec{Sizes: []Size{{400, 400}, {200, 200}, {96, 96}, {48, 48}}}
+	Banner = Spec{Sizes: []Size{{1500, 500}, {600, 200}}}
+)
+
+// Rendition is one resized copy of an upload, encoded and ready to store.
+type Rendition struct {
+	Size        Size
+	ContentType string
+	Data        []byte
+}
+
+// Process decodes a JPEG, PNG or WebP upload and renders every size in spec.
+// Renditions are encoded from the decoded pixels alone, so EXIF, ICC and any
+// other metadata in the upload is dropped; a JPEG's EXIF orientation is
+// applied first so photos stay upright. Opaque images become JPEGs and
+// images with transparency PNGs.
+func Process(data []byte, spec Spec) ([]Rendition, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png" && format != "webp") {
+		return nil, ErrUnsupported
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrUnsupported
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = exifOrientation(data)
+	}
+	// Orientations 5 to 8 turn the image a quarter, so the crop and the
+	// scaling happen in the upload's own, sideways, coordinates.
+	sideways := orientation >= 5 && orientation <= 8
+
+	opaque := isOpaque(img)
+	renditions := make([]Rendition, 0, len(spec.Sizes))
+	for _, size := range spec.Sizes {
+		target := size
+		if sideways {
+			target = Size{Width: size.Height, Height: size.Width}
+		}
+
+		dst := image.NewRGBA(image.Rect(0, 0, target.Width, target.Height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop(img.Bounds(), target), draw.Src, nil)
+		out := orient(dst, orientation)
+
+		var buf bytes.Buffer
+		rendition := Rendition{Size: size}
+		if opaque {
+			rendition.ContentType = "image/jpeg"
+			err = jpeg.Encode(&buf, out, &jpeg.Options{Quality: jpegQuality})
+		} else {
+			rendition.ContentType = "image/png"
+			err = png.Encode(&buf, out)
+		}
+		if err != nil {
+			return nil, err
+		}
+		rendition.Data = buf.Bytes()
+		renditions = append(renditions, rendition)
+	}
+
+	return renditions, nil
+}
+
+// crop returns the largest centred rectangle of bounds with the aspect
+// ratio of size.
+func crop(bounds image.Rectangle, size Size) image.Rectangle {
+	w, h := bounds.Dx(), bounds.Dy()
+	if w*size.Height > h*size.Width {
+		cw := h * size.Width / size.Height
+		x := bounds.Min.X + (w-cw)/2
+		return image.Rect(x, bounds.Min.Y, x+cw, bounds.Max.Y)
+	}
+	ch := w * size.Height / size.Width
+	y := bounds.Min.Y + (h-ch)/2
+	return image.Rect(bounds.Min.X, y, bounds.Max.X, y+ch)
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// orient applies an EXIF orientation (1 to 8) to img.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.SetRGBA(x, y, img.RGBAAt(sx, sy))
+		}
+	}
+	return dst
+}
+
+// exifOrientation returns the orientation tag of a JPEG's EXIF data, or 1
+// if there is none.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		// Start of scan: the metadata segments are over.
+		if marker == 0xDA {
+			return 1
+		}
+		length := int(data[i+2])<<8 | int(data[i+3])
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var u16 func([]byte) int
+	var u32 func([]byte) int
+	switch string(tiff[:2]) {
+	case "II":
+		u16 = func(b []byte) int { return int(b[0]) | int(b[1])<<8 }
+		u32 = func(b []byte) int { return u16(b) | u16(b[2:])<<16 }
+	case "MM":
+		u16 = func(b []byte) int { return int(b[0])<<8 | int(b[1]) }
+		u32 = func(b []byte) int { return u16(b)<<16 | u16(b[2:]) }
+	default:
+		return 1
+	}
+
+	ifd := u32(tiff[4:])
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := u16(tiff[ifd:])
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if u16(tiff[entry:]) == 0x0112 {
+			orientation := u16(tiff[entry+8:])
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// namePattern is what Store names look like: the hex SHA-256 of the file
+// and its extension.
+var namePattern = regexp.MustCompile(`^[0-9a-f]{64}\.(jpg|png)$`)
+
+// Store keeps renditions on disk, each named by the hash of its contents, so
+// identical files are stored once and a name always means the same bytes.
+type Store struct {
+	// Dir is where files are written, spread over subdirectories named by
+	// the first two characters of their names.
+	Dir string
+	// BaseURL is prefixed to names to make URLs, such as "/media/".
+	BaseURL string
+}
+
+// Put writes r to the store, unless it is already there, and returns its name.
+func (s *Store) Put(r Rendition) (string, error) {
+	ext := ".png"
+	if r.ContentType == "image/jpeg" {
+		ext = ".jpg"
+	}
+	sum := sha256.Sum256(r.Data)
+	name := hex.EncodeToString(sum[:]) + ext
+
+	path, _ := s.Path(name)
+	if _, err := os.Stat(path); err == nil {
+		return name, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	// Write under a temporary name first so a crash never leaves a partial
+	// file behind a name readers trust.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(r.Data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Path returns where the file called name is kept, or false if name is not
+// one Put could have returned.
+func (s *Store) Path(name string) (string, bool) {
+	if !namePattern.MatchString(name) {
+		return "", false
+	}
+	return filepath.Join(s.Dir, name[:2], name), true
+}
+
+// URL returns where clients can fetch the file called name.
+func (s *Store) URL(name string) string {
+	return s.BaseURL + name
+}
@@ -0,0 +1,201 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation inserts an EXIF segment holding orientation right after
+// the JPEG's start of image marker.
+func withOrientation(data []byte, orientation byte) []byte {
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8,
+		0, 1,
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0,
+		0, 0, 0, 0,
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := append([]byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestProcessRendersEverySize(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+
+	renditions, err := Process(encodeJPEG(t, img), Avatar)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if len(renditions) != len(Avatar.Sizes) {
+		t.Fatalf("Expected %d renditions, got %d", len(Avatar.Sizes), len(renditions))
+	}
+	for i, r := range renditions {
+		if r.ContentType != "image/jpeg" {
+			t.Errorf("Expected an opaque image to become a JPEG, got %s", r.ContentType)
+		}
+		decoded, format, err := image.Decode(bytes.NewReader(r.Data))
+		if err != nil || format != "jpeg" {
+			t.Fatalf("Rendition %d does not decode as JPEG: %v", i, err)
+		}
+		if b := decoded.Bounds(); b.Dx() != Avatar.Sizes[i].Width || b.Dy() != Avatar.Sizes[i].Height {
+			t.Errorf("Expected %v, got %dx%d", Avatar.Sizes[i], b.Dx(), b.Dy())
+		}
+	}
+}
+
+func TestProcessKeepsTransparency(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	img.Set(10, 10, color.NRGBA{R: 255, A: 128})
+
+	renditions, err := Process(encodePNG(t, img), Banner)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	for _, r := range renditions {
+		if r.ContentType != "image/png" {
+			t.Errorf("Expected a transparent image to stay a PNG, got %s", r.ContentType)
+		}
+	}
+}
+
+func TestProcessStripsMetadataAndAppliesOrientation(t *testing.T) {
+	// Stored sideways: 200 wide, 100 tall, red on the left.
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			c := color.RGBA{B: 255, A: 255}
+			if x < 100 {
+				c = color.RGBA{R: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	// Orientation 6 means the camera was turned a quarter clockwise, so the
+	// upright image is 100 wide and 200 tall with red at the top.
+	data := withOrientation(encodeJPEG(t, img), 6)
+	if exifOrientation(data) != 6 {
+		t.Fatalf("Expected to read orientation 6 back")
+	}
+
+	renditions, err := Process(data, Spec{Sizes: []Size{{50, 100}}})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	out := renditions[0].Data
+	if bytes.Contains(out, []byte("Exif")) {
+		t.Errorf("Expected EXIF data to be stripped")
+	}
+
+	decoded, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Rendition does not decode: %v", err)
+	}
+	if b := decoded.Bounds(); b.Dx() != 50 || b.Dy() != 100 {
+		t.Fatalf("Expected 50x100, got %dx%d", b.Dx(), b.Dy())
+	}
+	top, _, _, _ := decoded.At(25, 10).RGBA()
+	bottom, _, _, _ := decoded.At(25, 90).RGBA()
+	if top < 0xC000 || bottom > 0x4000 {
+		t.Errorf("Expected red at the top only, got red %x at the top and %x at the bottom", top, bottom)
+	}
+}
+
+func TestProcessRejectsOtherFormats(t *testing.T) {
+	gif := []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;")
+	if _, err := Process(gif, Avatar); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported for a GIF, got %v", err)
+	}
+	if _, err := Process([]byte("not an image"), Avatar); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported for text, got %v", err)
+	}
+}
+
+func TestProcessRejectsHugeDimensions(t *testing.T) {
+	// A PNG header claiming 10000x10000 pixels is enough to be refused
+	// before anything is decoded.
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	data := buf.Bytes()
+	copy(data[16:24], []byte{0, 0, 0x27, 0x10, 0, 0, 0x27, 0x10})
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+
+	if _, err := Process(data, Avatar); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestStoreIsContentAddressed(t *testing.T) {
+	store := &Store{Dir: t.TempDir(), BaseURL: "/media/"}
+	r := Rendition{ContentType: "image/png", Data: []byte("pixels")}
+
+	name, err := store.Put(r)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	again, err := store.Put(r)
+	if err != nil || again != name {
+		t.Fatalf("Expected the same name for the same bytes, got %q and %q (%v)", name, again, err)
+	}
+
+	path, ok := store.Path(name)
+	if !ok {
+		t.Fatalf("Expected %q to be a valid name", name)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "pixels" {
+		t.Fatalf("Expected the stored bytes back, got %q (%v)", data, err)
+	}
+	if store.URL(name) != "/media/"+name {
+		t.Errorf("Unexpected URL %q", store.URL(name))
+	}
+
+	other, _ := store.Put(Rendition{ContentType: "image/png", Data: []byte("other pixels")})
+	if other == name {
+		t.Errorf("Expected different bytes to get a different name")
+	}
+}
+
+func TestStoreRejectsForeignNames(t *testing.T) {
+	store := &Store{Dir: t.TempDir()}
+	for _, name := range []string{"../secret", "abc.jpg", "", "0000000000000000000000000000000000000000000000000000000000000000.gif"} {
+		if _, ok := store.Path(name); ok {
+			t.Errorf("Expected %q to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MinCompressSize is the smallest body worth compressing. Anything shorter is
+// sent as is, since the encoding overhead would eat the savings.
+const MinCompressSize = 1024
+
+// incompressibleTypes are content types that are already compressed.
+var incompressibleTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-brotli",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/octet-stream",
+	"application/pdf",
+}
+
+// compressibleImages are the image types that are really text.
+var compressibleImages = []string{
+	"image/svg+xml",
+	"image/x-icon",
+}
+
+var gzipPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+var brotliPool = sync.Pool{
+	New: func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, 4)
+	},
+}
+
+// Compress negotiates Accept-Encoding with the client and compresses the
+// response with brotli or gzip. Small bodies, already-compressed content
+// types and responses that set their own Content-Encoding pass through
+// untouched. Flushes are forwarded, so streaming handlers keep working.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+			status:         http.StatusOK,
+		}
+		defer cw.close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks the best encoding we support from an
+// Accept-Encoding header, or "" when the body must stay uncompressed.
+// Brotli wins over gzip when the client rates them equally.
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	quality := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, q := ParseQuality(part)
+		if name == "" {
+			continue
+		}
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		quality[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range []string{"br", "gzip"} {
+		q, ok := quality[name]
+		if !ok {
+			if name == "gzip" {
+				q, ok = quality["x-gzip"]
+			}
+			if !ok && wildcard >= 0 {
+				q, ok = wildcard, true
+			}
+		}
+		if ok && q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+
+	return best
+}
+
+// ParseQuality splits one element of an Accept or Accept-* list into its
+// lowercased value and q parameter. A missing or invalid q counts as 1.
+func ParseQuality(part string) (string, float64) {
+	fields := strings.Split(part, ";")
+	name := strings.ToLower(strings.TrimSpace(fields[0]))
+	q := 1.0
+	for _, param := range fields[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || strings.ToLower(strings.TrimSpace(key)) != "q" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err == nil {
+			q = parsed
+		}
+	}
+
+	return name, q
+}
+
+func isCompressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, t := range compressibleImages {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	for _, t := range incompressibleTypes {
+		if strings.HasPrefix(contentType, t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compressWriter buffers the start of a response until it knows whether the
+// body is worth compressing, then either starts an encoder or replays the
+// buffer unchanged.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+
+	buf     []byte
+	decided bool
+	encoder io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided {
+		return
+	}
+	cw.status = status
+
+	// Informational responses go straight out and don't end the headers.
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if status == http.StatusNoContent || status == http.StatusNotModified ||
+		status == http.StatusPartialContent || status == http.StatusSwitchingProtocols {
+		cw.passthrough()
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < MinCompressSize && !cw.knownLarge() {
+			return len(p), nil
+		}
+		cw.decide(true)
+		return len(p), cw.flushBuffer()
+	}
+
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush sends whatever has been written so far. A handler that flushes is
+// streaming, so a pending body is compressed even if it is still small.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(true)
+		if err := cw.flushBuffer(); err != nil {
+			return
+		}
+	}
+
+	if f, ok := cw.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the underlying ResponseWriter does not support hijacking")
+	}
+
+	cw.decided = true
+	return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the real ResponseWriter.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// knownLarge reports whether the handler announced a body big enough to
+// compress, letting us start before the buffer fills up.
+func (cw *compressWriter) knownLarge() bool {
+	length, err := strconv.Atoi(cw.Header().Get("Content-Length"))
+	return err == nil && length >= MinCompressSize
+}
+
+// decide settles on compressing or not and writes the headers. compress is
+// false when the body turned out to be too small.
+func (cw *compressWriter) decide(compress bool) {
+	if cw.decided {
+		return
+	}
+
+	header := cw.Header()
+	if header.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// Sniff before compressing, otherwise net/http would sniff the
+		// compressed bytes.
+		header.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if !compress || header.Get("Content-Encoding") != "" || !isCompressible(header.Get("Content-Type")) {
+		cw.passthrough()
+		return
+	}
+
+	cw.decided = true
+	header.Set("Content-Encoding", cw.encoding)
+	header.Del("Content-Length")
+	header.Del("Accept-Ranges")
+	// The compressed bytes differ from the identity ones, so a strong
+	// validator no longer holds.
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+
+	switch cw.encoding {
+	case "br":
+		bw := brotliPool.Get().(*brotli.Writer)
+		bw.Reset(cw.ResponseWriter)
+		cw.encoder = bw
+	default:
+		gw := gzipPool.Get().(*gzip.Writer)
+		gw.Reset(cw.ResponseWriter)
+		cw.encoder = gw
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+func (cw *compressWriter) passthrough() {
+	if cw.decided {
+		return
+	}
+	cw.decided = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+func (cw *compressWriter) flushBuffer() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+	if cw.encoder != nil {
+		_, err := cw.encoder.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// close finishes the response once the handler has returned.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		// The handler finished without filling the buffer: too small.
+		cw.decide(false)
+		cw.flushBuffer()
+	}
+
+	if cw.encoder == nil {
+		return
+	}
+
+	cw.encoder.Close()
+	switch enc := cw.encoder.(type) {
+	case *brotli.Writer:
+		enc.Reset(io.Discard)
+		brotliPool.Put(enc)
+	case *gzip.Writer:
+		enc.Reset(io.Discard)
+		gzipPool.Put(enc)
+	}
+	cw.encoder = nil
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serve(t *testing.T, acceptEncoding string, handler http.HandlerFunc) *http.Response {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	Compress(handler).ServeHTTP(rec, req)
+
+	return rec.Result()
+}
+
+func largeJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"body":"` + strings.Repeat("chirp ", 500) + `"}`))
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                         "",
+		"identity":                 "",
+		"gzip":                     "gzip",
+		"gzip, deflate, br":        "br",
+		"br;q=0.5, gzip":           "gzip",
+		"br;q=0, gzip;q=0":         "",
+		"*":                        "br",
+		"*;q=0.1, gzip;q=0.8":      "gzip",
+		"x-gzip":                   "gzip",
+		"deflate":                  "",
+		"BR;Q=1.0, GZIP;Q=0.9":     "br",
+		"gzip;q=0.9, br;q=invalid": "br",
+	}
+
+	for header, want := range cases {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompressGzip(t *testing.T) {
+	resp := serve(t, "gzip", largeJSON)
+
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip encoding, got %q", resp.Header.Get("Content-Encoding"))
+	}
+	if resp.Header.Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("Expected Vary: Accept-Encoding, got %q", resp.Header.Get("Vary"))
+	}
+
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip body: %v", err)
+	}
+	body, _ := io.ReadAll(zr)
+	if !strings.Contains(string(body), "chirp chirp") {
+		t.Fatalf("Unexpected decompressed body: %q", body)
+	}
+}
+
+func TestCompressBrotli(t *testing.T) {
+	resp := serve(t, "gzip, br", largeJSON)
+
+	if resp.Header.Get("Content-Encoding") != "br" {
+		t.Fatalf("Expected br encoding, got %q", resp.Header.Get("Content-Encoding"))
+	}
+
+	body, err := io.ReadAll(brotli.NewReader(resp.Body))
+	if err != nil {
+		t.Fatalf("Failed to read brotli body: %v", err)
+	}
+	if !strings.HasPrefix(string(body), `{"body":"chirp`) {
+		t.Fatalf("Unexpected decompressed body: %q", body)
+	}
+}
+
+func TestCompressSkipsSmallBodies(t *testing.T) {
+	resp := serve(t, "gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("OK"))
+	})
+
+	if resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("Expected no encoding, got %q", resp.Header.Get("Content-Encoding"))
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "OK" {
+		t.Fatalf("Expected OK, got %q", body)
+	}
+}
+
+func TestCompressSkipsCompressedTypes(t *testing.T) {
+	resp := serve(t, "gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(bytes.Repeat([]byte{0x89}, 4096))
+	})
+
+	if resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("Expected no encoding for image/png, got %q", resp.Header.Get("Content-Encoding"))
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if len(body) != 4096 {
+		t.Fatalf("Expected 4096 bytes, got %d", len(body))
+	}
+}
+
+func TestCompressWeakensETag(t *testing.T) {
+	resp := serve(t, "gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		largeJSON(w, r)
+	})
+
+	if resp.Header.Get("ETag") != `W/"abc"` {
+		t.Fatalf("Expected weakened ETag, got %q", resp.Header.Get("ETag"))
+	}
+}
+
+func TestCompressStreaming(t *testing.T) {
+	resp := serve(t, "gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			w.Write([]byte("data: tick\n\n"))
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip encoding for a flushed stream, got %q", resp.Header.Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip body: %v", err)
+	}
+	body, _ := io.ReadAll(zr)
+	if strings.Count(string(body), "data: tick") != 3 {
+		t.Fatalf("Expected three events, got %q", body)
+	}
+}
+
+func TestCompressNotModified(t *testing.T) {
+	resp := serve(t, "gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("Expected 304, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("Expected no encoding on 304, got %q", resp.Header.Get("Content-Encoding"))
+	}
+}
@@ -0,0 +1,205 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// Schema is the subset of JSON Schema used by openapi.json.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 SchemaType         `json:"type"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MaxItems             *int               `json:"maxItems"`
+	Format               string             `json:"format"`
+	Pattern              string             `json:"pattern"`
+
+	resolved    *Schema
+	patternOnce sync.Once
+	pattern     *regexp.Regexp
+}
+
+// SchemaType is a JSON Schema type, which 3.1 allows to be a list such as
+// ["string", "null"].
+type SchemaType []string
+
+func (t *SchemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = SchemaType{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+func (t SchemaType) allows(name string) bool {
+	if len(t) == 0 {
+		return true
+	}
+	for _, allowed := range t {
+		if allowed == name || (allowed == "number" && name == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// Detail describes one way in which a request or response breaks the spec.
+type Detail struct {
+	Location string `json:"location"`
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// Validate checks a decoded JSON value against the schema. field names the
+// value in the returned details, e.g. "body" or "author.id".
+func (s *Schema) Validate(location string, field string, value interface{}) []Detail {
+	if s == nil {
+		return nil
+	}
+	if s.resolved != nil {
+		return s.resolved.Validate(location, field, value)
+	}
+
+	fail := func(format string, args ...interface{}) []Detail {
+		return []Detail{{Location: location, Field: field, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	kind := jsonType(value)
+	if !s.Type.allows(kind) {
+		return fail("expected %s, got %s", joinTypes(s.Type), kind)
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if allowed == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail("must be one of %v", s.Enum)
+		}
+	}
+
+	var details []Detail
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength != nil && length < *s.MinLength {
+			details = append(details, fail("must be at least %d characters", *s.MinLength)...)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			details = append(details, fail("must be at most %d characters", *s.MaxLength)...)
+		}
+		if s.Format == "uuid" && !uuidPattern.MatchString(v) {
+			details = append(details, fail("must be a UUID")...)
+		}
+		if re := s.compiledPattern(); re != nil && !re.MatchString(v) {
+			details = append(details, fail("must match %s", s.Pattern)...)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			details = append(details, fail("must be at least %v", *s.Minimum)...)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			details = append(details, fail("must be at most %v", *s.Maximum)...)
+		}
+	case []interface{}:
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			details = append(details, fail("must have at most %d items", *s.MaxItems)...)
+		}
+		for i, item := range v {
+			details = append(details, s.Items.Validate(location, fmt.Sprintf("%s[%d]", field, i), item)...)
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				details = append(details, Detail{Location: location, Field: join(field, name), Message: "is required"})
+			}
+		}
+
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					details = append(details, Detail{Location: location, Field: join(field, name), Message: "is not allowed"})
+				}
+				continue
+			}
+			details = append(details, prop.Validate(location, join(field, name), v[name])...)
+		}
+	}
+
+	return details
+}
+
+func (s *Schema) compiledPattern() *regexp.Regexp {
+	s.patternOnce.Do(func() {
+		if s.Pattern != "" {
+			s.pattern, _ = regexp.Compile(s.Pattern)
+		}
+	})
+	return s.pattern
+}
+
+func join(field string, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+func joinTypes(types SchemaType) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	return fmt.Sprint([]string(types))
+}
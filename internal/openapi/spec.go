@@ -0,0 +1,169 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// specJSON is the OpenAPI document for every route registered in main.go.
+// Keep it in step with the handlers: the validator rejects requests that it
+// does not describe.
+//
+//go:embed openapi.json
+var specJSON []byte
+
+// Document is the subset of an OpenAPI 3.1 document the validator needs.
+type Document struct {
+	Paths      map[string]*PathItem `json:"paths"`
+	Components struct {
+		Schemas   map[string]*Schema   `json:"schemas"`
+		Responses map[string]*Response `json:"responses"`
+	} `json:"components"`
+}
+
+type PathItem struct {
+	Parameters []*Parameter `json:"parameters"`
+	Get        *Operation   `json:"get"`
+	Put        *Operation   `json:"put"`
+	Post       *Operation   `json:"post"`
+	Patch      *Operation   `json:"patch"`
+	Delete     *Operation   `json:"delete"`
+}
+
+func (p *PathItem) operation(method string) *Operation {
+	switch method {
+	case "GET", "HEAD":
+		return p.Get
+	case "PUT":
+		return p.Put
+	case "POST":
+		return p.Post
+	case "PATCH":
+		return p.Patch
+	case "DELETE":
+		return p.Delete
+	}
+	return nil
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref     string                `json:"$ref"`
+	Content map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Load parses the embedded document and resolves its $refs.
+func Load() (*Document, error) {
+	doc := &Document{}
+	if err := json.Unmarshal(specJSON, doc); err != nil {
+		return nil, fmt.Errorf("parsing openapi.json: %w", err)
+	}
+
+	for name, schema := range doc.Components.Schemas {
+		if err := doc.resolveSchema(schema, map[*Schema]bool{}); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+
+	for path, item := range doc.Paths {
+		for _, param := range item.Parameters {
+			if err := doc.resolveSchema(param.Schema, map[*Schema]bool{}); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		for _, op := range []*Operation{item.Get, item.Put, item.Post, item.Patch, item.Delete} {
+			if op == nil {
+				continue
+			}
+			if err := doc.resolveOperation(op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", path, op.OperationID, err)
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+func (d *Document) resolveOperation(op *Operation) error {
+	for _, param := range op.Parameters {
+		if err := d.resolveSchema(param.Schema, map[*Schema]bool{}); err != nil {
+			return err
+		}
+	}
+
+	if op.RequestBody != nil {
+		for _, media := range op.RequestBody.Content {
+			if err := d.resolveSchema(media.Schema, map[*Schema]bool{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for status, resp := range op.Responses {
+		if resp.Ref != "" {
+			name := strings.TrimPrefix(resp.Ref, "#/components/responses/")
+			shared, ok := d.Components.Responses[name]
+			if !ok {
+				return fmt.Errorf("unknown response %s", resp.Ref)
+			}
+			op.Responses[status] = shared
+			resp = shared
+		}
+		for _, media := range resp.Content {
+			if err := d.resolveSchema(media.Schema, map[*Schema]bool{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveSchema points every $ref in schema at the component it names.
+func (d *Document) resolveSchema(schema *Schema, seen map[*Schema]bool) error {
+	if schema == nil || seen[schema] {
+		return nil
+	}
+	seen[schema] = true
+
+	if schema.Ref != "" && schema.resolved == nil {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		target, ok := d.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("unknown schema %s", schema.Ref)
+		}
+		schema.resolved = target
+	}
+
+	for _, prop := range schema.Properties {
+		if err := d.resolveSchema(prop, seen); err != nil {
+			return err
+		}
+	}
+
+	return d.resolveSchema(schema.Items, seen)
+}
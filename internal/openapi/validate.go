@@ -0,0 +1,349 @@
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxValidatedBody caps how much of a request body the validator reads.
+const maxValidatedBody = 1 << 20
+
+//go:embed docs.html
+var docsHTML []byte
+
+// ValidationError is the body of the 400 sent for requests that do not match
+// the spec, and of the 500 sent in dev for responses that do not.
+type ValidationError struct {
+	Error   string   `json:"error"`
+	Details []Detail `json:"details"`
+}
+
+type route struct {
+	segments []string
+	item     *PathItem
+}
+
+// Validator checks requests, and optionally responses, against the spec.
+type Validator struct {
+	routes            []route
+	validateResponses bool
+}
+
+// NewValidator loads the spec. Responses are validated too when
+// validateResponses is set, which main.go does on the dev platform.
+func NewValidator(validateResponses bool) (*Validator, error) {
+	doc, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Validator{validateResponses: validateResponses}
+	for path, item := range doc.Paths {
+		v.routes = append(v.routes, route{
+			segments: strings.Split(strings.Trim(path, "/"), "/"),
+			item:     item,
+		})
+	}
+
+	return v, nil
+}
+
+// find returns the path item matching path and the values of its templated
+// segments. Literal segments win over templates.
+func (v *Validator) find(path string) (*PathItem, map[string]string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	var best *route
+	var bestParams map[string]string
+	bestLiterals := -1
+	for i := range v.routes {
+		rt := &v.routes[i]
+		if len(rt.segments) != len(segments) {
+			continue
+		}
+
+		params := map[string]string{}
+		literals := 0
+		matched := true
+		for j, seg := range rt.segments {
+			if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+				params[seg[1:len(seg)-1]] = segments[j]
+				continue
+			}
+			if seg != segments[j] {
+				matched = false
+				break
+			}
+			literals++
+		}
+		if matched && literals > bestLiterals {
+			best, bestParams, bestLiterals = rt, params, literals
+		}
+	}
+
+	if best == nil {
+		return nil, nil
+	}
+	return best.item, bestParams
+}
+
+// Middleware rejects requests that break the spec with a structured 400.
+// Paths the spec does not know, like the /app/ file server, pass through.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		item, pathParams := v.find(r.URL.Path)
+		if item == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		op := item.operation(r.Method)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		details := validateParameters(r, pathParams, append(append([]*Parameter{}, item.Parameters...), op.Parameters...))
+		details = append(details, validateBody(r, op.RequestBody)...)
+		if len(details) > 0 {
+			writeValidationError(w, http.StatusBadRequest, "request does not match the API specification", details)
+			return
+		}
+
+		if !v.validateResponses {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &responseRecorder{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if details := validateResponse(rec, op); len(details) > 0 {
+			log.Printf("openapi: %s %s returned a response that breaks the spec: %+v", r.Method, r.URL.Path, details)
+			writeValidationError(w, http.StatusInternalServerError, "response does not match the API specification", details)
+			return
+		}
+		rec.replay(w)
+	})
+}
+
+func validateParameters(r *http.Request, pathParams map[string]string, params []*Parameter) []Detail {
+	// Operation parameters override path item parameters of the same name.
+	effective := map[string]*Parameter{}
+	var order []string
+	for _, param := range params {
+		key := param.In + ":" + param.Name
+		if _, ok := effective[key]; !ok {
+			order = append(order, key)
+		}
+		effective[key] = param
+	}
+
+	query := r.URL.Query()
+	var details []Detail
+	for _, key := range order {
+		param := effective[key]
+
+		var raw string
+		var present bool
+		switch param.In {
+		case "path":
+			raw, present = pathParams[param.Name]
+		case "query":
+			present = query.Has(param.Name)
+			raw = query.Get(param.Name)
+		case "header":
+			raw = r.Header.Get(param.Name)
+			present = raw != ""
+		default:
+			continue
+		}
+
+		if !present {
+			if param.Required {
+				details = append(details, Detail{Location: param.In, Field: param.Name, Message: "is required"})
+			}
+			continue
+		}
+
+		value, ok := coerce(raw, param.Schema)
+		if !ok {
+			details = append(details, Detail{Location: param.In, Field: param.Name, Message: "expected " + joinTypes(param.Schema.Type)})
+			continue
+		}
+		details = append(details, param.Schema.Validate(param.In, param.Name, value)...)
+	}
+
+	return details
+}
+
+// coerce converts a raw parameter string to the JSON type its schema wants.
+func coerce(raw string, schema *Schema) (interface{}, bool) {
+	if schema == nil || len(schema.Type) == 0 || schema.Type.allows("string") {
+		return raw, true
+	}
+	if schema.Type.allows("integer") || schema.Type.allows("number") {
+		n, err := strconv.ParseFloat(raw, 64)
+		return n, err == nil
+	}
+	if schema.Type.allows("boolean") {
+		b, err := strconv.ParseBool(raw)
+		return b, err == nil
+	}
+	return raw, true
+}
+
+func validateBody(r *http.Request, body *RequestBody) []Detail {
+	if body == nil {
+		return nil
+	}
+
+	// Clients that omit Content-Type have always been treated as JSON.
+	mediaType := "application/json"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, _ = mime.ParseMediaType(ct)
+	}
+	// Image uploads are left unread for the handler, which knows how large
+	// they may be.
+	if _, ok := body.Content[mediaType]; ok && strings.HasPrefix(mediaType, "image/") {
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBody+1))
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return []Detail{{Location: "body", Message: err.Error()}}
+	}
+	if len(data) > maxValidatedBody {
+		return []Detail{{Location: "body", Message: "is too large"}}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		if body.Required {
+			return []Detail{{Location: "body", Message: "is required"}}
+		}
+		return nil
+	}
+
+	media, ok := body.Content[mediaType]
+	if !ok {
+		return []Detail{{Location: "header", Field: "Content-Type", Message: "unsupported media type " + mediaType}}
+	}
+	if mediaType != "application/json" || media.Schema == nil {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return []Detail{{Location: "body", Message: "invalid JSON: " + err.Error()}}
+	}
+
+	return media.Schema.Validate("body", "", value)
+}
+
+func validateResponse(rec *responseRecorder, op *Operation) []Detail {
+	status := strconv.Itoa(rec.status)
+	resp, ok := op.Responses[status]
+	if !ok {
+		resp, ok = op.Responses[status[:1]+"XX"]
+	}
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		return []Detail{{Location: "response", Message: "status " + status + " is not documented"}}
+	}
+
+	if rec.body.Len() == 0 || len(resp.Content) == 0 {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(rec.header.Get("Content-Type"))
+	if mediaType == "" {
+		mediaType = "text/plain"
+	}
+	media, ok := resp.Content[mediaType]
+	if !ok {
+		// Sniffed plain text error messages are fine.
+		if _, text := resp.Content["text/plain"]; text && strings.HasPrefix(http.DetectContentType(rec.body.Bytes()), "text/plain") {
+			return nil
+		}
+		return []Detail{{Location: "response", Field: "Content-Type", Message: "undocumented media type " + mediaType}}
+	}
+	if mediaType != "application/json" || media.Schema == nil {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(rec.body.Bytes(), &value); err != nil {
+		return []Detail{{Location: "response", Message: "invalid JSON: " + err.Error()}}
+	}
+
+	return media.Schema.Validate("response", "", value)
+}
+
+func writeValidationError(w http.ResponseWriter, status int, message string, details []Detail) {
+	data, err := json.Marshal(ValidationError{Error: message, Details: details})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// responseRecorder holds a response back until it has been validated.
+type responseRecorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = status
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.body.Write(p)
+}
+
+func (rec *responseRecorder) replay(w http.ResponseWriter) {
+	for key, values := range rec.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(rec.status)
+	w.Write(rec.body.Bytes())
+}
+
+// ServeSpec serves the OpenAPI document.
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(specJSON)
+}
+
+// ServeDocs serves a self-contained page that renders the OpenAPI document.
+func ServeDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(docsHTML)
+}
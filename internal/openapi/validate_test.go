@@ -0,0 +1,149 @@
+package openapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestValidator(t *testing.T, validateResponses bool) *Validator {
+	t.Helper()
+
+	v, err := NewValidator(validateResponses)
+	if err != nil {
+		t.Fatalf("Failed to load the spec: %v", err)
+	}
+	return v
+}
+
+func decodeValidationError(t *testing.T, rec *httptest.ResponseRecorder) ValidationError {
+	t.Helper()
+
+	body := ValidationError{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected a JSON validation error, got %q", rec.Body.String())
+	}
+	return body
+}
+
+func TestValidRequestPassesThrough(t *testing.T) {
+	v := newTestValidator(t, false)
+	called := false
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chirps", strings.NewReader(`{"body":"hello"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !called || rec.Code != http.StatusCreated {
+		t.Fatalf("Expected the handler to run, got status %d", rec.Code)
+	}
+}
+
+func TestInvalidBodyIsRejected(t *testing.T) {
+	v := newTestValidator(t, false)
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("Handler should not run for an invalid request")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chirps", strings.NewReader(`{"body":"`+strings.Repeat("x", 141)+`"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", rec.Code)
+	}
+	body := decodeValidationError(t, rec)
+	if len(body.Details) != 1 || body.Details[0].Field != "body" || body.Details[0].Location != "body" {
+		t.Fatalf("Unexpected details: %+v", body.Details)
+	}
+}
+
+func TestImageUploadsAreNotRead(t *testing.T) {
+	v := newTestValidator(t, false)
+	upload := strings.Repeat("x", maxValidatedBody+1)
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if len(data) != len(upload) {
+			t.Errorf("Expected the whole upload to reach the handler, got %d bytes", len(data))
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPut, "/api/users/me/avatar", strings.NewReader(upload))
+	req.Header.Set("Content-Type", "image/png")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the upload to pass through, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/users/me/avatar", strings.NewReader("GIF89a"))
+	req.Header.Set("Content-Type", "image/gif")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for an undocumented image type, got %d", rec.Code)
+	}
+}
+
+func TestInvalidParametersAreRejected(t *testing.T) {
+	v := newTestValidator(t, false)
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("Handler should not run for an invalid request")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/chirps/not-a-uuid?sort=sideways", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", rec.Code)
+	}
+	if details := decodeValidationError(t, rec).Details; len(details) != 1 || details[0].Field != "id" {
+		t.Fatalf("Unexpected details: %+v", details)
+	}
+}
+
+func TestUnknownPathsPassThrough(t *testing.T) {
+	v := newTestValidator(t, true)
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("static"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/app/index.html", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "static" {
+		t.Fatalf("Expected the file server response, got %q", rec.Body.String())
+	}
+}
+
+func TestResponseValidation(t *testing.T) {
+	v := newTestValidator(t, true)
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"token":"abc","extra":true}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/refresh", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500 for a response that breaks the spec, got %d", rec.Code)
+	}
+	if details := decodeValidationError(t, rec).Details; len(details) != 1 || details[0].Field != "extra" {
+		t.Fatalf("Unexpected details: %+v", details)
+	}
+}
@@ -0,0 +1,145 @@
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// bloomMagic starts every Bloom filter file.
+const bloomMagic = "CHIRPYBF"
+
+var ErrBadBloom = errors.New("passwords: not a breached password filter")
+
+// Bloom is a Bloom filter over the SHA-1 hashes of passwords, the form
+// breach corpora such as Have I Been Pwned publish them in. It may say a
+// password is breached when it is not, at the rate it was built for, but
+// never the reverse. At a 0.1% rate it takes under two bytes a password.
+type Bloom struct {
+	bits []uint64
+	k    uint32
+}
+
+// NewBloom sizes a filter for n passwords with false positive rate fp.
+func NewBloom(n int, fp float64) *Bloom {
+	n = max(n, 1)
+	m := math.Ceil(-float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2))
+	k := max(1, uint32(math.Round(m/float64(n)*math.Ln2)))
+	return &Bloom{bits: make([]uint64, (uint64(m)+63)/64), k: k}
+}
+
+// Add adds a password.
+func (b *Bloom) Add(password string) {
+	sum := sha1.Sum([]byte(password))
+	b.addHash(sum)
+}
+
+// Contains reports whether password is probably in the filter.
+func (b *Bloom) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	m := uint64(len(b.bits)) * 64
+	h1, h2 := split(sum)
+	for i := uint32(0); i < b.k; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Bloom) addHash(sum [sha1.Size]byte) {
+	m := uint64(len(b.bits)) * 64
+	h1, h2 := split(sum)
+	for i := uint32(0); i < b.k; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// split derives the two hashes double hashing needs from a SHA-1 sum.
+func split(sum [sha1.Size]byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(sum[0:8]), binary.BigEndian.Uint64(sum[8:16]) | 1
+}
+
+// WriteTo writes the filter in the format ReadBloom reads: the magic, k and
+// the number of 64-bit words, then the words, all big-endian.
+func (b *Bloom) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := make([]byte, len(bloomMagic)+12)
+	copy(header, bloomMagic)
+	binary.BigEndian.PutUint32(header[len(bloomMagic):], b.k)
+	binary.BigEndian.PutUint64(header[len(bloomMagic)+4:], uint64(len(b.bits)))
+	if _, err := bw.Write(header); err != nil {
+		return 0, err
+	}
+
+	word := make([]byte, 8)
+	for _, bits := range b.bits {
+		binary.BigEndian.PutUint64(word, bits)
+		if _, err := bw.Write(word); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(header) + 8*len(b.bits)), bw.Flush()
+}
+
+// ReadBloom reads a filter written by WriteTo.
+func ReadBloom(r io.Reader) (*Bloom, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(bloomMagic)+12)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(bloomMagic)]) != bloomMagic {
+		return nil, ErrBadBloom
+	}
+	k := binary.BigEndian.Uint32(header[len(bloomMagic):])
+	words := binary.BigEndian.Uint64(header[len(bloomMagic)+4:])
+	if k == 0 || words == 0 || words > 1<<34 {
+		return nil, ErrBadBloom
+	}
+
+	b := &Bloom{bits: make([]uint64, words), k: k}
+	word := make([]byte, 8)
+	for i := range b.bits {
+		if _, err := io.ReadFull(br, word); err != nil {
+			return nil, ErrBadBloom
+		}
+		b.bits[i] = binary.BigEndian.Uint64(word)
+	}
+	return b, nil
+}
+
+// LoadBloom reads the filter file at path.
+func LoadBloom(path string) (*Bloom, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBloom(f)
+}
+
+// AddList adds one password per line of r. Lines that are a hex SHA-1,
+// optionally followed by ":count" as in Have I Been Pwned's downloads, are
+// added as that hash; any other line is taken as a plain password.
+func (b *Bloom) AddList(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		if sum, err := hex.DecodeString(hash); err == nil && len(sum) == sha1.Size {
+			b.addHash([sha1.Size]byte(sum))
+			continue
+		}
+		b.Add(line)
+	}
+	return scanner.Err()
+}
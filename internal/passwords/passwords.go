@@ -0,0 +1,73 @@
+// Package passwords decides whether a new password is good enough: long
+// enough, hard enough to guess, not made from the account's email address
+// or handle, and not known from a data breach.
+package passwords
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrTooShort = errors.New("Password is too short")
+	ErrTooLong  = errors.New("Password is too long")
+	ErrPersonal = errors.New("Password must not contain your email address or handle")
+	ErrTooWeak  = errors.New("Password is too easy to guess")
+	ErrBreached = errors.New("Password has appeared in a data breach; choose another")
+)
+
+// Policy is what new passwords must satisfy.
+type Policy struct {
+	// MinLength and MaxLength count characters, not bytes.
+	MinLength int
+	MaxLength int
+	// MinScore is the lowest Estimate score accepted, from 0 to 4.
+	MinScore int
+	// Breached holds known breached passwords; nil skips the check.
+	Breached *Bloom
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		MinLength: 8,
+		MaxLength: 128,
+		MinScore:  2,
+	}
+}
+
+// Check returns why password breaks the policy, or nil. userInputs are the
+// account's email address, handle and the like, which the password must not
+// be built from.
+func (p Policy) Check(password string, userInputs ...string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("%w: use at least %d characters", ErrTooShort, p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("%w: use at most %d characters", ErrTooLong, p.MaxLength)
+	}
+
+	lower := strings.ToLower(password)
+	for _, input := range userInputs {
+		for _, token := range personalTokens(input) {
+			if utf8.RuneCountInString(token) >= 4 && strings.Contains(lower, token) {
+				return ErrPersonal
+			}
+		}
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		return ErrBreached
+	}
+
+	strength := Estimate(password, userInputs...)
+	if strength.Score < p.MinScore {
+		if strength.Warning == "" {
+			return fmt.Errorf("%w: make it longer", ErrTooWeak)
+		}
+		return fmt.Errorf("%w: %s", ErrTooWeak, strength.Warning)
+	}
+	return nil
+}
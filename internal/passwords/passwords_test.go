@@ -0,0 +1,140 @@
+package passwords
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEstimateRatesWeakPasswordsLow(t *testing.T) {
+	cases := []struct {
+		password string
+		warning  string
+	}{
+		{"password", warnings[patternCommon]},
+		{"P@ssw0rd!", warnings[patternCommon]},
+		{"drowssap", warnings[patternCommon]},
+		{"abcdefgh", warnings[patternSequence]},
+		{"98765432", warnings[patternSequence]},
+		{"aaaaaaaaaa", warnings[patternRepeat]},
+		{"asdfghjkl;", warnings[patternCommon]},
+		{"zxcvbnm,./", warnings[patternKeyboard]},
+	}
+	for _, c := range cases {
+		s := Estimate(c.password)
+		if s.Score > 1 {
+			t.Errorf("Expected %q to score at most 1, got %d", c.password, s.Score)
+		}
+		if s.Warning != c.warning {
+			t.Errorf("Expected %q to warn %q, got %q", c.password, c.warning, s.Warning)
+		}
+	}
+}
+
+func TestEstimateRatesStrongPasswordsHigh(t *testing.T) {
+	for _, password := range []string{"correcthorsebatterystaple", "x7#Kp9!qLm", "Tr0ub4dor&3"} {
+		if s := Estimate(password); s.Score < 3 {
+			t.Errorf("Expected %q to score at least 3, got %d (%s)", password, s.Score, s.Warning)
+		}
+	}
+}
+
+func TestEstimateUsesUserInputs(t *testing.T) {
+	without := Estimate("bartholomew1987")
+	with := Estimate("bartholomew1987", "bartholomew@example.com")
+	if with.Guesses >= without.Guesses {
+		t.Fatalf("Expected the email address to make the password weaker: %.1f vs %.1f", with.Guesses, without.Guesses)
+	}
+	if with.Warning != warnings[patternPersonal] {
+		t.Errorf("Expected the personal warning, got %q", with.Warning)
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	policy := DefaultPolicy()
+	cases := []struct {
+		password string
+		want     error
+	}{
+		{"", ErrTooShort},
+		{"x7#Kp9!", ErrTooShort},
+		{strings.Repeat("x7#Kp9!q", 20), ErrTooLong},
+		{"Dave.Bates!xyz", ErrPersonal},
+		{"chirper_dave77", ErrPersonal},
+		{"password123", ErrTooWeak},
+		{"x7#Kp9!qLm", nil},
+	}
+	for _, c := range cases {
+		err := policy.Check(c.password, "dave.bates@example.com", "chirper_dave")
+		if c.want == nil && err != nil {
+			t.Errorf("Expected %q to pass, got %v", c.password, err)
+		}
+		if c.want != nil && !errors.Is(err, c.want) {
+			t.Errorf("Expected %q to fail with %v, got %v", c.password, c.want, err)
+		}
+	}
+}
+
+func TestPolicyRejectsBreachedPasswords(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.Breached = NewBloom(10, 0.001)
+	policy.Breached.Add("x7#Kp9!qLm")
+
+	if err := policy.Check("x7#Kp9!qLm"); !errors.Is(err, ErrBreached) {
+		t.Fatalf("Expected ErrBreached, got %v", err)
+	}
+	if err := policy.Check("Vq8$mT2!zR"); err != nil {
+		t.Fatalf("Expected an unbreached password to pass, got %v", err)
+	}
+}
+
+func TestBloomRoundTrip(t *testing.T) {
+	sum := sha1.Sum([]byte("hunter2"))
+	list := strings.Join([]string{
+		"plain-password",
+		strings.ToUpper(hex.EncodeToString(sum[:])) + ":2034",
+		"",
+	}, "\n")
+
+	b := NewBloom(1000, 0.001)
+	if err := b.AddList(strings.NewReader(list)); err != nil {
+		t.Fatalf("AddList failed: %v", err)
+	}
+	for i := 0; i < 500; i++ {
+		b.Add(fmt.Sprintf("filler-%d", i))
+	}
+
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	loaded, err := ReadBloom(&buf)
+	if err != nil {
+		t.Fatalf("ReadBloom failed: %v", err)
+	}
+
+	for _, password := range []string{"plain-password", "hunter2", "filler-0", "filler-499"} {
+		if !loaded.Contains(password) {
+			t.Errorf("Expected %q to be in the filter", password)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if loaded.Contains(fmt.Sprintf("absent-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 50 {
+		t.Errorf("Expected about 0.1%% false positives, got %d in 10000", falsePositives)
+	}
+}
+
+func TestReadBloomRejectsOtherFiles(t *testing.T) {
+	if _, err := ReadBloom(strings.NewReader("123456\npassword\n")); !errors.Is(err, ErrBadBloom) {
+		t.Fatalf("Expected ErrBadBloom, got %v", err)
+	}
+}
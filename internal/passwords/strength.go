@@ -0,0 +1,361 @@
+package passwords
+
+import (
+	_ "embed"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//go:embed common.txt
+var commonList string
+
+// common ranks the most used passwords and words; the lower the rank, the
+// sooner an attacker tries it.
+var common = rankWords(strings.Fields(commonList))
+
+func rankWords(words []string) map[string]int {
+	ranks := make(map[string]int, len(words))
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}
+
+// keyboardRows are walked by passwords like "qwerty" and "asdf".
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+// leet maps the substitutions people make for letters back to the letters.
+var leet = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i',
+	'!': 'i', '|': 'l', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+// Pattern types, which decide the warning given for a weak password.
+const (
+	patternBruteforce = iota
+	patternCommon
+	patternPersonal
+	patternSequence
+	patternRepeat
+	patternKeyboard
+	patternYear
+)
+
+var warnings = map[int]string{
+	patternCommon:   "it is built on a common password or word",
+	patternPersonal: "it is built on your email address or handle",
+	patternSequence: "sequences like abc or 6543 are easy to guess",
+	patternRepeat:   "repeated characters like aaa are easy to guess",
+	patternKeyboard: "straight rows of keys are easy to guess",
+	patternYear:     "years are easy to guess",
+}
+
+// Strength estimates how many guesses an attacker needs for a password, in
+// the manner of zxcvbn: the password is split into the cheapest run of
+// known patterns (common words, sequences, repeats, keyboard rows, years)
+// and whatever is left is guessed character by character.
+type Strength struct {
+	// Guesses is log10 of the number of guesses needed.
+	Guesses float64
+	// Score goes from 0, guessed in under a thousand tries, to 4, which
+	// takes more than ten billion.
+	Score int
+	// Warning says what makes the password weak, if anything stands out.
+	Warning string
+}
+
+// match is a pattern found at password[start:end] and how many guesses
+// it takes, as log10.
+type match struct {
+	start, end int
+	guesses    float64
+	pattern    int
+}
+
+// minMatchGuesses is the least a multi-character pattern can cost, so the
+// estimate is not fooled by splitting a password into many tiny matches.
+var minMatchGuesses = math.Log10(10)
+
+// bruteforceGuesses is what each character left over costs.
+var bruteforceGuesses = math.Log10(10)
+
+// Estimate rates password. userInputs, such as the user's email address and
+// handle, are treated as the first words an attacker would try.
+func Estimate(password string, userInputs ...string) Strength {
+	runes := []rune(password)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	personal := map[string]int{}
+	for i, input := range userInputs {
+		for _, token := range personalTokens(input) {
+			if _, ok := personal[token]; !ok {
+				personal[token] = i + 1
+			}
+		}
+	}
+
+	var matches []match
+	matches = append(matches, dictionaryMatches(runes, lower, common, patternCommon)...)
+	matches = append(matches, dictionaryMatches(runes, lower, personal, patternPersonal)...)
+	matches = append(matches, sequenceMatches(lower)...)
+	matches = append(matches, repeatMatches(lower)...)
+	matches = append(matches, keyboardMatches(lower)...)
+	matches = append(matches, yearMatches(lower)...)
+
+	// best[i] is the fewest guesses for the first i characters, and how.
+	n := len(runes)
+	best := make([]float64, n+1)
+	via := make([]*match, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = best[i-1] + bruteforceGuesses
+		for j := range matches {
+			m := &matches[j]
+			if m.end != i {
+				continue
+			}
+			if cost := best[m.start] + math.Max(m.guesses, minMatchGuesses); cost < best[i] {
+				best[i] = cost
+				via[i] = m
+			}
+		}
+	}
+
+	// Walk back along the cheapest split to find the pattern covering the
+	// most characters.
+	covered := map[int]int{}
+	for i := n; i > 0; {
+		if m := via[i]; m != nil {
+			covered[m.pattern] += m.end - m.start
+			i = m.start
+		} else {
+			i--
+		}
+	}
+	worst, most := patternBruteforce, 0
+	for pattern, count := range covered {
+		if count > most || (count == most && pattern < worst) {
+			worst, most = pattern, count
+		}
+	}
+
+	strength := Strength{Guesses: best[n], Score: score(best[n])}
+	if strength.Score < 4 {
+		strength.Warning = warnings[worst]
+	}
+	return strength
+}
+
+func score(guesses float64) int {
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	}
+	return 4
+}
+
+// personalTokens splits a user input into the words it is made of, so
+// "dave.bates@example.com" yields "dave.bates", "dave" and "bates". The
+// domain of an email address is left out.
+func personalTokens(input string) []string {
+	input = strings.ToLower(strings.TrimSpace(input))
+	if at := strings.LastIndex(input, "@"); at >= 0 {
+		input = input[:at]
+	}
+	if input == "" {
+		return nil
+	}
+
+	tokens := []string{input}
+	for _, part := range strings.FieldsFunc(input, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if part != input {
+			tokens = append(tokens, part)
+		}
+	}
+	return tokens
+}
+
+// dictionaryMatches finds the words of ranks in password, also reversed or
+// spelled with substitutions like "p@ssw0rd".
+func dictionaryMatches(runes []rune, lower []rune, ranks map[string]int, pattern int) []match {
+	if len(ranks) == 0 {
+		return nil
+	}
+
+	unleeted := make([]rune, len(lower))
+	for i, r := range lower {
+		if plain, ok := leet[r]; ok {
+			unleeted[i] = plain
+		} else {
+			unleeted[i] = r
+		}
+	}
+
+	var matches []match
+	for i := 0; i < len(lower); i++ {
+		for j := i + 3; j <= len(lower); j++ {
+			word := string(lower[i:j])
+			rank, ok := ranks[word]
+			extra := 1.0
+			if !ok {
+				rank, ok = ranks[reverse(word)]
+				extra = 2
+			}
+			if !ok {
+				rank, ok = ranks[string(unleeted[i:j])]
+				extra = 2
+			}
+			if !ok {
+				continue
+			}
+			guesses := math.Log10(float64(rank) * extra * caseVariations(runes[i:j]))
+			matches = append(matches, match{start: i, end: j, guesses: guesses, pattern: pattern})
+		}
+	}
+	return matches
+}
+
+// caseVariations is how many ways of capitalising word an attacker tries
+// before reaching the one used.
+func caseVariations(word []rune) float64 {
+	upper, letters := 0, 0
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	switch {
+	case upper == 0:
+		return 1
+	case upper == letters, upper == 1 && unicode.IsUpper(word[0]):
+		return 2
+	}
+	return math.Pow(2, float64(min(upper, letters-upper)+1))
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// sequenceMatches finds runs like "abcd", "9876" or "aceg".
+func sequenceMatches(lower []rune) []match {
+	var matches []match
+	for i := 0; i+2 < len(lower); {
+		delta := lower[i+1] - lower[i]
+		if delta == 0 || delta < -2 || delta > 2 || !sameClass(lower[i], lower[i+1]) {
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j+1 < len(lower) && lower[j+1]-lower[j] == delta && sameClass(lower[j], lower[j+1]) {
+			j++
+		}
+		if j-i < 2 {
+			i++
+			continue
+		}
+
+		base := 26.0
+		if unicode.IsDigit(lower[i]) {
+			base = 10
+		}
+		if strings.ContainsRune("az09", lower[i]) {
+			base = 4
+		}
+		if delta < 0 {
+			base *= 2
+		}
+		matches = append(matches, match{start: i, end: j + 1, guesses: math.Log10(base * float64(j-i+1)), pattern: patternSequence})
+		i = j
+	}
+	return matches
+}
+
+func sameClass(a rune, b rune) bool {
+	return (unicode.IsDigit(a) && unicode.IsDigit(b)) || (unicode.IsLetter(a) && unicode.IsLetter(b))
+}
+
+// repeatMatches finds runs of one character, like "aaaa".
+func repeatMatches(lower []rune) []match {
+	var matches []match
+	for i := 0; i < len(lower); {
+		j := i + 1
+		for j < len(lower) && lower[j] == lower[i] {
+			j++
+		}
+		if j-i >= 3 {
+			matches = append(matches, match{start: i, end: j, guesses: math.Log10(cardinality(lower[i]) * float64(j-i)), pattern: patternRepeat})
+		}
+		i = j
+	}
+	return matches
+}
+
+func cardinality(r rune) float64 {
+	switch {
+	case unicode.IsDigit(r):
+		return 10
+	case unicode.IsLetter(r):
+		return 26
+	}
+	return 33
+}
+
+// keyboardMatches finds walks along a row of keys, like "asdfgh" or "poiu".
+func keyboardMatches(lower []rune) []match {
+	var matches []match
+	for i := 0; i < len(lower); i++ {
+		for j := len(lower); j >= i+4; j-- {
+			walk := string(lower[i:j])
+			if onKeyboardRow(walk) || onKeyboardRow(reverse(walk)) {
+				matches = append(matches, match{start: i, end: j, guesses: math.Log10(50 * float64(j-i)), pattern: patternKeyboard})
+				break
+			}
+		}
+	}
+	return matches
+}
+
+func onKeyboardRow(walk string) bool {
+	for _, row := range keyboardRows {
+		if strings.Contains(row, walk) {
+			return true
+		}
+	}
+	return false
+}
+
+// yearMatches finds years from 1900 to 2099.
+func yearMatches(lower []rune) []match {
+	var matches []match
+	for i := 0; i+4 <= len(lower); i++ {
+		year, err := strconv.Atoi(string(lower[i : i+4]))
+		if err == nil && year >= 1900 && year <= 2099 {
+			matches = append(matches, match{start: i, end: i + 4, guesses: math.Log10(200), pattern: patternYear})
+		}
+	}
+	return matches
+}
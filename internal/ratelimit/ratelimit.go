@@ -0,0 +1,136 @@
+// Package ratelimit implements token bucket rate limits keyed by route and
+// caller, with separate limits for anonymous, signed in and Chirpy Red users.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Limit is a token bucket: it holds up to Burst requests and refills at Rate
+// requests per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute allows n requests a minute, all of which may come at once.
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+// window is how long an empty bucket takes to refill.
+func (l Limit) window() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// Tier is the kind of caller a request comes from.
+type Tier int
+
+const (
+	Anonymous Tier = iota
+	User
+	Red
+)
+
+// Rule holds the limit of each tier for one route.
+type Rule struct {
+	Anonymous Limit
+	User      Limit
+	Red       Limit
+}
+
+func (r Rule) limit(tier Tier) Limit {
+	switch tier {
+	case Red:
+		return r.Red
+	case User:
+		return r.User
+	}
+	return r.Anonymous
+}
+
+// Result is the state of a bucket after a request has been counted.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request will be allowed. It is
+	// zero when Allowed is set.
+	RetryAfter time.Duration
+}
+
+// newResult describes a bucket left with tokens after a request.
+func newResult(tokens float64, allowed bool, limit Limit) Result {
+	res := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(limit.Burst) - tokens) / limit.Rate * float64(time.Second)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+	}
+	return res
+}
+
+// Store keeps the buckets. Take counts one request against key.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Limiter applies per-route rules, falling back to a default rule for
+// routes without one of their own.
+type Limiter struct {
+	store    Store
+	fallback Rule
+	rules    map[string]Rule
+}
+
+func New(store Store, fallback Rule) *Limiter {
+	return &Limiter{
+		store:    store,
+		fallback: fallback,
+		rules:    map[string]Rule{},
+	}
+}
+
+// SetRule sets the rule for route, a ServeMux pattern such as
+// "POST /api/login". Call it before serving requests.
+func (l *Limiter) SetRule(route string, rule Rule) {
+	l.rules[route] = rule
+}
+
+// Allow counts a request by identity to route. Every route and identity
+// pair has its own bucket.
+func (l *Limiter) Allow(ctx context.Context, route string, identity string, tier Tier) (Limit, Result, error) {
+	rule, ok := l.rules[route]
+	if !ok {
+		rule = l.fallback
+	}
+	limit := rule.limit(tier)
+
+	res, err := l.store.Take(ctx, route+"|"+identity, limit)
+	return limit, res, err
+}
+
+// WriteHeaders sets the RateLimit-* headers, and Retry-After when the
+// request was refused.
+func WriteHeaders(w http.ResponseWriter, limit Limit, res Result) {
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+	h.Set("RateLimit-Policy", strconv.Itoa(limit.Burst)+";w="+strconv.Itoa(seconds(limit.window())))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+	}
+}
+
+// seconds rounds d up to whole seconds, as the headers want.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(now *time.Time) *Limiter {
+	store := NewMemoryStore()
+	store.now = func() time.Time { return *now }
+
+	limiter := New(store, Rule{
+		Anonymous: PerMinute(2),
+		User:      PerMinute(4),
+		Red:       PerMinute(8),
+	})
+	limiter.SetRule("POST /api/login", Rule{
+		Anonymous: PerMinute(1),
+		User:      PerMinute(1),
+		Red:       PerMinute(1),
+	})
+	return limiter
+}
+
+func TestBucketEmptiesAndRefills(t *testing.T) {
+	now := time.Now()
+	limiter := newTestLimiter(&now)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, res, _ := limiter.Allow(ctx, "GET /api/chirps", "ip:1", Anonymous); !res.Allowed {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+	}
+
+	_, res, _ := limiter.Allow(ctx, "GET /api/chirps", "ip:1", Anonymous)
+	if res.Allowed {
+		t.Fatal("Expected the third request to be refused")
+	}
+	if res.RetryAfter != 30*time.Second {
+		t.Fatalf("Expected to retry after 30s, got %v", res.RetryAfter)
+	}
+
+	now = now.Add(30 * time.Second)
+	if _, res, _ := limiter.Allow(ctx, "GET /api/chirps", "ip:1", Anonymous); !res.Allowed {
+		t.Fatal("Expected a request to be allowed once a token has refilled")
+	}
+}
+
+func TestBucketsAreSeparate(t *testing.T) {
+	now := time.Now()
+	limiter := newTestLimiter(&now)
+	ctx := context.Background()
+
+	limiter.Allow(ctx, "POST /api/login", "ip:1", Anonymous)
+	if _, res, _ := limiter.Allow(ctx, "POST /api/login", "ip:1", Anonymous); res.Allowed {
+		t.Fatal("Expected the route rule to apply")
+	}
+	if _, res, _ := limiter.Allow(ctx, "POST /api/login", "ip:2", Anonymous); !res.Allowed {
+		t.Fatal("Expected another caller to have its own bucket")
+	}
+	if _, res, _ := limiter.Allow(ctx, "GET /api/chirps", "ip:1", Anonymous); !res.Allowed {
+		t.Fatal("Expected another route to have its own bucket")
+	}
+}
+
+func TestTiers(t *testing.T) {
+	now := time.Now()
+	limiter := newTestLimiter(&now)
+
+	for tier, burst := range map[Tier]int{Anonymous: 2, User: 4, Red: 8} {
+		limit, res, _ := limiter.Allow(context.Background(), "GET /api/chirps", fmt.Sprintf("user:%d", tier), tier)
+		if limit.Burst != burst || res.Remaining != burst-1 {
+			t.Fatalf("Tier %d: expected a burst of %d, got %d with %d remaining", tier, burst, limit.Burst, res.Remaining)
+		}
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteHeaders(rec, PerMinute(60), Result{Allowed: false, Remaining: 0, Reset: 59500 * time.Millisecond, RetryAfter: 500 * time.Millisecond})
+
+	expected := map[string]string{
+		"RateLimit-Limit":     "60",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "60",
+		"RateLimit-Policy":    "60;w=60",
+		"Retry-After":         "1",
+	}
+	for name, value := range expected {
+		if got := rec.Header().Get(name); got != value {
+			t.Errorf("Expected %s: %s, got %q", name, value, got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/dabates/httpServer/internal/database"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryStore keeps buckets in process. Each instance of the server counts
+// on its own, so use PostgresStore when running several.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	return newResult(b.tokens, allowed, limit), nil
+}
+
+// sweep drops buckets nobody has used for an hour, which are full again for
+// any limit with a window shorter than that.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) > time.Hour {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// PostgresStore keeps buckets in the rate_limits table, so that every
+// instance of the server shares them.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	row, err := s.db.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		Key:   key,
+		Burst: float64(limit.Burst),
+		Rate:  limit.Rate,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return newResult(row.Tokens, row.Allowed, limit), nil
+}
+
+// Purge deletes buckets idle for an hour every interval until the process
+// exits.
+func (s *PostgresStore) Purge(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := s.db.DeleteIdleRateLimits(context.Background()); err != nil {
+			log.Printf("ratelimit: purging idle buckets: %v", err)
+		}
+	}
+}
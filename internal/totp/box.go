@@ -0,0 +1,55 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+var (
+	ErrBadKey = errors.New("totp: key must be 32 bytes")
+	ErrSealed = errors.New("totp: sealed secret is corrupt or was sealed with another key")
+)
+
+// Box seals secrets with AES-256-GCM so they are stored encrypted, and a
+// leaked table does not hand out every user's second factor.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// NewBox returns a Box sealing with key, which must be 32 random bytes.
+func NewBox(key []byte) (*Box, error) {
+	if len(key) != 32 {
+		return nil, ErrBadKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts secret, returning a random nonce followed by the ciphertext.
+func (b *Box) Seal(secret []byte) []byte {
+	nonce := make([]byte, b.aead.NonceSize(), b.aead.NonceSize()+len(secret)+b.aead.Overhead())
+	rand.Read(nonce)
+	return b.aead.Seal(nonce, nonce, secret, nil)
+}
+
+// Open decrypts what Seal returned.
+func (b *Box) Open(sealed []byte) ([]byte, error) {
+	if len(sealed) < b.aead.NonceSize() {
+		return nil, ErrSealed
+	}
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	secret, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrSealed
+	}
+	return secret, nil
+}
@@ -0,0 +1,27 @@
+package totp
+
+import (
+	"bytes"
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"image/png"
+)
+
+// QRCode returns a size by size PNG of a QR code holding uri, for an
+// authenticator app to scan.
+func QRCode(uri string, size int) ([]byte, error) {
+	code, err := qr.Encode(uri, qr.M, qr.Auto)
+	if err != nil {
+		return nil, err
+	}
+	code, err = barcode.Scale(code, size, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
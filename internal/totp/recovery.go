@@ -0,0 +1,40 @@
+package totp
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+)
+
+// RecoveryCodeCount is how many recovery codes a user is given at a time.
+const RecoveryCodeCount = 10
+
+var recoveryEncoding = base32.NewEncoding("abcdefghijkmnpqrstuvwxyz23456789").WithPadding(base32.NoPadding)
+
+// NewRecoveryCodes returns n random recovery codes of the form
+// "xxxxx-xxxxx". Each carries 50 bits, from an alphabet without the
+// letters and digits that are easy to mix up.
+func NewRecoveryCodes(n int) []string {
+	codes := make([]string, n)
+	// Seven bytes encode to twelve characters, of which ten are kept.
+	raw := make([]byte, 7)
+	for i := range codes {
+		rand.Read(raw)
+		code := recoveryEncoding.EncodeToString(raw)
+		codes[i] = code[:5] + "-" + code[5:10]
+	}
+	return codes
+}
+
+// NormalizeRecoveryCode returns code as NewRecoveryCodes made it, without
+// the dash, so it still matches when typed in another case or without the
+// dash. Hash this form to store and look up codes.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
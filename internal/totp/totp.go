@@ -0,0 +1,113 @@
+// Package totp implements the time-based one-time passwords of RFC 6238
+// that authenticator apps generate, along with what two-factor login needs
+// around them: sealing secrets for storage, single-use recovery codes and a
+// QR code to scan a secret into an app.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Digits is the length of a code.
+	Digits = 6
+	// Period is how long each code is valid.
+	Period = 30 * time.Second
+	// Skew is how many periods either side of now are accepted, to allow
+	// for clocks that are a little off.
+	Skew = 1
+	// SecretSize is the length of secrets in bytes, as RFC 4226 recommends.
+	SecretSize = 20
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random secret.
+func NewSecret() []byte {
+	secret := make([]byte, SecretSize)
+	rand.Read(secret)
+	return secret
+}
+
+// EncodeSecret returns secret in the base32 form apps accept when typed in.
+func EncodeSecret(secret []byte) string {
+	return secretEncoding.EncodeToString(secret)
+}
+
+// URI returns the otpauth:// URI that authenticator apps read from a QR
+// code, labelling the secret with issuer and account.
+func URI(issuer string, account string, secret []byte) string {
+	params := url.Values{}
+	params.Set("secret", EncodeSecret(secret))
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(Digits))
+	params.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Step returns the period t falls in.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// Code returns the code for secret during step.
+func Code(secret []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+	h := hmac.New(sha1.New, secret)
+	h.Write(counter)
+	sum := h.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
+
+// Validate reports whether code is right for secret at t, and the step it
+// was made for. Callers should refuse steps at or before the last one
+// accepted, so that a code cannot be used twice.
+func Validate(secret []byte, code string, t time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	now := Step(t)
+	for step := now - Skew; step <= now+Skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(Code(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// LooksLikeCode reports whether s has the form of a code rather than a
+// recovery code.
+func LooksLikeCode(s string) bool {
+	s = strings.TrimSpace(s)
+	if len(s) != Digits {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package totp
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 secret of RFC 6238's test vectors.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	// The RFC's vectors are eight digits; six-digit codes are their last six.
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, c := range cases {
+		if got := Code(rfcSecret, Step(time.Unix(c.unix, 0))); got != c.want {
+			t.Errorf("Code at %d = %s, want %s", c.unix, got, c.want)
+		}
+	}
+}
+
+func TestValidateAllowsSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := Step(now)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		got, ok := Validate(rfcSecret, Code(rfcSecret, step+offset), now)
+		if !ok || got != step+offset {
+			t.Errorf("Expected the code for step %+d to be accepted as %d, got %d, %v", offset, step+offset, got, ok)
+		}
+	}
+	for _, offset := range []int64{-2, 2} {
+		if _, ok := Validate(rfcSecret, Code(rfcSecret, step+offset), now); ok {
+			t.Errorf("Expected the code for step %+d to be refused", offset)
+		}
+	}
+	if _, ok := Validate(rfcSecret, "12345", now); ok {
+		t.Error("Expected a short code to be refused")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Chirpy", "dave@example.com", rfcSecret)
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("Expected a valid URI, got %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Chirpy:dave@example.com" {
+		t.Errorf("Unexpected URI %s", uri)
+	}
+	if got := u.Query().Get("secret"); got != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" {
+		t.Errorf("Expected the base32 secret without padding, got %s", got)
+	}
+	if u.Query().Get("issuer") != "Chirpy" {
+		t.Errorf("Expected the issuer parameter, got %s", uri)
+	}
+}
+
+func TestBoxRoundTrip(t *testing.T) {
+	box, err := NewBox(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewBox failed: %v", err)
+	}
+
+	sealed := box.Seal(rfcSecret)
+	if bytes.Contains(sealed, rfcSecret) {
+		t.Fatal("Expected the sealed secret not to contain the secret")
+	}
+	if again := box.Seal(rfcSecret); bytes.Equal(sealed, again) {
+		t.Error("Expected every seal to use a fresh nonce")
+	}
+	opened, err := box.Open(sealed)
+	if err != nil || !bytes.Equal(opened, rfcSecret) {
+		t.Fatalf("Expected to open the secret, got %q, %v", opened, err)
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err := box.Open(sealed); !errors.Is(err, ErrSealed) {
+		t.Errorf("Expected a tampered secret to fail with ErrSealed, got %v", err)
+	}
+
+	other, _ := NewBox(bytes.Repeat([]byte{8}, 32))
+	if _, err := other.Open(box.Seal(rfcSecret)); !errors.Is(err, ErrSealed) {
+		t.Errorf("Expected another key to fail with ErrSealed, got %v", err)
+	}
+	if _, err := NewBox([]byte("short")); !errors.Is(err, ErrBadKey) {
+		t.Errorf("Expected ErrBadKey, got %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes := NewRecoveryCodes(RecoveryCodeCount)
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("Expected %d codes, got %d", RecoveryCodeCount, len(codes))
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("Expected a code like xxxxx-xxxxx, got %q", code)
+		}
+		if seen[code] {
+			t.Errorf("Expected unique codes, got %q twice", code)
+		}
+		seen[code] = true
+		if LooksLikeCode(code) {
+			t.Errorf("Expected %q not to look like a TOTP code", code)
+		}
+	}
+
+	code := codes[0]
+	if NormalizeRecoveryCode(strings.ToUpper(code)) != NormalizeRecoveryCode(strings.Replace(code, "-", "", 1)) {
+		t.Error("Expected case and the dash not to matter")
+	}
+}
+
+func TestQRCodeIsPNG(t *testing.T) {
+	data, err := QRCode(URI("Chirpy", "dave@example.com", rfcSecret), 256)
+	if err != nil {
+		t.Fatalf("QRCode failed: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected a PNG, got %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 256 || b.Dy() != 256 {
+		t.Errorf("Expected 256x256, got %v", b)
+	}
+}
@@ -2,19 +2,49 @@ package types
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"github.com/dabates/httpServer/internal/abuse"
 	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/events"
+	"github.com/dabates/httpServer/internal/mailer"
+	"github.com/dabates/httpServer/internal/media"
+	"github.com/dabates/httpServer/internal/passwords"
+	"github.com/dabates/httpServer/internal/ratelimit"
+	"github.com/dabates/httpServer/internal/totp"
+	"github.com/dabates/httpServer/internal/webhooks"
 	"log"
 	"net/http"
+	"net/netip"
 	"sync/atomic"
 )
 
 type ApiConfig struct {
 	fileserverHits atomic.Int32
 	Platform       string
-	Db             *database.Queries
-	Secret         string
-	PolkaApiKey    string
+	// DB is the connection behind Db, for starting transactions.
+	DB          *sql.DB
+	Db          *database.Queries
+	Secret      string
+	PolkaApiKey string
+	AdminApiKey string
+	// Abuse holds the spam heuristics' thresholds; nil turns them off.
+	Abuse       *abuse.Thresholds
+	Events      *events.Bus
+	RateLimiter *ratelimit.Limiter
+	// Webhooks queues deliveries to users' webhooks; nil turns them off.
+	Webhooks *webhooks.Dispatcher
+	// Mailer sends account emails; nil sends none.
+	Mailer mailer.Mailer
+	// Media stores uploaded avatars and banners.
+	Media *media.Store
+	// Passwords is the policy new passwords must meet; nil uses the default.
+	Passwords *passwords.Policy
+	// TOTP seals users' two-factor secrets; nil turns two-factor setup off.
+	TOTP *totp.Box
+	// TrustedProxies are the load balancers and proxies whose
+	// X-Forwarded-For is believed when telling clients apart.
+	TrustedProxies []netip.Prefix
 }
 
 func (c *ApiConfig) MiddlewareMetricsInc(next http.Handler) http.Handler {
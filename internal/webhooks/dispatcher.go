@@ -0,0 +1,173 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/dabates/httpServer/internal/database"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+const (
+	// claimBatch is how many due deliveries one pass sends at most.
+	claimBatch = 50
+	// leaseMargin is added to the send timeout to lease a delivery. The
+	// lease has to outlast the attempt and recording it, or another
+	// instance sends the delivery again.
+	leaseMargin = time.Minute
+	// maxErrorLength caps the error kept in the delivery log.
+	maxErrorLength = 500
+)
+
+// payload is the JSON body of a delivery.
+type payload struct {
+	Id        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt string      `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatcher queues deliveries in Postgres and works through them, so
+// retries survive restarts and several instances can share the work.
+type Dispatcher struct {
+	db     *database.Queries
+	sender *Sender
+	policy Policy
+}
+
+func NewDispatcher(db *database.Queries, sender *Sender, policy Policy) *Dispatcher {
+	return &Dispatcher{db: db, sender: sender, policy: policy}
+}
+
+// Enqueue queues an event for every enabled webhook of userID subscribed to
+// eventType. data becomes the payload's data field. Pass the transaction
+// that makes the change the event is about as db, so the deliveries are
+// queued if and only if the change commits.
+func (d *Dispatcher) Enqueue(ctx context.Context, db *database.Queries, userID uuid.UUID, eventType string, data interface{}) error {
+	hooks, err := db.GetWebhooksForEvent(ctx, database.GetWebhooksForEventParams{
+		UserID:    userID,
+		EventType: eventType,
+	})
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+
+	eventID := uuid.New()
+	body, err := json.Marshal(payload{
+		Id:        eventID.String(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		_, err := db.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			WebhookID: hook.ID,
+			EventID:   eventID,
+			EventType: eventType,
+			Payload:   string(body),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run sends due deliveries every interval until the process exits.
+func (d *Dispatcher) Run(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := d.deliverDue(context.Background()); err != nil {
+			log.Printf("webhooks: delivering: %v", err)
+		}
+	}
+}
+
+// deliverDue sends up to claimBatch due deliveries. They are claimed one at
+// a time, each leased only for as long as one attempt can take, so slow
+// receivers cannot hold the rest of the queue past their lease. A delivery
+// that cannot be recorded is logged and left for its lease to run out.
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	lease := d.sender.client.Timeout + leaseMargin
+	for i := 0; i < claimBatch; i++ {
+		deliveries, err := d.db.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+			LeaseSeconds: int32(lease / time.Second),
+			BatchSize:    1,
+		})
+		if err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		if err := d.attempt(ctx, deliveries[0]); err != nil {
+			log.Printf("webhooks: delivery %s: %v", deliveries[0].ID, err)
+		}
+	}
+	return nil
+}
+
+// attempt sends a delivery once and records how it went.
+func (d *Dispatcher) attempt(ctx context.Context, delivery database.WebhookDelivery) error {
+	hook, err := d.db.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+
+	result := d.sender.Send(ctx, Request{
+		URL:        hook.Url,
+		Secret:     hook.Secret,
+		Event:      delivery.EventType,
+		DeliveryID: delivery.ID.String(),
+		Payload:    []byte(delivery.Payload),
+	})
+
+	code := sql.NullInt32{Int32: int32(result.StatusCode), Valid: result.StatusCode != 0}
+	if result.OK() {
+		err := d.db.RecordWebhookDeliverySuccess(ctx, database.RecordWebhookDeliverySuccessParams{
+			ID:           delivery.ID,
+			ResponseCode: code,
+		})
+		if err != nil {
+			return err
+		}
+		return d.db.RecordWebhookSuccess(ctx, hook.ID)
+	}
+
+	params := database.RecordWebhookDeliveryFailureParams{
+		ID:           delivery.ID,
+		ResponseCode: code,
+		Error:        sql.NullString{String: truncate(result.Err.Error(), maxErrorLength), Valid: true},
+	}
+	if wait, ok := d.policy.RetryIn(int(delivery.Attempts) + 1); ok {
+		params.RetryInSeconds = sql.NullInt32{Int32: int32(wait / time.Second), Valid: true}
+	}
+	if err := d.db.RecordWebhookDeliveryFailure(ctx, params); err != nil {
+		return err
+	}
+
+	hook, err = d.db.RecordWebhookFailure(ctx, database.RecordWebhookFailureParams{
+		ID:           hook.ID,
+		DisableAfter: int32(d.policy.DisableAfter),
+	})
+	if err != nil {
+		return err
+	}
+	if hook.DisabledAt.Valid && int(hook.FailureCount) == d.policy.DisableAfter {
+		log.Printf("webhooks: disabled %s after %d failed attempts", hook.ID, hook.FailureCount)
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,205 @@
+// Package webhooks delivers Chirpy events to the URLs users subscribe, signed
+// with HMAC-SHA256 and retried with exponential backoff.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Headers set on every delivery.
+const (
+	SignatureHeader = "Chirpy-Signature"
+	EventHeader     = "Chirpy-Event"
+	DeliveryHeader  = "Chirpy-Delivery"
+)
+
+var (
+	ErrPrivateAddress = errors.New("webhook URL resolves to a private address")
+	ErrBadSignature   = errors.New("webhook signature does not match")
+	ErrStaleSignature = errors.New("webhook signature is too old")
+)
+
+// NewSecret returns a random signing secret for a new webhook.
+func NewSecret() string {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return "whsec_" + hex.EncodeToString(key)
+}
+
+// Sign returns the Chirpy-Signature header for body sent at t:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + mac(secret, ts, body)
+}
+
+func mac(secret string, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Verify checks a Chirpy-Signature header the way receivers should: the
+// HMAC must match and the timestamp must be within tolerance of now, which
+// stops old deliveries being replayed.
+func Verify(secret string, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrBadSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, ts, body))) {
+		return ErrBadSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrStaleSignature
+	}
+	return nil
+}
+
+// Policy decides when failed deliveries are retried and when a webhook that
+// keeps failing is turned off.
+type Policy struct {
+	// Backoff is the wait after the first failed attempt; it doubles after
+	// every further one, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MaxAttempts is how many times a delivery is tried before it fails.
+	MaxAttempts int
+	// DisableAfter is how many failed attempts in a row, over all of a
+	// webhook's deliveries, disable it.
+	DisableAfter int
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		Backoff:      30 * time.Second,
+		MaxBackoff:   time.Hour,
+		MaxAttempts:  8,
+		DisableAfter: 20,
+	}
+}
+
+// RetryIn returns how long to wait after the attempts-th failed attempt, or
+// false if the delivery should not be tried again.
+func (p Policy) RetryIn(attempts int) (time.Duration, bool) {
+	if attempts >= p.MaxAttempts {
+		return 0, false
+	}
+
+	wait := p.Backoff
+	for i := 1; i < attempts && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, p.MaxBackoff), true
+}
+
+// Request is one attempt at a delivery.
+type Request struct {
+	URL        string
+	Secret     string
+	Event      string
+	DeliveryID string
+	Payload    []byte
+}
+
+// Result is how an attempt went. StatusCode is 0 if no response came back.
+type Result struct {
+	StatusCode int
+	Err        error
+}
+
+func (r Result) OK() bool {
+	return r.Err == nil && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Sender posts deliveries. Redirects are not followed, and unless the sender
+// allows private addresses, URLs resolving to loopback, private or link-local
+// addresses are refused so webhooks cannot reach internal services.
+type Sender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+
+	return &Sender{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: nil},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+func refusePrivate(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// Send makes one attempt at a delivery.
+func (s *Sender) Send(ctx context.Context, req Request) Result {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Payload))
+	if err != nil {
+		return Result{Err: err}
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	httpReq.Header.Set(EventHeader, req.Event)
+	httpReq.Header.Set(DeliveryHeader, req.DeliveryID)
+	httpReq.Header.Set(SignatureHeader, Sign(req.Secret, s.now(), req.Payload))
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return Result{Err: err}
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	result := Result{StatusCode: resp.StatusCode}
+	if !result.OK() {
+		result.Err = fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return result
+}
@@ -0,0 +1,102 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"chirp.created"}`)
+	header := Sign("secret", now, body)
+
+	if err := Verify("secret", header, body, now, time.Minute); err != nil {
+		t.Fatalf("Expected the signature to verify, got %v", err)
+	}
+	if err := Verify("other", header, body, now, time.Minute); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("Expected a wrong secret to fail, got %v", err)
+	}
+	if err := Verify("secret", header, []byte(`{"type":"chirp.deleted"}`), now, time.Minute); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("Expected a changed body to fail, got %v", err)
+	}
+	if err := Verify("secret", header, body, now.Add(10*time.Minute), time.Minute); !errors.Is(err, ErrStaleSignature) {
+		t.Fatalf("Expected an old signature to fail, got %v", err)
+	}
+	if err := Verify("secret", "garbage", body, now, time.Minute); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("Expected a malformed header to fail, got %v", err)
+	}
+}
+
+func TestRetryIn(t *testing.T) {
+	policy := Policy{Backoff: time.Second, MaxBackoff: 5 * time.Second, MaxAttempts: 5}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		got, ok := policy.RetryIn(i + 1)
+		if !ok || got != w {
+			t.Fatalf("After %d failures expected a retry in %v, got %v (%v)", i+1, w, got, ok)
+		}
+	}
+	if _, ok := policy.RetryIn(5); ok {
+		t.Fatalf("Expected no retry after MaxAttempts")
+	}
+}
+
+func TestSendSignsDeliveries(t *testing.T) {
+	var got *http.Request
+	var gotBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	body := []byte(`{"id":"1"}`)
+	result := NewSender(time.Second, true).Send(context.Background(), Request{
+		URL:        receiver.URL,
+		Secret:     "secret",
+		Event:      "chirp.created",
+		DeliveryID: "delivery-1",
+		Payload:    body,
+	})
+	if !result.OK() || result.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected a successful delivery, got %+v", result)
+	}
+
+	if got.Header.Get(EventHeader) != "chirp.created" || got.Header.Get(DeliveryHeader) != "delivery-1" {
+		t.Fatalf("Expected event and delivery headers, got %v", got.Header)
+	}
+	if err := Verify("secret", got.Header.Get(SignatureHeader), gotBody, time.Now(), time.Minute); err != nil {
+		t.Fatalf("Expected the receiver to verify the delivery, got %v", err)
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	sender := NewSender(time.Second, true)
+	if result := sender.Send(context.Background(), Request{URL: receiver.URL}); result.OK() || result.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Expected a 500 to fail, got %+v", result)
+	}
+	if result := sender.Send(context.Background(), Request{URL: receiver.URL + "/redirect"}); result.OK() || result.StatusCode != http.StatusFound {
+		t.Fatalf("Expected redirects not to be followed, got %+v", result)
+	}
+
+	result := NewSender(time.Second, false).Send(context.Background(), Request{URL: receiver.URL})
+	if !errors.Is(result.Err, ErrPrivateAddress) {
+		t.Fatalf("Expected a loopback receiver to be refused, got %+v", result)
+	}
+}
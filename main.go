@@ -2,14 +2,31 @@ package main
 
 import (
 	"database/sql"
+	"encoding/base64"
 	"fmt"
+	"github.com/dabates/httpServer/internal/abuse"
 	"github.com/dabates/httpServer/internal/api"
+	"github.com/dabates/httpServer/internal/auth"
 	"github.com/dabates/httpServer/internal/database"
+	"github.com/dabates/httpServer/internal/events"
+	"github.com/dabates/httpServer/internal/mailer"
+	"github.com/dabates/httpServer/internal/media"
+	"github.com/dabates/httpServer/internal/middleware"
+	"github.com/dabates/httpServer/internal/openapi"
+	"github.com/dabates/httpServer/internal/passwords"
+	"github.com/dabates/httpServer/internal/ratelimit"
+	"github.com/dabates/httpServer/internal/totp"
 	"github.com/dabates/httpServer/internal/types"
+	"github.com/dabates/httpServer/internal/webhooks"
 	"github.com/joho/godotenv"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 import _ "github.com/lib/pq"
 
@@ -31,6 +48,9 @@ func main() {
 	apiKey := os.Getenv("POLKA_KEY")
 	apiConfig.PolkaApiKey = apiKey
 
+	apiConfig.AdminApiKey = os.Getenv("ADMIN_KEY")
+	apiConfig.Abuse = newAbuseThresholds()
+
 	dbURL := os.Getenv("DB_URL")
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
@@ -39,62 +59,531 @@ func main() {
 	defer db.Close()
 
 	dbQueries := database.New(db)
+	apiConfig.DB = db
 	apiConfig.Db = dbQueries
+	apiConfig.Events = events.NewBus()
+	apiConfig.RateLimiter = newRateLimiter(os.Getenv("RATE_LIMIT_STORE"), dbQueries)
+	apiConfig.Mailer = newMailer(os.Getenv("MAILER"))
+	apiConfig.Media = newMediaStore()
+	apiConfig.Passwords = newPasswordPolicy(os.Getenv("BREACHED_PASSWORDS"))
+	auth.SetArgon2Params(newArgon2Params())
+	apiConfig.TOTP = newTOTPBox(os.Getenv("TOTP_KEY"))
+	apiConfig.TrustedProxies = newTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	// Webhooks may only reach local receivers while developing.
+	apiConfig.Webhooks = webhooks.NewDispatcher(dbQueries, webhooks.NewSender(10*time.Second, platform == "dev"), webhooks.DefaultPolicy())
+
+	// Responses are only checked against the spec while developing.
+	validator, err := openapi.NewValidator(platform == "dev")
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	mux := http.NewServeMux()
 	httpServer := &http.Server{
 		Addr:    ":8080",
-		Handler: mux,
+		Handler: middleware.Compress(validator.Middleware(api.RateLimit(&apiConfig, mux))),
 	}
 
-	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("GET /api/healthz", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 
 		w.WriteHeader(http.StatusOK)
 
 		w.Write([]byte("OK"))
-	})
+	}))
 
-	mux.HandleFunc("POST /api/users", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("POST /api/users", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
 		api.CreateUser(w, r, &apiConfig)
-	})
-	mux.HandleFunc("PUT /api/users", func(w http.ResponseWriter, r *http.Request) {
+	})))
+	mux.HandleFunc("PUT /api/users", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
 		api.UpdateUser(w, r, &apiConfig)
-	})
+	})))
+	mux.HandleFunc("POST /api/users/verify", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.VerifyEmail(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/users/verify/resend", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.ResendVerification(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("GET /api/users/{id}", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.GetProfile(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("PATCH /api/users/me/profile", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.UpdateProfile(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/users/me/2fa/setup", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.SetupTwoFactor(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/users/me/2fa/confirm", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.ConfirmTwoFactor(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/users/me/2fa/recovery-codes", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.RegenerateRecoveryCodes(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("DELETE /api/users/me/2fa", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.DisableTwoFactor(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("PUT /api/users/me/avatar", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.UploadAvatar(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("PUT /api/users/me/banner", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.UploadBanner(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("GET /media/{name}", api.WithCacheControl(api.CacheMedia, func(w http.ResponseWriter, r *http.Request) {
+		api.ServeMedia(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/password/forgot", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.ForgotPassword(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/password/reset", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.ResetPassword(w, r, &apiConfig)
+	}))
 
-	mux.HandleFunc("POST /api/chirps", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("POST /api/chirps", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
 		api.Chirps(w, r, &apiConfig)
-	})
+	})))
 	mux.HandleFunc("GET /api/chirps/{id}", func(w http.ResponseWriter, r *http.Request) {
 		api.GetChirps(w, r, &apiConfig)
 	})
 	mux.HandleFunc("GET /api/chirps", func(w http.ResponseWriter, r *http.Request) {
 		api.GetChirps(w, r, &apiConfig)
 	})
-	mux.HandleFunc("DELETE /api/chirps/{id}", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("DELETE /api/chirps/{id}", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
 		api.DeleteChirp(w, r, &apiConfig)
-	})
+	})))
+
+	mux.HandleFunc("POST /api/graphql", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.GraphQL(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/batch", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
+		api.Batch(w, r, &apiConfig)
+	})))
+
+	// blocks and mutes
+	mux.HandleFunc("POST /api/users/{id}/block", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.Block(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("DELETE /api/users/{id}/block", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.Unblock(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/users/{id}/mute", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.Mute(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("DELETE /api/users/{id}/mute", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.Unmute(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("DELETE /api/users/me/blocks/{id}", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.Unblock(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("DELETE /api/users/me/mutes/{id}", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.Unmute(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("GET /api/users/me/blocks", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.MyBlocks(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("GET /api/users/me/mutes", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.MyMutes(w, r, &apiConfig)
+	}))
+
+	// content filters
+	mux.HandleFunc("GET /api/users/me/filters", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.MyFilters(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/users/me/filters", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
+		api.CreateFilter(w, r, &apiConfig)
+	})))
+	mux.HandleFunc("DELETE /api/users/me/filters/{id}", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.DeleteFilter(w, r, &apiConfig)
+	}))
+
+	// direct messages
+	mux.HandleFunc("POST /api/conversations", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
+		api.StartConversation(w, r, &apiConfig)
+	})))
+	mux.HandleFunc("GET /api/conversations", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.MyConversations(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("GET /api/conversations/unread", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.UnreadMessages(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("GET /api/conversations/{id}/messages", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.ConversationMessages(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/conversations/{id}/messages", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
+		api.SendMessage(w, r, &apiConfig)
+	})))
+	mux.HandleFunc("POST /api/conversations/{id}/read", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.MarkConversationRead(w, r, &apiConfig)
+	}))
+
+	// notifications
+	mux.HandleFunc("GET /api/notifications", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.Notifications(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/notifications/read", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.ReadNotifications(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("GET /api/notifications/preferences", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.NotificationPreferences(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("PUT /api/notifications/preferences", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.UpdateNotificationPreferences(w, r, &apiConfig)
+	}))
+
+	// outbound webhooks
+	mux.HandleFunc("POST /api/webhooks", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
+		api.CreateWebhook(w, r, &apiConfig)
+	})))
+	mux.HandleFunc("GET /api/webhooks", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.MyWebhooks(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("GET /api/webhooks/{id}", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.GetWebhook(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("PUT /api/webhooks/{id}", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.UpdateWebhook(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("DELETE /api/webhooks/{id}", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.DeleteWebhook(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("GET /api/webhooks/{id}/deliveries", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.WebhookDeliveries(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/webhooks/{id}/deliveries/{delivery_id}/redeliver", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
+		api.RedeliverWebhook(w, r, &apiConfig)
+	})))
+
+	// reports and appeals
+	mux.HandleFunc("POST /api/chirps/{id}/report", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
+		api.ReportChirp(w, r, &apiConfig)
+	})))
+	mux.HandleFunc("POST /api/users/{id}/report", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
+		api.ReportUser(w, r, &apiConfig)
+	})))
+	mux.HandleFunc("GET /api/moderation/actions", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.MyModerationActions(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/moderation/actions/{id}/appeal", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
+		api.Appeal(w, r, &apiConfig)
+	})))
 
 	// Webhooks
-	mux.HandleFunc("POST /api/polka/webhooks", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("POST /api/polka/webhooks", api.WithCacheControl(api.CacheNoStore, api.WithIdempotency(&apiConfig, func(w http.ResponseWriter, r *http.Request) {
 		api.PolkaWebhook(w, r, &apiConfig)
-	})
+	})))
 
 	// auth
-	mux.HandleFunc("POST /api/login", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("POST /api/login", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
 		api.Login(w, r, &apiConfig)
-	})
-	mux.HandleFunc("POST /api/refresh", func(w http.ResponseWriter, r *http.Request) {
+	}))
+	mux.HandleFunc("POST /api/login/2fa", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.LoginTwoFactor(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /api/refresh", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
 		api.Refresh(w, r, &apiConfig)
-	})
-	mux.HandleFunc("POST /api/revoke", func(w http.ResponseWriter, r *http.Request) {
+	}))
+	mux.HandleFunc("POST /api/revoke", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
 		api.Revoke(w, r, &apiConfig)
-	})
+	}))
 
-	mux.HandleFunc("GET /admin/metrics", apiConfig.GetFileserverHits)
-	mux.HandleFunc("POST /admin/reset", apiConfig.Reset)
+	// docs
+	mux.HandleFunc("GET /api/openapi.json", openapi.ServeSpec)
+	mux.HandleFunc("GET /api/docs", openapi.ServeDocs)
 
-	mux.Handle("/app/", apiConfig.MiddlewareMetricsInc(http.StripPrefix("/app/", http.FileServer(http.Dir(".")))))
+	mux.HandleFunc("GET /admin/metrics", api.WithCacheControl(api.CacheNoStore, apiConfig.GetFileserverHits))
+	mux.HandleFunc("POST /admin/reset", api.WithCacheControl(api.CacheNoStore, apiConfig.Reset))
+	mux.HandleFunc("GET /admin/abuse/flagged", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.FlaggedChirps(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /admin/users/{id}/unlock", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.UnlockUser(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("GET /admin/moderation/reports", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.ModerationReports(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /admin/moderation/reports/{id}/actions", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.ModerateReport(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("GET /admin/moderation/actions", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.ModerationLog(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("GET /admin/moderation/appeals", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.ModerationAppeals(w, r, &apiConfig)
+	}))
+	mux.HandleFunc("POST /admin/moderation/appeals/{id}", api.WithCacheControl(api.CacheNoStore, func(w http.ResponseWriter, r *http.Request) {
+		api.DecideAppeal(w, r, &apiConfig)
+	}))
+
+	fileServer := apiConfig.MiddlewareMetricsInc(http.StripPrefix("/app/", http.FileServer(http.Dir("."))))
+	mux.Handle("/app/", api.WithCacheControl(api.CacheStatic, fileServer.ServeHTTP))
+
+	// gRPC is served on its own port next to the REST API.
+	grpcAddr := os.Getenv("GRPC_ADDR")
+	if grpcAddr == "" {
+		grpcAddr = ":8081"
+	}
+	listener, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		log.Fatal(api.NewGRPCServer(&apiConfig).Serve(listener))
+	}()
+
+	go api.PurgeIdempotencyKeys(&apiConfig, time.Hour)
+	go apiConfig.Webhooks.Run(time.Second)
 
 	log.Fatal(httpServer.ListenAndServe())
 }
+
+// newRateLimiter sets the request limits. Buckets live in memory unless
+// store is "postgres", which shares them between instances.
+func newRateLimiter(store string, db *database.Queries) *ratelimit.Limiter {
+	var buckets ratelimit.Store = ratelimit.NewMemoryStore()
+	if store == "postgres" {
+		pg := ratelimit.NewPostgresStore(db)
+		go pg.Purge(time.Hour)
+		buckets = pg
+	}
+
+	limiter := ratelimit.New(buckets, ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(60),
+		User:      ratelimit.PerMinute(120),
+		Red:       ratelimit.PerMinute(600),
+	})
+	limiter.SetRule("POST /api/login", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(10),
+		User:      ratelimit.PerMinute(10),
+		Red:       ratelimit.PerMinute(10),
+	})
+	limiter.SetRule("POST /api/login/2fa", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(10),
+		User:      ratelimit.PerMinute(10),
+		Red:       ratelimit.PerMinute(10),
+	})
+	limiter.SetRule("POST /api/users", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(5),
+		User:      ratelimit.PerMinute(5),
+		Red:       ratelimit.PerMinute(5),
+	})
+	limiter.SetRule("POST /api/users/verify/resend", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(3),
+		User:      ratelimit.PerMinute(3),
+		Red:       ratelimit.PerMinute(3),
+	})
+	limiter.SetRule("POST /api/password/forgot", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(3),
+		User:      ratelimit.PerMinute(3),
+		Red:       ratelimit.PerMinute(3),
+	})
+	limiter.SetRule("POST /api/password/reset", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(10),
+		User:      ratelimit.PerMinute(10),
+		Red:       ratelimit.PerMinute(10),
+	})
+	limiter.SetRule("POST /api/users/me/2fa/setup", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(5),
+		User:      ratelimit.PerMinute(5),
+		Red:       ratelimit.PerMinute(5),
+	})
+	limiter.SetRule("POST /api/users/me/2fa/confirm", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(10),
+		User:      ratelimit.PerMinute(10),
+		Red:       ratelimit.PerMinute(10),
+	})
+	limiter.SetRule("POST /api/users/me/2fa/recovery-codes", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(5),
+		User:      ratelimit.PerMinute(5),
+		Red:       ratelimit.PerMinute(5),
+	})
+	limiter.SetRule("DELETE /api/users/me/2fa", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(5),
+		User:      ratelimit.PerMinute(5),
+		Red:       ratelimit.PerMinute(5),
+	})
+	limiter.SetRule("PUT /api/users/me/avatar", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(5),
+		User:      ratelimit.PerMinute(5),
+		Red:       ratelimit.PerMinute(5),
+	})
+	limiter.SetRule("PUT /api/users/me/banner", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(5),
+		User:      ratelimit.PerMinute(5),
+		Red:       ratelimit.PerMinute(5),
+	})
+	limiter.SetRule("POST /api/chirps", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(10),
+		User:      ratelimit.PerMinute(30),
+		Red:       ratelimit.PerMinute(120),
+	})
+	limiter.SetRule("POST /api/batch", ratelimit.Rule{
+		Anonymous: ratelimit.PerMinute(5),
+		User:      ratelimit.PerMinute(10),
+		Red:       ratelimit.PerMinute(60),
+	})
+
+	return limiter
+}
+
+// newMailer picks how account emails are sent: "smtp" through SMTP_ADDR,
+// "memory" nowhere, and by default as files in MAIL_DIR for local testing.
+func newMailer(kind string) mailer.Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@chirpy.local>"
+	}
+
+	switch kind {
+	case "smtp":
+		return &mailer.SMTPMailer{
+			Addr:     os.Getenv("SMTP_ADDR"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	case "memory":
+		return &mailer.MemoryMailer{}
+	case "", "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return &mailer.FileMailer{Dir: dir, From: from}
+	}
+
+	log.Fatalf("MAILER: unknown mailer %q", kind)
+	return nil
+}
+
+// newMediaStore keeps uploaded images in MEDIA_DIR, "media" by default, and
+// links to them under MEDIA_URL, which defaults to this server's /media/ but
+// can point at a CDN in front of it.
+func newMediaStore() *media.Store {
+	dir := os.Getenv("MEDIA_DIR")
+	if dir == "" {
+		dir = "media"
+	}
+	baseURL := os.Getenv("MEDIA_URL")
+	if baseURL == "" {
+		baseURL = "/media/"
+	}
+	return &media.Store{Dir: dir, BaseURL: baseURL}
+}
+
+// newPasswordPolicy returns the default password policy, checking new
+// passwords against the breached password filter at path if one is given.
+// Build the filter with cmd/breachfilter.
+func newPasswordPolicy(path string) *passwords.Policy {
+	policy := passwords.DefaultPolicy()
+	if path != "" {
+		breached, err := passwords.LoadBloom(path)
+		if err != nil {
+			log.Fatalf("BREACHED_PASSWORDS: %v", err)
+		}
+		policy.Breached = breached
+	}
+	return &policy
+}
+
+// newArgon2Params returns the argon2id defaults with ARGON2_MEMORY (KiB),
+// ARGON2_TIME and ARGON2_THREADS overriding them, and logs how long a hash
+// takes with them. Logins slow down by that much, so it should stay well
+// under a second; users' hashes are upgraded as they log in.
+func newArgon2Params() auth.Argon2Params {
+	params := auth.DefaultArgon2Params()
+	threads := uint32(params.Threads)
+	for name, value := range map[string]*uint32{
+		"ARGON2_MEMORY":  &params.Memory,
+		"ARGON2_TIME":    &params.Time,
+		"ARGON2_THREADS": &threads,
+	} {
+		raw := os.Getenv(name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil || parsed == 0 {
+			log.Fatalf("%s: must be a positive integer", name)
+		}
+		*value = uint32(parsed)
+	}
+	if threads > 255 {
+		log.Fatal("ARGON2_THREADS: must be at most 255")
+	}
+	params.Threads = uint8(threads)
+
+	took := auth.BenchmarkArgon2(params)
+	log.Printf("argon2id with m=%d KiB, t=%d, p=%d takes %v a password", params.Memory, params.Time, params.Threads, took)
+	if took > time.Second {
+		log.Printf("argon2id parameters are slow enough to make logins sluggish; consider lowering them")
+	}
+	return params
+}
+
+// newTOTPBox seals two-factor secrets with key, 32 bytes in base64 (make
+// one with "openssl rand -base64 32"). Without a key users cannot turn on
+// two-factor authentication. Changing the key locks out everyone who has.
+func newTOTPBox(key string) *totp.Box {
+	if key == "" {
+		log.Printf("TOTP_KEY is not set; two-factor authentication is off")
+		return nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		log.Fatalf("TOTP_KEY: %v", err)
+	}
+	box, err := totp.NewBox(raw)
+	if err != nil {
+		log.Fatalf("TOTP_KEY: %v", err)
+	}
+	return box
+}
+
+// newTrustedProxies parses TRUSTED_PROXIES, a comma-separated list of the
+// addresses or CIDR ranges of proxies in front of the server. Without it
+// every client behind a proxy looks like the proxy, and shares its rate
+// limits and failed login count.
+func newTrustedProxies(list string) []netip.Prefix {
+	var proxies []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				log.Fatalf("TRUSTED_PROXIES: %v", err)
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			log.Fatalf("TRUSTED_PROXIES: %v", err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies
+}
+
+// newAbuseThresholds returns the spam heuristics' defaults, with the scores
+// at which chirps are flagged and rejected overridable from the environment.
+func newAbuseThresholds() *abuse.Thresholds {
+	thresholds := abuse.DefaultThresholds()
+	for name, score := range map[string]*float64{
+		"ABUSE_FLAG_SCORE":   &thresholds.FlagScore,
+		"ABUSE_REJECT_SCORE": &thresholds.RejectScore,
+	} {
+		raw := os.Getenv(name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		*score = value
+	}
+
+	return &thresholds
+}
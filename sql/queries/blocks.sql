@@ -0,0 +1,28 @@
+-- name: BlockUser :exec
+insert into blocks (blocker_id, blocked_id, created_at)
+values ($1, $2, now())
+on conflict do nothing;
+
+-- name: UnblockUser :exec
+delete
+from blocks
+where blocker_id = $1
+  and blocked_id = $2;
+
+-- name: GetBlocks :many
+select *
+from blocks
+where blocker_id = $1
+order by created_at desc;
+
+-- name: IsBlocked :one
+select exists(select 1
+              from blocks
+              where blocker_id = $1
+                and blocked_id = $2);
+
+-- name: DeleteFollowsBetween :exec
+delete
+from follows
+where (follower_id = $1 and followee_id = $2)
+   or (follower_id = $2 and followee_id = $1);
@@ -0,0 +1,11 @@
+-- name: CreateChirpAbuseScore :exec
+insert into chirp_abuse_scores (chirp_id, score, reasons, flagged, created_at)
+values ($1, $2, $3, $4, now());
+
+-- name: GetFlaggedChirps :many
+select chirps.*, chirp_abuse_scores.score, chirp_abuse_scores.reasons
+from chirp_abuse_scores
+         join chirps on chirps.id = chirp_abuse_scores.chirp_id
+where chirp_abuse_scores.flagged
+order by chirp_abuse_scores.created_at desc
+limit sqlc.arg(page_size);
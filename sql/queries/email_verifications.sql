@@ -0,0 +1,31 @@
+-- name: CreateEmailVerification :one
+insert into email_verifications (user_id, email, expires_at, created_at)
+values ($1, $2, now() + interval '24 hours', now())
+returning *;
+
+-- name: GetEmailVerification :one
+select *
+from email_verifications
+where id = $1
+  and used_at is null
+  and expires_at > now();
+
+-- name: UseEmailVerification :execrows
+update email_verifications
+set used_at = now()
+where id = $1
+  and used_at is null;
+
+-- name: RevokeEmailVerifications :exec
+update email_verifications
+set used_at = now()
+where user_id = $1
+  and used_at is null;
+
+-- name: MarkEmailVerified :one
+update users
+set email_verified_at = now(),
+    updated_at        = now()
+where id = $1
+  and email = $2
+returning *;
@@ -0,0 +1,4 @@
+-- name: FollowUser :execrows
+insert into follows (follower_id, followee_id, created_at)
+values ($1, $2, now())
+on conflict do nothing;
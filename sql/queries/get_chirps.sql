@@ -1,2 +1,2 @@
 -- name: GetChirps :many
-select * from chirps order by created_at ASC;
\ No newline at end of file
+select * from chirps where hidden_at is null order by created_at ASC;
\ No newline at end of file
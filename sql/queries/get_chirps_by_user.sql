@@ -2,4 +2,5 @@
 select *
 from chirps
 where user_id = $1
-order by created_at;
\ No newline at end of file
+  and hidden_at is null
+order by created_at;
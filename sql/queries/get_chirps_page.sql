@@ -0,0 +1,9 @@
+-- name: GetChirpsPage :many
+select *
+from chirps
+where hidden_at is null
+  and (sqlc.narg(author_id)::uuid is null or user_id = sqlc.narg(author_id)::uuid)
+  and (sqlc.narg(after_created_at)::timestamp is null
+    or (created_at, id) > (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid))
+order by created_at, id
+limit sqlc.arg(page_size);
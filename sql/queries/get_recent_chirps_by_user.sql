@@ -0,0 +1,6 @@
+-- name: GetRecentChirpsByUser :many
+select *
+from chirps
+where user_id = $1
+  and created_at > now() - sqlc.arg(window_seconds)::float8 * interval '1 second'
+order by created_at desc;
@@ -0,0 +1,4 @@
+-- name: GetUser :one
+select *
+from users
+where id = $1;
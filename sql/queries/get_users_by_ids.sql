@@ -0,0 +1,4 @@
+-- name: GetUsersByIDs :many
+select *
+from users
+where id = any (sqlc.arg(ids)::uuid[]);
@@ -0,0 +1,5 @@
+-- name: GetVisibleChirp :one
+select *
+from chirps
+where id = $1
+  and hidden_at is null;
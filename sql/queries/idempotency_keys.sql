@@ -0,0 +1,40 @@
+-- name: ClaimIdempotencyKey :one
+-- A key is claimed afresh once it expires, or once a claim with no response
+-- recorded is older than lease_seconds, as its request was abandoned.
+insert into idempotency_keys (scope, key, fingerprint, created_at)
+values ($1, $2, $3, now())
+on conflict (scope, key) do update
+    set fingerprint  = excluded.fingerprint,
+        status       = null,
+        content_type = null,
+        body         = null,
+        created_at   = excluded.created_at
+where idempotency_keys.created_at < now() - interval '24 hours'
+   or (idempotency_keys.status is null and
+       idempotency_keys.created_at < now() - make_interval(secs => sqlc.arg(lease_seconds)::integer))
+returning *;
+
+-- name: GetIdempotencyKey :one
+select *
+from idempotency_keys
+where scope = $1
+  and key = $2;
+
+-- name: SaveIdempotentResponse :exec
+update idempotency_keys
+set status       = $3,
+    content_type = $4,
+    body         = $5
+where scope = $1
+  and key = $2;
+
+-- name: DeleteIdempotencyKey :exec
+delete
+from idempotency_keys
+where scope = $1
+  and key = $2;
+
+-- name: DeleteExpiredIdempotencyKeys :exec
+delete
+from idempotency_keys
+where created_at < now() - interval '24 hours';
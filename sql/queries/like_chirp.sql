@@ -0,0 +1,4 @@
+-- name: LikeChirp :execrows
+insert into chirp_likes (chirp_id, user_id, created_at)
+values ($1, $2, now())
+on conflict do nothing;
@@ -0,0 +1,35 @@
+-- name: EnsureLoginThrottle :exec
+insert into login_throttles (key, failures, last_failed_at)
+values ($1, 0, now())
+on conflict (key) do nothing;
+
+-- name: LockLoginThrottle :one
+-- Locks the key's row until the transaction ends, so concurrent attempts on
+-- the same key are counted one after another.
+select failures,
+       coalesce(extract(epoch from blocked_until - now()), 0)::float8 as wait_seconds,
+       extract(epoch from now() - last_failed_at)::float8               as idle_seconds
+from login_throttles
+where key = $1
+    for update;
+
+-- name: SetLoginThrottle :exec
+update login_throttles
+set failures       = sqlc.arg(failures),
+    last_failed_at = now(),
+    blocked_until  = case
+                         when sqlc.arg(wait_seconds)::float8 > 0
+                             then now() + make_interval(secs => sqlc.arg(wait_seconds)::float8) end,
+    locked         = sqlc.arg(locked)
+where key = sqlc.arg(key);
+
+-- name: ForgiveLoginAttempt :exec
+-- Takes back an attempt that turned out to be a success.
+update login_throttles
+set failures = greatest(failures - 1, 0)
+where key = $1;
+
+-- name: ClearLoginThrottle :execrows
+delete
+from login_throttles
+where key = $1;
@@ -0,0 +1,99 @@
+-- name: CreateConversation :one
+insert into conversations (created_by, created_at, updated_at)
+values ($1, now(), now())
+returning *;
+
+-- name: AddConversationMember :exec
+insert into conversation_members (conversation_id, user_id, joined_at)
+values ($1, $2, now())
+on conflict do nothing;
+
+-- name: GetConversation :one
+select *
+from conversations
+where id = $1;
+
+-- name: IsConversationMember :one
+select exists(select 1
+              from conversation_members
+              where conversation_id = $1
+                and user_id = $2);
+
+-- name: GetDirectConversation :one
+select c.*
+from conversations c
+         join conversation_members a on a.conversation_id = c.id and a.user_id = sqlc.arg(user_id)
+         join conversation_members b on b.conversation_id = c.id and b.user_id = sqlc.arg(other_id)
+where (select count(*) from conversation_members m where m.conversation_id = c.id) = 2
+limit 1;
+
+-- name: GetConversationsByUser :many
+select c.id,
+       c.created_by,
+       c.created_at,
+       c.updated_at,
+       (select count(*)
+        from messages msg
+        where msg.conversation_id = c.id
+          and msg.sender_id <> cm.user_id
+          and (cm.last_read_at is null or msg.created_at > cm.last_read_at))::bigint as unread_count
+from conversations c
+         join conversation_members cm on cm.conversation_id = c.id
+where cm.user_id = $1
+order by c.updated_at desc
+limit sqlc.arg(page_size);
+
+-- name: GetConversationMembers :many
+select *
+from conversation_members
+where conversation_id = any (sqlc.arg(conversation_ids)::uuid[])
+order by joined_at, user_id;
+
+-- name: CreateMessage :one
+insert into messages (conversation_id, sender_id, body, created_at)
+values ($1, $2, $3, now())
+returning *;
+
+-- name: TouchConversation :exec
+update conversations
+set updated_at = now()
+where id = $1;
+
+-- name: GetMessage :one
+select *
+from messages
+where id = $1;
+
+-- name: GetMessagesPage :many
+select *
+from messages
+where conversation_id = $1
+  and (sqlc.narg(before_created_at)::timestamp is null
+    or (created_at, id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid))
+order by created_at desc, id desc
+limit sqlc.arg(page_size);
+
+-- name: MarkConversationRead :exec
+-- Read markers only move forward; without read_at everything up to now is read.
+update conversation_members
+set last_read_at = greatest(last_read_at, coalesce(sqlc.narg(read_at)::timestamp, now()))
+where conversation_id = $1
+  and user_id = $2;
+
+-- name: GetUnreadCounts :one
+select count(distinct cm.conversation_id)::bigint as conversations,
+       count(msg.id)::bigint                       as messages
+from conversation_members cm
+         join messages msg on msg.conversation_id = cm.conversation_id
+where cm.user_id = $1
+  and msg.sender_id <> cm.user_id
+  and (cm.last_read_at is null or msg.created_at > cm.last_read_at);
+
+-- name: GetConversationUnreadCount :one
+select count(msg.id)::bigint
+from conversation_members cm
+         join messages msg on msg.conversation_id = cm.conversation_id
+where cm.conversation_id = $1
+  and cm.user_id = $2
+  and msg.sender_id <> cm.user_id
+  and (cm.last_read_at is null or msg.created_at > cm.last_read_at);
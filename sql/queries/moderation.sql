@@ -0,0 +1,101 @@
+-- name: CreateReport :one
+insert into reports (reporter_id, chirp_id, user_id, reason, details, created_at)
+values ($1, $2, $3, $4, $5, now())
+on conflict do nothing
+returning *;
+
+-- name: GetReport :one
+select *
+from reports
+where id = $1;
+
+-- name: LockReport :one
+select *
+from reports
+where id = $1
+    for update;
+
+-- name: GetReportsByStatus :many
+select *
+from reports
+where status = $1
+order by created_at
+limit sqlc.arg(page_size);
+
+-- name: ResolveReport :exec
+update reports
+set status      = $2,
+    resolved_at = now()
+where id = $1;
+
+-- name: HideChirp :exec
+update chirps
+set hidden_at = now()
+where id = $1;
+
+-- name: UnhideChirp :exec
+update chirps
+set hidden_at = null
+where id = $1;
+
+-- name: SuspendUser :exec
+update users
+set suspended_at = now()
+where id = $1;
+
+-- name: UnsuspendUser :exec
+update users
+set suspended_at = null
+where id = $1;
+
+-- name: CreateModerationAction :one
+insert into moderation_actions (report_id, action, chirp_id, user_id, note, created_at)
+values ($1, $2, $3, $4, $5, now())
+returning *;
+
+-- name: GetModerationAction :one
+select *
+from moderation_actions
+where id = $1;
+
+-- name: GetModerationActions :many
+select *
+from moderation_actions
+order by created_at desc
+limit sqlc.arg(page_size);
+
+-- name: GetModerationActionsByUser :many
+select *
+from moderation_actions
+where user_id = $1
+order by created_at desc;
+
+-- name: CreateAppeal :one
+insert into appeals (action_id, user_id, message, created_at)
+values ($1, $2, $3, now())
+on conflict do nothing
+returning *;
+
+-- name: GetAppeal :one
+select *
+from appeals
+where id = $1;
+
+-- name: LockAppeal :one
+select *
+from appeals
+where id = $1
+    for update;
+
+-- name: GetAppealsByStatus :many
+select *
+from appeals
+where status = $1
+order by created_at
+limit sqlc.arg(page_size);
+
+-- name: ResolveAppeal :exec
+update appeals
+set status      = $2,
+    resolved_at = now()
+where id = $1;
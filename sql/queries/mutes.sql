@@ -0,0 +1,25 @@
+-- name: MuteUser :exec
+insert into mutes (muter_id, muted_id, created_at)
+values ($1, $2, now())
+on conflict do nothing;
+
+-- name: UnmuteUser :exec
+delete
+from mutes
+where muter_id = $1
+  and muted_id = $2;
+
+-- name: GetMutes :many
+select *
+from mutes
+where muter_id = $1
+order by created_at desc;
+
+-- name: GetHiddenAuthorIDs :many
+select muted_id
+from mutes
+where muter_id = $1
+union
+select blocked_id
+from blocks
+where blocker_id = $1;
@@ -0,0 +1,53 @@
+-- name: CreateNotification :exec
+-- Nothing is written if the user turned the type off or muted the actor.
+insert into notifications (user_id, actor_id, type, chirp_id, created_at)
+select $1, $2, $3, $4, now()
+where not exists(select 1
+                 from notification_preferences p
+                 where p.user_id = $1
+                   and p.type = $3
+                   and not p.enabled)
+  and not exists(select 1
+                 from mutes m
+                 where m.muter_id = $1
+                   and m.muted_id = $2);
+
+-- name: GetNotificationsPage :many
+select *
+from notifications
+where user_id = $1
+  and (not sqlc.arg(unread_only)::boolean or read_at is null)
+  and (sqlc.narg(before_created_at)::timestamp is null
+    or (created_at, id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid))
+order by created_at desc, id desc
+limit sqlc.arg(page_size);
+
+-- name: CountUnreadNotifications :one
+select count(*)
+from notifications
+where user_id = $1
+  and read_at is null;
+
+-- name: MarkNotificationsRead :exec
+update notifications
+set read_at = now()
+where user_id = $1
+  and read_at is null
+  and id = any (sqlc.arg(ids)::uuid[]);
+
+-- name: MarkNotificationsReadUpTo :exec
+update notifications
+set read_at = now()
+where user_id = $1
+  and read_at is null
+  and (created_at, id) <= (sqlc.arg(up_to_created_at)::timestamp, sqlc.arg(up_to_id)::uuid);
+
+-- name: GetNotificationPreferences :many
+select *
+from notification_preferences
+where user_id = $1;
+
+-- name: SetNotificationPreference :exec
+insert into notification_preferences (user_id, type, enabled)
+values ($1, $2, $3)
+on conflict (user_id, type) do update set enabled = excluded.enabled;
@@ -0,0 +1,38 @@
+-- name: CreatePasswordReset :one
+insert into password_resets (user_id, email, token_hash, expires_at, created_at)
+values ($1, $2, $3, now() + interval '1 hour', now())
+returning *;
+
+-- name: CountRecentPasswordResets :one
+select count(*)
+from password_resets
+where user_id = $1
+  and created_at > now() - interval '1 minute';
+
+-- name: GetPasswordResetByHash :one
+select *
+from password_resets
+where token_hash = $1
+  and used_at is null
+  and expires_at > now();
+
+-- name: UsePasswordReset :execrows
+update password_resets
+set used_at = now()
+where id = $1
+  and used_at is null;
+
+-- name: RevokePasswordResets :exec
+update password_resets
+set used_at = now()
+where user_id = $1
+  and used_at is null;
+
+-- name: ResetUserPassword :one
+-- Only while the user still has the address the reset was sent to.
+update users
+set hashed_password = $3,
+    updated_at      = now()
+where id = $1
+  and email = $2
+returning *;
@@ -0,0 +1,45 @@
+-- name: GetUserByHandle :one
+select *
+from users
+where lower(handle) = lower($1);
+
+-- name: GetUserByLogin :one
+-- Handles cannot contain @, so a login is never both an email and a handle.
+select *
+from users
+where email = $1
+   or lower(handle) = lower($1);
+
+-- name: GetUsersByHandles :many
+select *
+from users
+where lower(handle) = any (sqlc.arg(handles)::text[]);
+
+-- name: UpdateProfile :one
+update users
+set handle       = $2,
+    display_name = $3,
+    bio          = $4,
+    website      = $5,
+    updated_at   = now()
+where id = $1
+returning *;
+
+-- name: UpdateAvatar :one
+update users
+set avatar     = sqlc.arg(avatar)::text[],
+    updated_at = now()
+where id = sqlc.arg(id)
+returning *;
+
+-- name: UpdateBanner :one
+update users
+set banner     = sqlc.arg(banner)::text[],
+    updated_at = now()
+where id = sqlc.arg(id)
+returning *;
+
+-- name: GetProfileCounts :one
+select (select count(*) from chirps c where c.user_id = $1 and c.hidden_at is null)::bigint as chirp_count,
+       (select count(*) from follows f where f.followee_id = $1)::bigint                  as follower_count,
+       (select count(*) from follows f where f.follower_id = $1)::bigint                  as following_count;
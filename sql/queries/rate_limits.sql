@@ -0,0 +1,21 @@
+-- name: TakeRateLimitToken :one
+insert into rate_limits (key, tokens, allowed, updated_at)
+values (sqlc.arg(key), sqlc.arg(burst)::float8 - 1, true, now())
+on conflict (key) do update
+    set tokens     = case
+                         when least(sqlc.arg(burst)::float8, rate_limits.tokens +
+                             extract(epoch from now() - rate_limits.updated_at)::float8 * sqlc.arg(rate)::float8) >= 1
+                             then least(sqlc.arg(burst)::float8, rate_limits.tokens +
+                                 extract(epoch from now() - rate_limits.updated_at)::float8 * sqlc.arg(rate)::float8) - 1
+                         else least(sqlc.arg(burst)::float8, rate_limits.tokens +
+                             extract(epoch from now() - rate_limits.updated_at)::float8 * sqlc.arg(rate)::float8)
+        end,
+        allowed    = least(sqlc.arg(burst)::float8, rate_limits.tokens +
+                         extract(epoch from now() - rate_limits.updated_at)::float8 * sqlc.arg(rate)::float8) >= 1,
+        updated_at = now()
+returning tokens, allowed;
+
+-- name: DeleteIdleRateLimits :exec
+delete
+from rate_limits
+where updated_at < now() - interval '1 hour';
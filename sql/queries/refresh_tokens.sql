@@ -5,4 +5,11 @@ values ($1,
         $3,
         now(),
         now())
-returning *;
\ No newline at end of file
+returning *;
+
+-- name: RevokeUserRefreshTokens :exec
+update refresh_tokens
+set revoked_at = now(),
+    updated_at = now()
+where user_id = $1
+  and revoked_at is null;
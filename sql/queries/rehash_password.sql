@@ -0,0 +1,7 @@
+-- name: RehashPassword :execrows
+-- Only replaces the hash it was computed from, so a password changed in the
+-- meantime is not overwritten.
+update users
+set hashed_password = sqlc.arg(new_hash)
+where id = sqlc.arg(id)
+  and hashed_password = sqlc.arg(old_hash);
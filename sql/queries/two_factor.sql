@@ -0,0 +1,66 @@
+-- name: SetPendingTotpSecret :execrows
+-- Replaces a pending secret, but never a confirmed one.
+insert into totp_secrets (user_id, secret, created_at)
+values ($1, $2, now())
+on conflict (user_id) do update
+    set secret     = excluded.secret,
+        last_step  = 0,
+        created_at = now()
+where totp_secrets.confirmed_at is null;
+
+-- name: GetTotpSecret :one
+select *
+from totp_secrets
+where user_id = $1;
+
+-- name: ConfirmTotpSecret :execrows
+update totp_secrets
+set confirmed_at = now(),
+    last_step    = $2
+where user_id = $1
+  and confirmed_at is null;
+
+-- name: UseTotpStep :execrows
+-- Fails for codes at or before the last one used, so each works once.
+update totp_secrets
+set last_step = $2
+where user_id = $1
+  and confirmed_at is not null
+  and last_step < $2;
+
+-- name: DeleteRecoveryCodes :exec
+delete
+from recovery_codes
+where user_id = $1;
+
+-- name: CreateRecoveryCodes :exec
+insert into recovery_codes (user_id, code_hash, created_at)
+select sqlc.arg(user_id), unnest(sqlc.arg(code_hashes)::text[]), now();
+
+-- name: UseRecoveryCode :execrows
+update recovery_codes
+set used_at = now()
+where user_id = $1
+  and code_hash = $2
+  and used_at is null;
+
+-- name: CreateLoginChallenge :one
+insert into login_challenges (user_id, token_hash, expires_at, created_at)
+values ($1, $2, now() + interval '5 minutes', now())
+returning *;
+
+-- name: GetLoginChallengeByHash :one
+select *
+from login_challenges
+where token_hash = $1
+  and expires_at > now();
+
+-- name: DeleteLoginChallenge :execrows
+delete
+from login_challenges
+where id = $1;
+
+-- name: DeleteTotpSecret :execrows
+delete
+from totp_secrets
+where user_id = $1;
@@ -2,6 +2,7 @@
 update users
 set email    = $2,
     hashed_password = $3,
+    email_verified_at = case when email = $2 then email_verified_at end,
     updated_at = now()
 where id = $1
 returning *;
\ No newline at end of file
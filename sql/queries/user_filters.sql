@@ -0,0 +1,21 @@
+-- name: CreateUserFilter :one
+insert into user_filters (user_id, kind, value, action, expires_at, created_at)
+values ($1, $2, $3, $4, $5, now())
+returning *;
+
+-- name: GetUserFilters :many
+select *
+from user_filters
+where user_id = $1
+order by created_at;
+
+-- name: CountUserFilters :one
+select count(*)
+from user_filters
+where user_id = $1;
+
+-- name: DeleteUserFilter :execrows
+delete
+from user_filters
+where id = $1
+  and user_id = $2;
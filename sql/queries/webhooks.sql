@@ -0,0 +1,110 @@
+-- name: CreateWebhook :one
+insert into webhooks (user_id, url, secret, events, created_at, updated_at)
+values ($1, $2, $3, $4, now(), now())
+returning *;
+
+-- name: GetWebhook :one
+select *
+from webhooks
+where id = $1;
+
+-- name: GetWebhooksByUser :many
+select *
+from webhooks
+where user_id = $1
+order by created_at;
+
+-- name: CountWebhooksByUser :one
+select count(*)
+from webhooks
+where user_id = $1;
+
+-- name: GetWebhooksForEvent :many
+select *
+from webhooks
+where user_id = $1
+  and disabled_at is null
+  and sqlc.arg(event_type)::text = any (events);
+
+-- name: UpdateWebhook :one
+-- Re-enabling a webhook forgets its past failures.
+update webhooks
+set url           = $2,
+    events        = $3,
+    disabled_at   = case when sqlc.arg(enabled)::boolean then null else coalesce(disabled_at, now()) end,
+    failure_count = case when sqlc.arg(enabled)::boolean and disabled_at is not null then 0 else failure_count end,
+    updated_at    = now()
+where id = $1
+returning *;
+
+-- name: DeleteWebhook :exec
+delete
+from webhooks
+where id = $1;
+
+-- name: RecordWebhookSuccess :exec
+update webhooks
+set failure_count = 0
+where id = $1;
+
+-- name: RecordWebhookFailure :one
+-- The webhook is disabled once disable_after attempts in a row have failed.
+update webhooks
+set failure_count = failure_count + 1,
+    disabled_at   = case
+                        when disabled_at is null and failure_count + 1 >= sqlc.arg(disable_after)::integer then now()
+                        else disabled_at end
+where id = $1
+returning *;
+
+-- name: CreateWebhookDelivery :one
+insert into webhook_deliveries (webhook_id, event_id, event_type, payload, next_attempt_at, created_at)
+values ($1, $2, $3, $4, now(), now())
+returning *;
+
+-- name: GetWebhookDelivery :one
+select *
+from webhook_deliveries
+where id = $1;
+
+-- name: GetWebhookDeliveries :many
+select *
+from webhook_deliveries
+where webhook_id = $1
+order by created_at desc
+limit sqlc.arg(page_size);
+
+-- name: ClaimWebhookDeliveries :many
+-- Claimed deliveries are leased for lease_seconds, so another instance only
+-- picks them up if this one dies mid-attempt.
+update webhook_deliveries
+set next_attempt_at = now() + make_interval(secs => sqlc.arg(lease_seconds)::integer)
+where id in (select d.id
+             from webhook_deliveries d
+                      join webhooks w on w.id = d.webhook_id
+             where d.status = 'pending'
+               and d.next_attempt_at <= now()
+               and w.disabled_at is null
+             order by d.next_attempt_at
+             limit sqlc.arg(batch_size) for update of d skip locked)
+returning *;
+
+-- name: RecordWebhookDeliverySuccess :exec
+update webhook_deliveries
+set status          = 'succeeded',
+    attempts        = attempts + 1,
+    response_code   = $2,
+    error           = null,
+    next_attempt_at = null,
+    delivered_at    = now()
+where id = $1;
+
+-- name: RecordWebhookDeliveryFailure :exec
+-- Without retry_in_seconds the delivery has failed for good.
+update webhook_deliveries
+set status          = case when sqlc.narg(retry_in_seconds)::integer is null then 'failed' else 'pending' end,
+    attempts        = attempts + 1,
+    response_code   = $2,
+    error           = $3,
+    next_attempt_at = now() + make_interval(secs => sqlc.narg(retry_in_seconds)::integer)
+where id = $1;
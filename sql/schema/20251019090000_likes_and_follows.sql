@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+create table chirp_likes
+(
+    chirp_id   uuid      not null,
+    user_id    uuid      not null,
+    created_at timestamp not null,
+    primary key (chirp_id, user_id),
+    FOREIGN KEY (chirp_id)
+        REFERENCES chirps (id)
+        on delete cascade,
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+
+create table follows
+(
+    follower_id uuid      not null,
+    followee_id uuid      not null,
+    created_at  timestamp not null,
+    primary key (follower_id, followee_id),
+    FOREIGN KEY (follower_id)
+        REFERENCES users (id)
+        on delete cascade,
+    FOREIGN KEY (followee_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table follows;
+drop table chirp_likes;
+-- +goose StatementEnd
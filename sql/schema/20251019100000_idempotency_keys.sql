@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+create table idempotency_keys
+(
+    scope        text      not null,
+    key          text      not null,
+    fingerprint  text      not null,
+    status       integer,
+    content_type text,
+    body         bytea,
+    created_at   timestamp not null,
+    primary key (scope, key)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table idempotency_keys;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+create table rate_limits
+(
+    key        text             primary key,
+    tokens     double precision not null,
+    allowed    boolean          not null,
+    updated_at timestamp        not null
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table rate_limits;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+create table chirp_abuse_scores
+(
+    chirp_id   uuid primary key,
+    score      double precision not null,
+    reasons    text[]           not null,
+    flagged    boolean          not null,
+    created_at timestamp        not null,
+    FOREIGN KEY (chirp_id)
+        REFERENCES chirps (id)
+        on delete cascade
+);
+
+create index chirp_abuse_scores_flagged_idx on chirp_abuse_scores (created_at desc) where flagged;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table chirp_abuse_scores;
+-- +goose StatementEnd
@@ -0,0 +1,75 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table chirps
+    add column hidden_at timestamp default null;
+
+alter table users
+    add column suspended_at timestamp default null;
+
+create table reports
+(
+    id          uuid primary key default gen_random_uuid(),
+    reporter_id uuid      not null,
+    chirp_id    uuid,
+    user_id     uuid      not null,
+    reason      text      not null,
+    details     text      not null,
+    status      text      not null default 'open',
+    created_at  timestamp not null,
+    resolved_at timestamp default null,
+    FOREIGN KEY (reporter_id)
+        REFERENCES users (id)
+        on delete cascade,
+    FOREIGN KEY (chirp_id)
+        REFERENCES chirps (id)
+        on delete set null,
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+
+create unique index reports_open_chirp_idx on reports (reporter_id, chirp_id) where status = 'open' and chirp_id is not null;
+create unique index reports_open_user_idx on reports (reporter_id, user_id) where status = 'open' and chirp_id is null;
+
+-- Every moderation decision. Not tied to the rows it acted on, so that the
+-- history survives their deletion.
+create table moderation_actions
+(
+    id         uuid primary key default gen_random_uuid(),
+    report_id  uuid,
+    action     text      not null,
+    chirp_id   uuid,
+    user_id    uuid      not null,
+    note       text      not null,
+    created_at timestamp not null
+);
+
+create table appeals
+(
+    id          uuid primary key default gen_random_uuid(),
+    action_id   uuid      not null,
+    user_id     uuid      not null,
+    message     text      not null,
+    status      text      not null default 'open',
+    created_at  timestamp not null,
+    resolved_at timestamp default null,
+    FOREIGN KEY (action_id)
+        REFERENCES moderation_actions (id)
+        on delete cascade,
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        on delete cascade,
+    UNIQUE (action_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table appeals;
+drop table moderation_actions;
+drop table reports;
+alter table users
+    drop column suspended_at;
+alter table chirps
+    drop column hidden_at;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+create table blocks
+(
+    blocker_id uuid      not null,
+    blocked_id uuid      not null,
+    created_at timestamp not null,
+    primary key (blocker_id, blocked_id),
+    FOREIGN KEY (blocker_id)
+        REFERENCES users (id)
+        on delete cascade,
+    FOREIGN KEY (blocked_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+
+create table mutes
+(
+    muter_id   uuid      not null,
+    muted_id   uuid      not null,
+    created_at timestamp not null,
+    primary key (muter_id, muted_id),
+    FOREIGN KEY (muter_id)
+        REFERENCES users (id)
+        on delete cascade,
+    FOREIGN KEY (muted_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table mutes;
+drop table blocks;
+-- +goose StatementEnd
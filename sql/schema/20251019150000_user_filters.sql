@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+create table user_filters
+(
+    id         uuid primary key default gen_random_uuid(),
+    user_id    uuid      not null,
+    kind       text      not null,
+    value      text      not null,
+    action     text      not null,
+    expires_at timestamp default null,
+    created_at timestamp not null,
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+
+create index user_filters_user_id_idx on user_filters (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table user_filters;
+-- +goose StatementEnd
@@ -0,0 +1,54 @@
+-- +goose Up
+-- +goose StatementBegin
+create table conversations
+(
+    id         uuid primary key default gen_random_uuid(),
+    created_by uuid      default null,
+    created_at timestamp not null,
+    updated_at timestamp not null,
+    FOREIGN KEY (created_by)
+        REFERENCES users (id)
+        on delete set null
+);
+
+create table conversation_members
+(
+    conversation_id uuid      not null,
+    user_id         uuid      not null,
+    joined_at       timestamp not null,
+    last_read_at    timestamp default null,
+    primary key (conversation_id, user_id),
+    FOREIGN KEY (conversation_id)
+        REFERENCES conversations (id)
+        on delete cascade,
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+
+create index conversation_members_user_id_idx on conversation_members (user_id);
+
+create table messages
+(
+    id              uuid primary key default gen_random_uuid(),
+    conversation_id uuid      not null,
+    sender_id       uuid      not null,
+    body            text      not null,
+    created_at      timestamp not null,
+    FOREIGN KEY (conversation_id)
+        REFERENCES conversations (id)
+        on delete cascade,
+    FOREIGN KEY (sender_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+
+create index messages_conversation_id_created_at_idx on messages (conversation_id, created_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table messages;
+drop table conversation_members;
+drop table conversations;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+create table notifications
+(
+    id         uuid primary key default gen_random_uuid(),
+    user_id    uuid      not null,
+    actor_id   uuid      not null,
+    type       text      not null,
+    chirp_id   uuid      default null,
+    read_at    timestamp default null,
+    created_at timestamp not null,
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        on delete cascade,
+    FOREIGN KEY (actor_id)
+        REFERENCES users (id)
+        on delete cascade,
+    FOREIGN KEY (chirp_id)
+        REFERENCES chirps (id)
+        on delete cascade
+);
+
+create index notifications_user_id_created_at_idx on notifications (user_id, created_at, id);
+
+create table notification_preferences
+(
+    user_id uuid    not null,
+    type    text    not null,
+    enabled boolean not null,
+    primary key (user_id, type),
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table notification_preferences;
+drop table notifications;
+-- +goose StatementEnd
@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+create table webhooks
+(
+    id            uuid primary key default gen_random_uuid(),
+    user_id       uuid      not null,
+    url           text      not null,
+    secret        text      not null,
+    events        text[]    not null,
+    failure_count integer   not null default 0,
+    disabled_at   timestamp default null,
+    created_at    timestamp not null,
+    updated_at    timestamp not null,
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+
+create index webhooks_user_id_idx on webhooks (user_id);
+
+create table webhook_deliveries
+(
+    id              uuid primary key default gen_random_uuid(),
+    webhook_id      uuid      not null,
+    event_id        uuid      not null,
+    event_type      text      not null,
+    payload         text      not null,
+    status          text      not null default 'pending',
+    attempts        integer   not null default 0,
+    response_code   integer   default null,
+    error           text      default null,
+    next_attempt_at timestamp default null,
+    delivered_at    timestamp default null,
+    created_at      timestamp not null,
+    FOREIGN KEY (webhook_id)
+        REFERENCES webhooks (id)
+        on delete cascade
+);
+
+create index webhook_deliveries_webhook_id_created_at_idx on webhook_deliveries (webhook_id, created_at);
+create index webhook_deliveries_due_idx on webhook_deliveries (next_attempt_at) where status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table webhook_deliveries;
+drop table webhooks;
+-- +goose StatementEnd
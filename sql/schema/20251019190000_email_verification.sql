@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table users
+    add column email_verified_at timestamp default null;
+
+-- Accounts from before verification existed keep working.
+update users
+set email_verified_at = created_at;
+
+create table email_verifications
+(
+    id         uuid primary key default gen_random_uuid(),
+    user_id    uuid      not null,
+    email      text      not null,
+    expires_at timestamp not null,
+    used_at    timestamp default null,
+    created_at timestamp not null,
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+
+create index email_verifications_user_id_idx on email_verifications (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table email_verifications;
+
+alter table users
+    drop column email_verified_at;
+-- +goose StatementEnd
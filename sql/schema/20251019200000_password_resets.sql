@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+create table password_resets
+(
+    id         uuid primary key default gen_random_uuid(),
+    user_id    uuid      not null,
+    email      text      not null,
+    token_hash text      not null unique,
+    expires_at timestamp not null,
+    used_at    timestamp default null,
+    created_at timestamp not null,
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+
+create index password_resets_user_id_idx on password_resets (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table password_resets;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table users
+    add column handle       text default null,
+    add column display_name text not null default '',
+    add column bio          text not null default '',
+    add column website      text not null default '';
+
+create unique index users_handle_idx on users (lower(handle));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index users_handle_idx;
+
+alter table users
+    drop column website,
+    drop column bio,
+    drop column display_name,
+    drop column handle;
+-- +goose StatementEnd
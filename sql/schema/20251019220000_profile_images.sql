@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Media store names of each rendition, in the order of the media package's
+-- Avatar and Banner sizes.
+alter table users
+    add column avatar text[] not null default '{}',
+    add column banner text[] not null default '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table users
+    drop column banner,
+    drop column avatar;
+-- +goose StatementEnd
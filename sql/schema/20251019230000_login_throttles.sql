@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Failed logins in a row, keyed by "user:<id>" or "login:<name>" for
+-- accounts and "ip:<address>" for addresses.
+create table login_throttles
+(
+    key            text primary key,
+    failures       integer   not null,
+    last_failed_at timestamp not null,
+    blocked_until  timestamp default null,
+    locked         boolean   not null default false
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table login_throttles;
+-- +goose StatementEnd
@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+-- secret is sealed with the server's TOTP_KEY. Until confirmed_at is set the
+-- secret is only pending and can be replaced by starting setup again.
+-- last_step is the period of the last code accepted, so a code works once.
+create table totp_secrets
+(
+    user_id      uuid primary key,
+    secret       bytea     not null,
+    last_step    bigint    not null default 0,
+    confirmed_at timestamp default null,
+    created_at   timestamp not null,
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+
+create table recovery_codes
+(
+    id         uuid primary key default gen_random_uuid(),
+    user_id    uuid      not null,
+    code_hash  text      not null,
+    used_at    timestamp default null,
+    created_at timestamp not null,
+    unique (user_id, code_hash),
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+
+-- A login challenge is handed out in place of tokens when the password was
+-- right but a second factor is still needed.
+create table login_challenges
+(
+    id         uuid primary key default gen_random_uuid(),
+    user_id    uuid      not null,
+    token_hash text      not null unique,
+    expires_at timestamp not null,
+    created_at timestamp not null,
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        on delete cascade
+);
+
+create index login_challenges_user_id_idx on login_challenges (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table login_challenges;
+drop table recovery_codes;
+drop table totp_secrets;
+-- +goose StatementEnd